@@ -9,6 +9,7 @@ import (
 
 	"github.com/celer-network/go-rollup/db"
 	"github.com/celer-network/go-rollup/validator"
+	"github.com/celer-network/go-rollup/witness"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
@@ -17,7 +18,7 @@ import (
 	"github.com/rs/zerolog/log"
 
 	rollupdb "github.com/celer-network/go-rollup/db"
-	"github.com/celer-network/go-rollup/db/badgerdb"
+	"github.com/celer-network/go-rollup/db/backends"
 	"github.com/celer-network/go-rollup/statemachine"
 	"github.com/celer-network/go-rollup/types"
 	"github.com/celer-network/rollup-contracts/bindings/go/mainchain"
@@ -28,17 +29,23 @@ import (
 type Aggregator struct {
 	aggregatorDb          rollupdb.DB
 	validatorDb           rollupdb.DB
+	serializer            *types.Serializer
 	stateMachine          *statemachine.StateMachine
 	pendingBlock          *types.RollupBlock
+	pendingWitness        *types.BlockWitness
 	txGenerator           *TransactionGenerator
 	blockSubmitter        *BlockSubmitter
 	validator             *validator.Validator
 	relayerGrpcPort       int
 	bridge                *relayer.Bridge
 	withdrawManager       *relayer.WithdrawManager
+	witnessStore          *witness.Store
+	witnessServer         *witness.Server
 	numTransitionsInBlock int
 	fraudTransfer         bool
 	validatorMode         bool
+	// Whether to generate and serve block witnesses, which costs a state tree proof per input slot
+	generateWitnesses bool
 }
 
 func NewAggregator(
@@ -47,13 +54,15 @@ func NewAggregator(
 	mainchainKeystore string,
 	sidechainKeystore string,
 	relayerGrpcPort int,
+	witnessGrpcPort int,
 	fraudTransfer bool,
 	validatorMode bool) (*Aggregator, error) {
-	aggregatorDb, err := badgerdb.NewDB(aggregatorDbDir)
+	dbBackend := viper.GetString("dbBackend")
+	aggregatorDb, err := backends.NewDB(dbBackend, aggregatorDbDir)
 	if err != nil {
 		return nil, err
 	}
-	validatorDb, err := badgerdb.NewDB(validatorDbDir)
+	validatorDb, err := backends.NewDB(dbBackend, validatorDbDir)
 	if err != nil {
 		return nil, err
 	}
@@ -65,6 +74,8 @@ func NewAggregator(
 		return nil, err
 	}
 	numTransitionsInBlock := viper.GetInt("numTransitionsInBlock")
+	generateWitnesses := viper.GetBool("generateWitnesses")
+	stateTreeCacheSize := statemachine.WithStateTreeCacheSize(viper.GetInt("stateTreeCacheSize"))
 
 	mainchainKeystoreBytes, err := ioutil.ReadFile(mainchainKeystore)
 	if err != nil {
@@ -134,7 +145,7 @@ func NewAggregator(
 		return nil, err
 	}
 
-	aggregatorStateMachine, err := statemachine.NewStateMachine(aggregatorDb, serializer)
+	aggregatorStateMachine, err := statemachine.NewStateMachine(aggregatorDb, serializer, stateTreeCacheSize)
 	if err != nil {
 		log.Error().Err(err).Send()
 		return nil, err
@@ -157,13 +168,14 @@ func NewAggregator(
 			blockCommittee,
 		)
 
-	validatorStateMachine, err := statemachine.NewStateMachine(validatorDb, serializer)
+	validatorStateMachine, err := statemachine.NewStateMachine(validatorDb, serializer, stateTreeCacheSize)
 	validator := validator.NewValidator(
 		validatorDb,
 		serializer,
 		validatorStateMachine,
 		mainchainClient,
 		mainchainAuth,
+		common.HexToAddress(rollupChainAddress),
 		rollupChain,
 	)
 
@@ -184,24 +196,44 @@ func NewAggregator(
 		aggregatorDb,
 	)
 
-	return &Aggregator{
+	witnessStore := witness.NewStore(aggregatorDb, serializer)
+
+	a := &Aggregator{
 		aggregatorDb:    aggregatorDb,
 		validatorDb:     validatorDb,
+		serializer:      serializer,
 		stateMachine:    aggregatorStateMachine,
 		txGenerator:     transactionGenerator,
 		blockSubmitter:  blockSubmitter,
 		validator:       validator,
 		bridge:          bridge,
 		withdrawManager: withdrawManager,
+		witnessStore:    witnessStore,
+		witnessServer:   witness.NewServer(witnessGrpcPort, witnessStore),
 
-		pendingBlock:          types.NewRollupBlock(0),
 		numTransitionsInBlock: numTransitionsInBlock,
+		generateWitnesses:     generateWitnesses,
 		fraudTransfer:         fraudTransfer,
 		validatorMode:         validatorMode,
-	}, nil
+	}
+	// Resume at the block after the last checkpoint, which is the start of the block the aggregator stopped in
+	var nextBlockNumber uint64
+	checkpoint, exists, err := aggregatorStateMachine.GetStateCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		nextBlockNumber = checkpoint.NextBlockNumber
+	}
+	err = a.startPendingBlock(nextBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
 }
 
 func (a *Aggregator) Start() {
+	a.stateMachine.StartPruning()
 	go a.processTransactions()
 	if a.validatorMode {
 		go a.validator.Start()
@@ -211,6 +243,53 @@ func (a *Aggregator) Start() {
 	a.txGenerator.Start()
 	a.bridge.Start()
 	a.withdrawManager.Start()
+	if a.generateWitnesses {
+		a.witnessServer.Start()
+	}
+}
+
+// startPendingBlock starts a new pending block at the current state root, and retains the root so that the
+// witness of the block can be read from it once the block is complete
+func (a *Aggregator) startPendingBlock(blockNumber uint64) error {
+	a.pendingBlock = types.NewRollupBlock(blockNumber)
+	if !a.generateWitnesses {
+		return a.stateMachine.RetainStateRoot(blockNumber)
+	}
+	a.pendingWitness = &types.BlockWitness{
+		BlockNumber: blockNumber,
+		StateRoot:   a.stateMachine.GetStateRoot(),
+	}
+	nextSlotIndex, err := a.stateMachine.GetNextAccountSlotIndex()
+	if err != nil {
+		return err
+	}
+	a.pendingWitness.NextSlotIndex = nextSlotIndex
+	return a.stateMachine.RetainStateRoot(blockNumber)
+}
+
+// storeBlockWitness stores the witness of the complete pending block
+func (a *Aggregator) storeBlockWitness() error {
+	if a.pendingWitness.NextSlotIndex == nil {
+		return errors.New("Next slot index unknown at the start of the block")
+	}
+	witness, err := a.stateMachine.GetBlockWitnessForRoot(
+		a.pendingBlock, a.pendingWitness.StateRoot, a.pendingWitness.NextSlotIndex)
+	if err != nil {
+		return err
+	}
+	info, err := types.NewRollupBlockInfo(a.serializer, a.pendingBlock)
+	if err != nil {
+		return err
+	}
+	for i, transition := range a.pendingWitness.Transitions {
+		proof, err := info.GetTransitionInclusionProof(i)
+		if err != nil {
+			return err
+		}
+		transition.InclusionProof = proof.Siblings
+	}
+	witness.Transitions = a.pendingWitness.Transitions
+	return a.witnessStore.Put(witness)
 }
 
 func (a *Aggregator) processTransactions() {
@@ -221,6 +300,14 @@ func (a *Aggregator) processTransactions() {
 }
 
 func (a *Aggregator) applyTransaction(tx types.Transaction) (*types.SignedStateReceipt, error) {
+	var inputs []*types.StateSnapshot
+	var err error
+	if a.generateWitnesses {
+		inputs, err = a.stateMachine.GetInputStateSnapshots(tx)
+		if err != nil {
+			return nil, err
+		}
+	}
 	stateUpdate, err := a.stateMachine.ApplyTransaction(tx)
 	if err != nil {
 		return nil, err
@@ -230,17 +317,29 @@ func (a *Aggregator) applyTransaction(tx types.Transaction) (*types.SignedStateR
 	if err != nil {
 		return nil, err
 	}
+	if a.generateWitnesses {
+		a.pendingWitness.Transitions = append(a.pendingWitness.Transitions, &types.TransitionWitness{Inputs: inputs})
+	}
 
 	log.Debug().
 		Int("numPendingTxn", len(a.pendingBlock.Transitions)).
 		Int("numTxnInBlock", a.numTransitionsInBlock).Send()
 	if len(a.pendingBlock.Transitions) >= a.numTransitionsInBlock {
+		if a.generateWitnesses {
+			err = a.storeBlockWitness()
+			if err != nil {
+				log.Err(err).Uint64("blockNumber", a.pendingBlock.BlockNumber).Msg("Failed to store block witness")
+			}
+		}
 		_, proposeErr := a.blockSubmitter.proposeBlock(a.pendingBlock)
 		if proposeErr != nil {
 			log.Err(proposeErr).Msg("Propose error")
 			return nil, proposeErr
 		}
-		a.pendingBlock = types.NewRollupBlock(a.pendingBlock.BlockNumber + 1)
+		err = a.startPendingBlock(a.pendingBlock.BlockNumber + 1)
+		if err != nil {
+			log.Err(err).Msg("Failed to start pending block")
+		}
 	}
 
 	// TODO: Generate receipt?
@@ -11,10 +11,6 @@ import (
 	"github.com/celer-network/go-rollup/db"
 	rollupdb "github.com/celer-network/go-rollup/db"
 
-	"github.com/celer-network/go-rollup/db/memorydb"
-	"github.com/celer-network/go-rollup/smt"
-	"golang.org/x/crypto/sha3"
-
 	"github.com/celer-network/go-rollup/types"
 	"github.com/celer-network/go-rollup/utils"
 	"github.com/celer-network/rollup-contracts/bindings/go/mainchain"
@@ -178,10 +174,9 @@ func (bs *BlockSubmitter) commitBlock(
 	log.Debug().Str("tx", tx.Hash().Hex()).Msg("Committed block")
 	block, _ := bs.rollupChain.Blocks(&bind.CallOpts{}, big.NewInt(0))
 	log.Printf("Contract block root hash: %s", common.Bytes2Hex(block.RootHash[:]))
-	tree, _ := smt.NewSparseMerkleTree(memorydb.NewDB(), rollupdb.NamespaceRollupBlockTrie, sha3.NewLegacyKeccak256(), nil, int(block.BlockSize.Uint64()), false)
-	for i, encodedTransition := range proposal.Transitions {
-		//log.Debug().Str("encodedTransition", common.Bytes2Hex(encodedTransition)).Send()
-		_, _ = tree.Update(big.NewInt(int64(i)).Bytes(), encodedTransition)
+	tree, err := types.NewTransitionTree(proposal.Transitions)
+	if err != nil {
+		return err
 	}
 	log.Printf("Local block root hash: %s", common.Bytes2Hex(tree.Root()))
 
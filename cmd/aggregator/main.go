@@ -18,6 +18,7 @@ var (
 	mainchainKeystore = flag.String("mainchainkeystore", "env/keystore/node0.json", "Mainchain keystore file")
 	sidechainKeystore = flag.String("sidechainkeystore", "env/keystore/node0.json", "Sidechain keystore file")
 	relayerGrpcPort   = flag.Int("relayergrpcport", 6666, "Relayer gRPC port")
+	witnessGrpcPort   = flag.Int("witnessgrpcport", 6667, "Block witness gRPC port")
 	fraudTransfer     = flag.Bool("fraudtransfer", false, "Submit bad state root for transfers")
 	validatorMode     = flag.Bool("validatormode", false, "Run in validator mode")
 )
@@ -42,6 +43,7 @@ func main() {
 			*mainchainKeystore,
 			*sidechainKeystore,
 			*relayerGrpcPort,
+			*witnessGrpcPort,
 			*fraudTransfer,
 			*validatorMode,
 		)
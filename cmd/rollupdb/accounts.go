@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+
+	"github.com/celer-network/go-rollup/statemachine"
+	"github.com/celer-network/go-rollup/types"
+	"github.com/spf13/cobra"
+)
+
+type accountRecord struct {
+	SlotIndex   *big.Int           `json:"slotIndex"`
+	AccountInfo *types.AccountInfo `json:"accountInfo"`
+}
+
+func dumpAccounts(dbDir string, rootHex string) error {
+	return withStateMachine(dbDir, func(stateMachine *statemachine.StateMachine) error {
+		root, err := resolveStateRoot(stateMachine, rootHex)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		return stateMachine.ForEachAccount(root, func(slotIndex *big.Int, info *types.AccountInfo) error {
+			return encoder.Encode(&accountRecord{SlotIndex: slotIndex, AccountInfo: info})
+		})
+	})
+}
+
+func AccountsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "accounts",
+		Short: "Dump all accounts at a state root of a stopped node as JSON lines, in slot order",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbDir, err := cmd.Flags().GetString(flagDb)
+			if err != nil {
+				return err
+			}
+			root, err := cmd.Flags().GetString(flagRoot)
+			if err != nil {
+				return err
+			}
+			return dumpAccounts(dbDir, root)
+		},
+	}
+	cmd.Flags().String(flagDb, "", "Aggregator or validator DB directory")
+	cmd.Flags().String(flagRoot, "", "State root in hex, defaults to the last retained state root")
+	_ = cmd.MarkFlagRequired(flagDb)
+	return cmd
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/celer-network/go-rollup/statemachine"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/cobra"
+)
+
+const (
+	flagFrom = "from"
+	flagTo   = "to"
+)
+
+func diffStateRoots(dbDir string, fromHex string, toHex string) error {
+	return withStateMachine(dbDir, func(stateMachine *statemachine.StateMachine) error {
+		to, err := resolveStateRoot(stateMachine, toHex)
+		if err != nil {
+			return err
+		}
+		diffs, err := stateMachine.DiffStateRoots(common.FromHex(fromHex), to)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		for _, diff := range diffs {
+			err = encoder.Encode(diff)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func DiffCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Print the accounts that differ between two state roots of a stopped node as JSON lines",
+		Long: "Diff prints every account that differs between two state roots, with its state before and after, " +
+			"in slot order. Both state roots must not have been pruned.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbDir, err := cmd.Flags().GetString(flagDb)
+			if err != nil {
+				return err
+			}
+			from, err := cmd.Flags().GetString(flagFrom)
+			if err != nil {
+				return err
+			}
+			to, err := cmd.Flags().GetString(flagTo)
+			if err != nil {
+				return err
+			}
+			return diffStateRoots(dbDir, from, to)
+		},
+	}
+	cmd.Flags().String(flagDb, "", "Aggregator or validator DB directory")
+	cmd.Flags().String(flagFrom, "", "State root before, in hex")
+	cmd.Flags().String(flagTo, "", "State root after in hex, defaults to the last retained state root")
+	_ = cmd.MarkFlagRequired(flagDb)
+	_ = cmd.MarkFlagRequired(flagFrom)
+	return cmd
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/celer-network/go-rollup/db/backends"
+	"github.com/celer-network/go-rollup/validator"
+	"github.com/spf13/cobra"
+)
+
+func exportFraudProofs(dbDir string, out string) (err error) {
+	validatorDb, err := backends.NewDB(dbBackend, dbDir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := validatorDb.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+	var w io.Writer = os.Stdout
+	if out != "" {
+		file, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	return validator.NewFraudProofStore(validatorDb).Export(w)
+}
+
+func FraudProofsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fraudproofs",
+		Short: "Export the fraud proof audit trail of a validator DB as JSON",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbDir, err := cmd.Flags().GetString(flagDb)
+			if err != nil {
+				return err
+			}
+			out, err := cmd.Flags().GetString(flagOut)
+			if err != nil {
+				return err
+			}
+			return exportFraudProofs(dbDir, out)
+		},
+	}
+	cmd.Flags().String(flagDb, "", "Validator DB directory")
+	cmd.Flags().String(flagOut, "", "Output file, defaults to stdout")
+	_ = cmd.MarkFlagRequired(flagDb)
+	return cmd
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/celer-network/go-rollup/db/backends"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	flagDb        = "db"
+	flagDbBackend = "db-backend"
+	flagOut       = "out"
+)
+
+// Storage backend of the DB directories, which must match the dbBackend the node ran with
+var dbBackend string
+
+func main() {
+	cobra.EnableCommandSorting = false
+	log.Logger = log.With().Caller().Logger()
+
+	rootCmd := &cobra.Command{
+		Use:   "rollupdb",
+		Short: "rollup database inspection tool",
+	}
+	rootCmd.PersistentFlags().StringVar(
+		&dbBackend, flagDbBackend, backends.BadgerDB, "Storage backend of the DB, badgerdb or leveldb")
+
+	// Construct Root Command
+	rootCmd.AddCommand(
+		FraudProofsCommand(),
+		PruneCommand(),
+		SnapshotCommand(),
+		AccountsCommand(),
+		DiffCommand(),
+	)
+
+	err := rootCmd.Execute()
+	if err != nil {
+		log.Fatal().Err(err).Send()
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"github.com/celer-network/go-rollup/db/backends"
+	"github.com/celer-network/go-rollup/statemachine"
+	"github.com/celer-network/go-rollup/types"
+	"github.com/spf13/cobra"
+)
+
+// withStateMachine opens the DB of a stopped node and calls fn with its state machine
+func withStateMachine(dbDir string, fn func(stateMachine *statemachine.StateMachine) error) (err error) {
+	stateDb, err := backends.NewDB(dbBackend, dbDir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := stateDb.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+	serializer, err := types.NewSerializer()
+	if err != nil {
+		return err
+	}
+	stateMachine, err := statemachine.NewStateMachine(stateDb, serializer)
+	if err != nil {
+		return err
+	}
+	return fn(stateMachine)
+}
+
+func prune(dbDir string) error {
+	return withStateMachine(dbDir, func(stateMachine *statemachine.StateMachine) error {
+		stats, err := stateMachine.PruneStateTree()
+		if err != nil {
+			return err
+		}
+		return printSummary(stats)
+	})
+}
+
+func PruneCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Prune state tree nodes of a stopped node that are not in a retained state root",
+		Long: "Prune deletes the state tree nodes that are not reachable from the state roots retained at " +
+			"recent block boundaries, and reports the number of nodes and bytes reclaimed. " +
+			"The node must be stopped, as state after the last retained root is deleted as well.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbDir, err := cmd.Flags().GetString(flagDb)
+			if err != nil {
+				return err
+			}
+			return prune(dbDir)
+		},
+	}
+	cmd.Flags().String(flagDb, "", "Aggregator or validator DB directory")
+	_ = cmd.MarkFlagRequired(flagDb)
+	return cmd
+}
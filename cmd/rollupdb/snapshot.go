@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/celer-network/go-rollup/statemachine"
+	"github.com/celer-network/go-rollup/types"
+	"github.com/celer-network/go-rollup/validator"
+	"github.com/celer-network/rollup-contracts/bindings/go/mainchain"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	flagIn         = "in"
+	flagRoot       = "root"
+	flagBlock      = "block"
+	flagConfig     = "config"
+	flagStartBlock = "start-block"
+)
+
+type snapshotSummary struct {
+	StateRoot   string `json:"stateRoot"`
+	BlockNumber uint64 `json:"blockNumber,omitempty"`
+	Accounts    int    `json:"accounts,omitempty"`
+}
+
+func exportSnapshot(dbDir string, out string, rootHex string) error {
+	return withStateMachine(dbDir, func(stateMachine *statemachine.StateMachine) error {
+		root, err := resolveStateRoot(stateMachine, rootHex)
+		if err != nil {
+			return err
+		}
+		file, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		count, err := stateMachine.ExportStateSnapshot(file, root)
+		if err != nil {
+			return err
+		}
+		err = file.Sync()
+		if err != nil {
+			return err
+		}
+		return printSummary(&snapshotSummary{StateRoot: common.Bytes2Hex(root), Accounts: count})
+	})
+}
+
+// resolveStateRoot parses a hex state root, or returns the last retained state root if it is empty
+func resolveStateRoot(stateMachine *statemachine.StateMachine, rootHex string) ([]byte, error) {
+	if rootHex != "" {
+		return common.FromHex(rootHex), nil
+	}
+	roots, err := stateMachine.GetRetainedStateRoots()
+	if err != nil {
+		return nil, err
+	}
+	if len(roots) == 0 {
+		return nil, errors.New("No retained state root, specify one with --root")
+	}
+	return roots[len(roots)-1], nil
+}
+
+func importSnapshot(dbDir string, in string, blockNumber uint64, configDir string, startBlock uint64) error {
+	stateRoot, err := getCommittedStateRoot(configDir, blockNumber, startBlock)
+	if err != nil {
+		return err
+	}
+	return withStateMachine(dbDir, func(stateMachine *statemachine.StateMachine) error {
+		file, err := os.Open(in)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		err = stateMachine.ImportStateSnapshot(file, blockNumber, stateRoot)
+		if err != nil {
+			return err
+		}
+		return printSummary(&snapshotSummary{StateRoot: common.Bytes2Hex(stateRoot), BlockNumber: blockNumber})
+	})
+}
+
+// getCommittedStateRoot returns the post state root of a block committed to the RollupChain of the config
+func getCommittedStateRoot(configDir string, blockNumber uint64, startBlock uint64) ([]byte, error) {
+	viper.AddConfigPath(configDir)
+	viper.SetConfigName("ethereum_networks")
+	err := viper.MergeInConfig()
+	if err != nil {
+		return nil, err
+	}
+	viper.SetConfigName("mainchain_contract_addresses")
+	err = viper.MergeInConfig()
+	if err != nil {
+		return nil, err
+	}
+	mainchainClient, err := ethclient.Dial(viper.GetString("mainchainEndpoint"))
+	if err != nil {
+		return nil, err
+	}
+	defer mainchainClient.Close()
+	rollupChain, err := mainchain.NewRollupChain(common.HexToAddress(viper.GetString("rollupChain")), mainchainClient)
+	if err != nil {
+		return nil, err
+	}
+	serializer, err := types.NewSerializer()
+	if err != nil {
+		return nil, err
+	}
+	return validator.GetCommittedStateRoot(rollupChain, serializer, blockNumber, startBlock)
+}
+
+func printSummary(summary interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(summary)
+}
+
+func SnapshotCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Export or import a checkpoint of the state tree",
+	}
+	cmd.AddCommand(snapshotExportCommand(), snapshotImportCommand())
+	return cmd
+}
+
+func snapshotExportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export all accounts at a state root of a stopped node",
+		Long: "Export writes all accounts under a state root to a snapshot file, which another node can import " +
+			"to start from that state. The state root defaults to the one retained at the last block boundary.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbDir, err := cmd.Flags().GetString(flagDb)
+			if err != nil {
+				return err
+			}
+			out, err := cmd.Flags().GetString(flagOut)
+			if err != nil {
+				return err
+			}
+			root, err := cmd.Flags().GetString(flagRoot)
+			if err != nil {
+				return err
+			}
+			return exportSnapshot(dbDir, out, root)
+		},
+	}
+	cmd.Flags().String(flagDb, "", "Aggregator or validator DB directory")
+	cmd.Flags().String(flagOut, "", "Snapshot file")
+	cmd.Flags().String(flagRoot, "", "State root in hex, defaults to the last retained state root")
+	_ = cmd.MarkFlagRequired(flagDb)
+	_ = cmd.MarkFlagRequired(flagOut)
+	return cmd
+}
+
+func snapshotImportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import a snapshot into the DB of a new node",
+		Long: "Import rebuilds the state tree and the account indexes from a snapshot file of the state after a " +
+			"block. The state root of the snapshot must be the post state root of the block committed to the " +
+			"RollupChain. The node resumes from the block after it.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbDir, err := cmd.Flags().GetString(flagDb)
+			if err != nil {
+				return err
+			}
+			in, err := cmd.Flags().GetString(flagIn)
+			if err != nil {
+				return err
+			}
+			blockNumber, err := cmd.Flags().GetUint64(flagBlock)
+			if err != nil {
+				return err
+			}
+			configDir, err := cmd.Flags().GetString(flagConfig)
+			if err != nil {
+				return err
+			}
+			startBlock, err := cmd.Flags().GetUint64(flagStartBlock)
+			if err != nil {
+				return err
+			}
+			return importSnapshot(dbDir, in, blockNumber, configDir, startBlock)
+		},
+	}
+	cmd.Flags().String(flagDb, "", "Aggregator or validator DB directory")
+	cmd.Flags().String(flagIn, "", "Snapshot file")
+	cmd.Flags().Uint64(flagBlock, 0, "Rollup block that the snapshot is the state after")
+	cmd.Flags().String(flagConfig, "/tmp/celer-rollup-test/config", "Config directory")
+	cmd.Flags().Uint64(flagStartBlock, 0, "Mainchain block to search the committed block from")
+	_ = cmd.MarkFlagRequired(flagDb)
+	_ = cmd.MarkFlagRequired(flagIn)
+	_ = cmd.MarkFlagRequired(flagBlock)
+	return cmd
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+
+	"github.com/celer-network/go-rollup/db/backends"
+	"github.com/celer-network/go-rollup/statemachine"
+	"github.com/celer-network/go-rollup/types"
+	"github.com/celer-network/go-rollup/validator"
+	"github.com/celer-network/go-rollup/witness"
+	"github.com/celer-network/rollup-contracts/bindings/go/mainchain"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/rs/zerolog/pkgerrors"
+	"github.com/spf13/viper"
+)
+
+var (
+	config            = flag.String("config", "/tmp/celer-rollup-test/config", "Config directory")
+	validatorDbDir    = flag.String("validatordb", "/tmp/celer-rollup-test/validator_db", "Validator DB directory")
+	mainchainKeystore = flag.String("mainchainkeystore", "", "Mainchain keystore file, fraud proofs are only recorded if empty")
+	auditor           = flag.Bool("auditor", false, "Run as a watch-only auditor that reports but never submits fraud proofs")
+	reportFile        = flag.String("reportfile", "", "File to append fraud reports to")
+	webhook           = flag.String("webhook", "", "URL to POST fraud reports to")
+	witnessEndpoint   = flag.String("witnessendpoint", "", "Block witness gRPC endpoint, validates statelessly if set")
+	startBlock        = flag.Uint64("startblock", 0, "Mainchain block the rollup contracts were deployed at, to backfill events from on the first start")
+)
+
+func main() {
+	flag.Parse()
+	log.Logger = log.With().Caller().Logger()
+	zerolog.ErrorStackMarshaler = pkgerrors.MarshalStack
+	viper.AddConfigPath(*config)
+	viper.SetConfigName("parameters")
+	viper.MergeInConfig()
+	viper.SetConfigName("ethereum_networks")
+	viper.MergeInConfig()
+	viper.SetConfigName("mainchain_contract_addresses")
+	viper.MergeInConfig()
+
+	validatorDb, err := backends.NewDB(viper.GetString("dbBackend"), *validatorDbDir)
+	if err != nil {
+		log.Fatal().Err(err).Send()
+	}
+	serializer, err := types.NewSerializer()
+	if err != nil {
+		log.Fatal().Err(err).Send()
+	}
+	if *auditor && *mainchainKeystore != "" {
+		log.Fatal().Msg("An auditor does not use a mainchain keystore")
+	}
+	var mainchainAuth *bind.TransactOpts
+	if *mainchainKeystore != "" {
+		mainchainKeystoreBytes, err := ioutil.ReadFile(*mainchainKeystore)
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		mainchainKey, err := keystore.DecryptKey(mainchainKeystoreBytes, "")
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		mainchainAuth = bind.NewKeyedTransactor(mainchainKey.PrivateKey)
+	} else {
+		log.Info().Msg("No mainchain keystore, fraud proof submission disabled")
+	}
+	mainchainClient, err := ethclient.Dial(viper.GetString("mainchainEndpoint"))
+	if err != nil {
+		log.Fatal().Err(err).Send()
+	}
+	rollupChainAddress := common.HexToAddress(viper.GetString("rollupChain"))
+	rollupChain, err := mainchain.NewRollupChain(rollupChainAddress, mainchainClient)
+	if err != nil {
+		log.Fatal().Err(err).Send()
+	}
+	tokenRegistry, err :=
+		mainchain.NewTokenRegistry(common.HexToAddress(viper.GetString("tokenRegistry")), mainchainClient)
+	if err != nil {
+		log.Fatal().Err(err).Send()
+	}
+	stateMachine, err := statemachine.NewStateMachine(
+		validatorDb, serializer, statemachine.WithStateTreeCacheSize(viper.GetInt("stateTreeCacheSize")))
+	if err != nil {
+		log.Fatal().Err(err).Send()
+	}
+
+	err = validator.NewTokenRegistryWatcher(validatorDb, tokenRegistry, *startBlock).Start()
+	if err != nil {
+		log.Fatal().Err(err).Send()
+	}
+	v := validator.NewValidator(
+		validatorDb,
+		serializer,
+		stateMachine,
+		mainchainClient,
+		mainchainAuth,
+		rollupChainAddress,
+		rollupChain,
+	)
+	v.SetStartBlock(*startBlock)
+	if *reportFile != "" {
+		v.AddFraudReporter(validator.NewFileFraudReporter(*reportFile))
+	}
+	if *webhook != "" {
+		v.AddFraudReporter(validator.NewWebhookFraudReporter(*webhook))
+	}
+	if *witnessEndpoint != "" {
+		witnessClient, err := witness.NewClient(*witnessEndpoint, serializer)
+		if err != nil {
+			log.Fatal().Err(err).Send()
+		}
+		v.SetWitnessSource(witnessClient)
+	}
+	if *auditor && *reportFile == "" && *webhook == "" {
+		log.Warn().Msg("Auditor without -reportfile or -webhook, frauds are only recorded in the DB")
+	}
+	v.Start()
+	<-make(chan interface{})
+}
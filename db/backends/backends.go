@@ -0,0 +1,36 @@
+// Package backends opens a db.DB on the storage backend selected by config
+package backends
+
+import (
+	"fmt"
+
+	rollupdb "github.com/celer-network/go-rollup/db"
+	"github.com/celer-network/go-rollup/db/badgerdb"
+	"github.com/celer-network/go-rollup/db/leveldb"
+)
+
+// Names of the storage backends, as returned by DB.Type
+const (
+	BadgerDB = "badgerdb"
+	LevelDB  = "leveldb"
+)
+
+// NewDB creates new database or load existing database in the directory with the backend, or with badgerdb if
+// backend is empty
+func NewDB(backend string, dir string) (rollupdb.DB, error) {
+	var db rollupdb.DB
+	var err error
+	switch backend {
+	case "", BadgerDB:
+		db, err = badgerdb.NewDB(dir)
+	case LevelDB:
+		db, err = leveldb.NewDB(dir)
+	default:
+		return nil, fmt.Errorf("Unknown db backend %s", backend)
+	}
+	if err != nil {
+		// Do not return a typed nil
+		return nil, err
+	}
+	return db, nil
+}
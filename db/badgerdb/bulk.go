@@ -1,6 +1,7 @@
 package badgerdb
 
 import (
+	"errors"
 	"time"
 
 	"github.com/celer-network/go-rollup/db"
@@ -9,6 +10,8 @@ import (
 	"github.com/dgraph-io/badger/v2"
 )
 
+// Bulk writes with a badger WriteBatch, which commits a badger transaction whenever one is full. So the writes
+// of a bulk are not atomic, and those committed before DiscardLast are kept.
 type Bulk struct {
 	db        *DB
 	bulk      *badger.WriteBatch
@@ -17,10 +20,11 @@ type Bulk struct {
 	delCount  uint
 	keySize   uint64
 	valueSize uint64
+	isDiscard bool
+	isCommit  bool
 }
 
 func (bulk *Bulk) Set(namespace []byte, key []byte, value []byte) error {
-	// TODO Updating trie nodes may require many updates but ErrTxnTooBig is not handled
 	key = db.PrependNamespace(namespace, key)
 	key = db.ConvNilToBytes(key)
 	value = db.ConvNilToBytes(value)
@@ -37,7 +41,6 @@ func (bulk *Bulk) Set(namespace []byte, key []byte, value []byte) error {
 }
 
 func (bulk *Bulk) Delete(namespace []byte, key []byte) error {
-	// TODO Reverting trie may require many updates but ErrTxnTooBig is not handled
 	key = db.PrependNamespace(namespace, key)
 	key = db.ConvNilToBytes(key)
 
@@ -51,6 +54,13 @@ func (bulk *Bulk) Delete(namespace []byte, key []byte) error {
 }
 
 func (bulk *Bulk) Flush() error {
+	if bulk.isDiscard {
+		return errors.New("Commit after dicard tx is not allowed")
+	} else if bulk.isCommit {
+		return errors.New("Commit occures two times")
+	}
+	bulk.isCommit = true
+
 	writeStartT := time.Now()
 	err := bulk.bulk.Flush()
 	writeEndT := time.Now()
@@ -68,5 +78,6 @@ func (bulk *Bulk) Flush() error {
 }
 
 func (bulk *Bulk) DiscardLast() {
+	bulk.isDiscard = true
 	bulk.bulk.Cancel()
 }
@@ -219,7 +219,7 @@ func (db *DB) NewTx() rollupdb.Transaction {
 
 	retTransaction := &Transaction{
 		db:      db,
-		tx:      badgerTx,
+		txs:     []*badger.Txn{badgerTx},
 		createT: time.Now(),
 	}
 
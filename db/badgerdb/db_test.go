@@ -0,0 +1,130 @@
+package badgerdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	rollupdb "github.com/celer-network/go-rollup/db"
+	"github.com/celer-network/go-rollup/db/dbtest"
+)
+
+var testNamespace = []byte("t")
+
+func TestConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badgerdb-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dbtest.TestDB(t, func(t *testing.T) rollupdb.DB {
+		dbDir, err := ioutil.TempDir(dir, "db")
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := NewDB(dbDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+}
+
+func newTestDB(t *testing.T) (*DB, func()) {
+	dir, err := ioutil.TempDir("", "badgerdb-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := NewDB(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// writeLarge writes three times as many keys as a badger transaction allows
+func writeLarge(t *testing.T, db *DB, set func(namespace []byte, key []byte, value []byte) error) int {
+	numKeys := int(3 * db.db.MaxBatchCount())
+	for i := 0; i < numKeys; i++ {
+		if err := set(testNamespace, []byte(fmt.Sprintf("%08d", i)), []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return numKeys
+}
+
+func countKeys(t *testing.T, db *DB) int {
+	iter := db.IteratePrefix(testNamespace, nil)
+	defer iter.Close()
+	var count int
+	for ; iter.Valid(); count++ {
+		if err := iter.Next(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return count
+}
+
+func TestLargeTransaction(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large transaction in short mode")
+	}
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	tx := db.NewTx()
+	numKeys := writeLarge(t, db, tx.Set)
+	if len(tx.(*Transaction).txs) < 3 {
+		t.Fatalf("tx of %d keys is in %d badger txs", numKeys, len(tx.(*Transaction).txs))
+	}
+	tx.Discard()
+	if count := countKeys(t, db); count != 0 {
+		t.Fatalf("discarded tx wrote %d keys", count)
+	}
+
+	tx = db.NewTx()
+	writeLarge(t, db, tx.Set)
+	if count := countKeys(t, db); count != 0 {
+		t.Fatalf("tx wrote %d keys before commit", count)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if count := countKeys(t, db); count != numKeys {
+		t.Fatalf("committed %d keys, expected %d", count, numKeys)
+	}
+
+	// Deleting everything also needs several badger transactions
+	tx = db.NewTx()
+	writeLarge(t, db, func(namespace []byte, key []byte, value []byte) error {
+		return tx.Delete(namespace, key)
+	})
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if count := countKeys(t, db); count != 0 {
+		t.Fatalf("%d keys left after deleting all", count)
+	}
+}
+
+func TestLargeBulk(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large bulk in short mode")
+	}
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	bulk := db.NewBulk()
+	numKeys := writeLarge(t, db, bulk.Set)
+	if err := bulk.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if count := countKeys(t, db); count != numKeys {
+		t.Fatalf("flushed %d keys, expected %d", count, numKeys)
+	}
+}
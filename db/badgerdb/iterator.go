@@ -4,19 +4,22 @@ import (
 	"bytes"
 	"errors"
 
-	"github.com/celer-network/go-rollup/db"
+	rollupdb "github.com/celer-network/go-rollup/db"
 	"github.com/dgraph-io/badger/v2"
 )
 
 type Iterator struct {
-	start   []byte
-	end     []byte
-	reverse bool
-	iter    *badger.Iterator
+	// end is the bound in the direction of the iteration
+	end        []byte
+	includeEnd bool
+	reverse    bool
+	prefixLen  int
+	txn        *badger.Txn
+	iter       *badger.Iterator
 }
 
-func (db *DB) Iterator(start, end []byte) db.Iterator {
-	badgerTx := db.db.NewTransaction(true)
+func (db *DB) Iterator(start, end []byte) rollupdb.Iterator {
+	badgerTx := db.db.NewTransaction(false)
 
 	var reverse bool
 
@@ -36,14 +39,52 @@ func (db *DB) Iterator(start, end []byte) db.Iterator {
 	badgerIter.Seek(start)
 
 	retIter := &Iterator{
-		start:   start,
 		end:     end,
 		reverse: reverse,
+		txn:     badgerTx,
 		iter:    badgerIter,
 	}
 	return retIter
 }
 
+func (db *DB) IteratePrefix(namespace []byte, prefix []byte) rollupdb.Iterator {
+	return db.IterateRange(namespace, prefix, rollupdb.PrefixEnd(prefix), false)
+}
+
+func (db *DB) IterateRange(namespace []byte, start []byte, end []byte, reverse bool) rollupdb.Iterator {
+	rawStart, rawEnd, prefixLen := rollupdb.NamespaceRange(namespace, start, end)
+	badgerTx := db.db.NewTransaction(false)
+
+	opt := badger.DefaultIteratorOptions
+	opt.PrefetchValues = false
+	opt.Reverse = reverse
+	opt.Prefix = rawStart[:prefixLen]
+
+	retIter := &Iterator{
+		reverse:   reverse,
+		prefixLen: prefixLen,
+		txn:       badgerTx,
+		iter:      badgerTx.NewIterator(opt),
+	}
+	if !reverse {
+		retIter.end = rawEnd
+		retIter.iter.Seek(rawStart)
+		return retIter
+	}
+	retIter.end = rawStart
+	retIter.includeEnd = true
+	if rawEnd == nil {
+		retIter.iter.Rewind()
+		return retIter
+	}
+	// A reverse seek finds the last key not bigger than rawEnd, which is excluded
+	retIter.iter.Seek(rawEnd)
+	if retIter.iter.Valid() && bytes.Equal(retIter.iter.Item().Key(), rawEnd) {
+		retIter.iter.Next()
+	}
+	return retIter
+}
+
 func (iter *Iterator) Next() error {
 	if iter.Valid() {
 		iter.iter.Next()
@@ -64,6 +105,10 @@ func (iter *Iterator) Valid() bool {
 			if bytes.Compare(iter.end, iter.iter.Item().Key()) <= 0 {
 				return false
 			}
+		} else if iter.includeEnd {
+			if bytes.Compare(iter.iter.Item().Key(), iter.end) < 0 {
+				return false
+			}
 		} else {
 			if bytes.Compare(iter.iter.Item().Key(), iter.end) <= 0 {
 				return false
@@ -75,7 +120,7 @@ func (iter *Iterator) Valid() bool {
 }
 
 func (iter *Iterator) Key() (key []byte, err error) {
-	return iter.iter.Item().Key(), nil
+	return iter.iter.Item().KeyCopy(nil)[iter.prefixLen:], nil
 }
 
 func (iter *Iterator) Value() (value []byte, err error) {
@@ -88,3 +133,8 @@ func (iter *Iterator) Value() (value []byte, err error) {
 
 	return retVal, nil
 }
+
+func (iter *Iterator) Close() {
+	iter.iter.Close()
+	iter.txn.Discard()
+}
@@ -1,6 +1,7 @@
 package badgerdb
 
 import (
+	"errors"
 	"time"
 
 	rollupdb "github.com/celer-network/go-rollup/db"
@@ -8,23 +9,32 @@ import (
 	"github.com/dgraph-io/badger/v2"
 )
 
+// Transaction buffers its operations in badger transactions. When one is full, the operations continue in a new
+// one, and Commit commits them in order. Nothing is written before Commit, and a transaction that fits in one
+// badger transaction is committed atomically. A bigger one is not: if Commit fails after the first badger
+// transaction, it returns a *rollupdb.PartialCommitError, and if the process stops during Commit, a prefix of its
+// badger transactions may have been committed.
 type Transaction struct {
-	db        *DB
-	tx        *badger.Txn
+	db *DB
+	// txs are the badger transactions, all but the last of which are full
+	txs       []*badger.Txn
 	createT   time.Time
 	setCount  uint
 	delCount  uint
 	keySize   uint64
 	valueSize uint64
+	isDiscard bool
+	isCommit  bool
 }
 
 func (transaction *Transaction) Set(namespace []byte, key []byte, value []byte) error {
-	// TODO Updating trie nodes may require many updates but ErrTxnTooBig is not handled
 	key = rollupdb.PrependNamespace(namespace, key)
 	key = rollupdb.ConvNilToBytes(key)
 	value = rollupdb.ConvNilToBytes(value)
 
-	err := transaction.tx.Set(key, value)
+	err := transaction.update(func(tx *badger.Txn) error {
+		return tx.Set(key, value)
+	})
 	if err != nil {
 		return err
 	}
@@ -36,11 +46,12 @@ func (transaction *Transaction) Set(namespace []byte, key []byte, value []byte)
 }
 
 func (transaction *Transaction) Delete(namespace []byte, key []byte) error {
-	// TODO Reverting trie may require many updates but ErrTxnTooBig is not handled
 	key = rollupdb.PrependNamespace(namespace, key)
 	key = rollupdb.ConvNilToBytes(key)
 
-	err := transaction.tx.Delete(key)
+	err := transaction.update(func(tx *badger.Txn) error {
+		return tx.Delete(key)
+	})
 	if err != nil {
 		return err
 	}
@@ -49,11 +60,47 @@ func (transaction *Transaction) Delete(namespace []byte, key []byte) error {
 	return nil
 }
 
+// update applies op to the last badger transaction, or to a new one if the last is full
+func (transaction *Transaction) update(op func(tx *badger.Txn) error) error {
+	err := op(transaction.txs[len(transaction.txs)-1])
+	if err != badger.ErrTxnTooBig {
+		return err
+	}
+	tx := transaction.db.db.NewTransaction(true)
+	transaction.txs = append(transaction.txs, tx)
+	return op(tx)
+}
+
 func (transaction *Transaction) Commit() error {
+	// badger panics on committing a discarded or committed txn
+	if transaction.isDiscard {
+		return errors.New("Commit after dicard tx is not allowed")
+	} else if transaction.isCommit {
+		return errors.New("Commit occures two times")
+	}
+	transaction.isCommit = true
+
 	writeStartT := time.Now()
-	err := transaction.tx.Commit()
+	var err error
+	for i, tx := range transaction.txs {
+		err = tx.Commit()
+		if err != nil {
+			for _, tx := range transaction.txs[i+1:] {
+				tx.Discard()
+			}
+			if i > 0 {
+				err = &rollupdb.PartialCommitError{Committed: i, Total: len(transaction.txs), Err: err}
+			}
+			break
+		}
+	}
 	writeEndT := time.Now()
 
+	if len(transaction.txs) > 1 {
+		logger.Warn().Str("name", transaction.db.name).Int("txCount", len(transaction.txs)).
+			Msg("tx is too big for one badger tx, committed non-atomically")
+	}
+
 	if writeEndT.Sub(writeStartT) > time.Millisecond*100 {
 		// write warn log when write tx take too long time (100ms)
 		logger.Warn().Str("name", transaction.db.name).Str("callstack1", log.SkipCaller(2)).Str("callstack2", log.SkipCaller(3)).
@@ -61,12 +108,15 @@ func (transaction *Transaction) Commit() error {
 			Dur("takenTime", writeEndT.Sub(writeStartT)).
 			Uint("delCount", transaction.delCount).Uint("setCount", transaction.setCount).
 			Uint64("setKeySize", transaction.keySize).Uint64("setValueSize", transaction.valueSize).
-			Msg("commit takes long time")
+			Int("txCount", len(transaction.txs)).Msg("commit takes long time")
 	}
 
 	return err
 }
 
 func (transaction *Transaction) Discard() {
-	transaction.tx.Discard()
+	transaction.isDiscard = true
+	for _, tx := range transaction.txs {
+		tx.Discard()
+	}
 }
@@ -0,0 +1,428 @@
+// Package dbtest is a conformance suite for implementations of db.DB. A backend runs it from its own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		dbtest.TestDB(t, func(t *testing.T) db.DB { return NewDB() })
+//	}
+package dbtest
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	rollupdb "github.com/celer-network/go-rollup/db"
+)
+
+// NewDBFunc creates an empty db. The suite closes it at the end of each test.
+type NewDBFunc func(t *testing.T) rollupdb.DB
+
+var (
+	testNamespace      = []byte("t")
+	testOtherNamespace = []byte("tt")
+	testKeys           = [][]byte{{}, {0x00}, {0x01}, {0x01, 0x00}, {0x01, 0xff}, {0x02}, {0xff}, {0xff, 0xff}}
+)
+
+// TestDB runs the conformance suite on the dbs created by newDB
+func TestDB(t *testing.T, newDB NewDBFunc) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, db rollupdb.DB)
+	}{
+		{"GetSetDelete", testGetSetDelete},
+		{"Namespaces", testNamespaces},
+		{"Transaction", testTransaction},
+		{"TransactionDiscard", testTransactionDiscard},
+		{"Bulk", testBulk},
+		{"BulkDiscard", testBulkDiscard},
+		{"IteratePrefix", testIteratePrefix},
+		{"IterateRange", testIterateRange},
+		{"IteratorRawKeys", testIteratorRawKeys},
+		{"IteratorSnapshot", testIteratorSnapshot},
+		{"ConcurrentAccess", testConcurrentAccess},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			db := newDB(t)
+			defer db.Close()
+			test.test(t, db)
+		})
+	}
+}
+
+func checkValue(t *testing.T, db rollupdb.DB, namespace []byte, key []byte, expected []byte) {
+	t.Helper()
+	value, exists, err := db.Get(namespace, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exist, err := db.Exist(namespace, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists != exist {
+		t.Errorf("Get and Exist disagree on key %x", key)
+	}
+	if expected == nil {
+		if exists {
+			t.Errorf("got value %x for deleted key %x", value, key)
+		}
+		return
+	}
+	if !exists {
+		t.Errorf("key %x does not exist", key)
+	} else if !bytes.Equal(value, expected) {
+		t.Errorf("got value %x for key %x, expected %x", value, key, expected)
+	}
+}
+
+func testGetSetDelete(t *testing.T, db rollupdb.DB) {
+	checkValue(t, db, testNamespace, []byte("key"), nil)
+	// Deleting a missing key is not an error
+	if err := db.Delete(testNamespace, []byte("key")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Set(testNamespace, []byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	checkValue(t, db, testNamespace, []byte("key"), []byte("value"))
+	if err := db.Set(testNamespace, []byte("key"), []byte("value2")); err != nil {
+		t.Fatal(err)
+	}
+	checkValue(t, db, testNamespace, []byte("key"), []byte("value2"))
+	if err := db.Delete(testNamespace, []byte("key")); err != nil {
+		t.Fatal(err)
+	}
+	checkValue(t, db, testNamespace, []byte("key"), nil)
+
+	// A nil value is stored as an empty value, and a nil key as an empty key
+	if err := db.Set(testNamespace, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkValue(t, db, testNamespace, []byte{}, []byte{})
+}
+
+func testNamespaces(t *testing.T, db rollupdb.DB) {
+	for _, namespace := range [][]byte{testNamespace, testOtherNamespace} {
+		if err := db.Set(namespace, []byte("key"), namespace); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkValue(t, db, testNamespace, []byte("key"), testNamespace)
+	checkValue(t, db, testOtherNamespace, []byte("key"), testOtherNamespace)
+	checkValue(t, db, []byte("u"), []byte("key"), nil)
+	// A nil namespace reads raw keys
+	checkValue(t, db, nil, rawKey(testNamespace, []byte("key")), testNamespace)
+
+	if err := db.Delete(testNamespace, []byte("key")); err != nil {
+		t.Fatal(err)
+	}
+	checkValue(t, db, testNamespace, []byte("key"), nil)
+	checkValue(t, db, testOtherNamespace, []byte("key"), testOtherNamespace)
+}
+
+func testTransaction(t *testing.T, db rollupdb.DB) {
+	if err := db.Set(testNamespace, []byte("deleted"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	tx := db.NewTx()
+	if err := tx.Set(testNamespace, []byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Set(testNamespace, []byte("key"), []byte("value2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Set(testNamespace, []byte("set and deleted"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Delete(testNamespace, []byte("set and deleted")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Delete(testNamespace, []byte("deleted")); err != nil {
+		t.Fatal(err)
+	}
+	// Nothing is visible before the commit
+	checkValue(t, db, testNamespace, []byte("key"), nil)
+	checkValue(t, db, testNamespace, []byte("deleted"), []byte("value"))
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	checkValue(t, db, testNamespace, []byte("key"), []byte("value2"))
+	checkValue(t, db, testNamespace, []byte("set and deleted"), nil)
+	checkValue(t, db, testNamespace, []byte("deleted"), nil)
+	if err := tx.Commit(); err == nil {
+		t.Error("committing a transaction twice did not fail")
+	}
+}
+
+func testTransactionDiscard(t *testing.T, db rollupdb.DB) {
+	tx := db.NewTx()
+	if err := tx.Set(testNamespace, []byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	tx.Discard()
+	if err := tx.Commit(); err == nil {
+		t.Error("committing a discarded transaction did not fail")
+	}
+	checkValue(t, db, testNamespace, []byte("key"), nil)
+}
+
+func testBulk(t *testing.T, db rollupdb.DB) {
+	const numKeys = 1000
+	if err := db.Set(testNamespace, []byte("deleted"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	bulk := db.NewBulk()
+	for i := 0; i < numKeys; i++ {
+		if err := bulk.Set(testNamespace, []byte(fmt.Sprint(i)), []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bulk.Delete(testNamespace, []byte("deleted")); err != nil {
+		t.Fatal(err)
+	}
+	// A bulk may commit before the flush, so only the state after the flush is defined
+	if err := bulk.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < numKeys; i++ {
+		checkValue(t, db, testNamespace, []byte(fmt.Sprint(i)), []byte(fmt.Sprint(i)))
+	}
+	checkValue(t, db, testNamespace, []byte("deleted"), nil)
+	if err := bulk.Flush(); err == nil {
+		t.Error("flushing a bulk twice did not fail")
+	}
+}
+
+func testBulkDiscard(t *testing.T, db rollupdb.DB) {
+	bulk := db.NewBulk()
+	if err := bulk.Set(testNamespace, []byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	// A small bulk has not committed anything yet, so all of it is discarded
+	bulk.DiscardLast()
+	if err := bulk.Flush(); err == nil {
+		t.Error("flushing a discarded bulk did not fail")
+	}
+	checkValue(t, db, testNamespace, []byte("key"), nil)
+}
+
+func fillTestKeys(t *testing.T, db rollupdb.DB) {
+	for _, namespace := range [][]byte{testNamespace, testOtherNamespace} {
+		for _, key := range testKeys {
+			err := db.Set(namespace, key, rawKey(namespace, key))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+// rawKey returns the key prefixed with the namespace, which is also the value of a test key
+func rawKey(namespace []byte, key []byte) []byte {
+	if namespace == nil {
+		return key
+	}
+	return rollupdb.PrependNamespace(append([]byte{}, namespace...), key)
+}
+
+// collect returns the keys of iter and checks that each value is the raw key
+func collect(t *testing.T, iter rollupdb.Iterator, namespace []byte) [][]byte {
+	t.Helper()
+	defer iter.Close()
+	var keys [][]byte
+	for iter.Valid() {
+		key, err := iter.Key()
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := iter.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(value, rawKey(namespace, key)) {
+			t.Errorf("value %x for key %x", value, key)
+		}
+		keys = append(keys, key)
+		err = iter.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := iter.Next(); err == nil {
+		t.Error("moving an invalid iterator did not fail")
+	}
+	return keys
+}
+
+func checkKeys(t *testing.T, name string, keys [][]byte, expected [][]byte) {
+	t.Helper()
+	if len(keys) != len(expected) {
+		t.Errorf("%s: got keys %x, expected %x", name, keys, expected)
+		return
+	}
+	for i := range keys {
+		if !bytes.Equal(keys[i], expected[i]) {
+			t.Errorf("%s: got keys %x, expected %x", name, keys, expected)
+			return
+		}
+	}
+}
+
+func reversed(keys [][]byte) [][]byte {
+	reversed := make([][]byte, len(keys))
+	for i := range keys {
+		reversed[len(keys)-1-i] = keys[i]
+	}
+	return reversed
+}
+
+func testIteratePrefix(t *testing.T, db rollupdb.DB) {
+	fillTestKeys(t, db)
+	checkKeys(t, "nil prefix", collect(t, db.IteratePrefix(testNamespace, nil), testNamespace), testKeys)
+	checkKeys(t, "other namespace",
+		collect(t, db.IteratePrefix(testOtherNamespace, nil), testOtherNamespace), testKeys)
+	checkKeys(t, "prefix 01",
+		collect(t, db.IteratePrefix(testNamespace, []byte{0x01}), testNamespace), testKeys[2:5])
+	checkKeys(t, "prefix ff",
+		collect(t, db.IteratePrefix(testNamespace, []byte{0xff}), testNamespace), testKeys[6:])
+	checkKeys(t, "prefix 03", collect(t, db.IteratePrefix(testNamespace, []byte{0x03}), testNamespace), nil)
+	checkKeys(t, "missing namespace", collect(t, db.IteratePrefix([]byte("u"), nil), nil), nil)
+}
+
+func testIterateRange(t *testing.T, db rollupdb.DB) {
+	fillTestKeys(t, db)
+	ranges := []struct {
+		name     string
+		start    []byte
+		end      []byte
+		expected [][]byte
+	}{
+		{"open", nil, nil, testKeys},
+		{"open end", []byte{0x01, 0x00}, nil, testKeys[3:]},
+		{"open start", nil, []byte{0x01, 0xff}, testKeys[:4]},
+		{"bounded", []byte{0x01}, []byte{0xff}, testKeys[2:6]},
+		{"between keys", []byte{0x01, 0x01}, []byte{0x03}, testKeys[4:6]},
+		{"empty", []byte{0x02}, []byte{0x02}, nil},
+	}
+	for _, r := range ranges {
+		checkKeys(t, r.name,
+			collect(t, db.IterateRange(testNamespace, r.start, r.end, false), testNamespace), r.expected)
+		checkKeys(t, r.name+" reverse",
+			collect(t, db.IterateRange(testNamespace, r.start, r.end, true), testNamespace), reversed(r.expected))
+	}
+}
+
+func testIteratorRawKeys(t *testing.T, db rollupdb.DB) {
+	fillTestKeys(t, db)
+	var expected [][]byte
+	for _, key := range testKeys[2:6] {
+		expected = append(expected, rawKey(testNamespace, key))
+	}
+	start, end := expected[0], expected[3]
+	checkKeys(t, "forward", collect(t, db.Iterator(start, end), nil), expected[:3])
+	// Reverse iteration includes start and excludes end
+	checkKeys(t, "reverse", collect(t, db.Iterator(end, start), nil), reversed(expected[1:]))
+}
+
+func testIteratorSnapshot(t *testing.T, db rollupdb.DB) {
+	fillTestKeys(t, db)
+	iter := db.IteratePrefix(testNamespace, nil)
+	defer iter.Close()
+	// Deleting while iterating does not change the iterated keys
+	var count int
+	for ; iter.Valid(); count++ {
+		key, err := iter.Key()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.Delete(testNamespace, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = iter.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if count != len(testKeys) {
+		t.Errorf("iterated %d keys, expected %d", count, len(testKeys))
+	}
+	checkKeys(t, "after delete", collect(t, db.IteratePrefix(testNamespace, nil), testNamespace), nil)
+}
+
+func testConcurrentAccess(t *testing.T, db rollupdb.DB) {
+	const (
+		numWorkers = 8
+		numKeys    = 100
+	)
+	var wg sync.WaitGroup
+	errs := make(chan error, numWorkers)
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			errs <- runWorker(db, w, numKeys)
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Each worker leaves its even keys
+	for w := 0; w < numWorkers; w++ {
+		keys := collect(t, db.IteratePrefix(testNamespace, []byte(fmt.Sprintf("%d/", w))), testNamespace)
+		if len(keys) != numKeys/2 {
+			t.Errorf("worker %d left %d keys, expected %d", w, len(keys), numKeys/2)
+		}
+	}
+}
+
+// runWorker writes the keys of a worker directly, in transactions and in bulks, reads them back and deletes
+// the odd ones
+func runWorker(db rollupdb.DB, w int, numKeys int) error {
+	key := func(i int) []byte {
+		return []byte(fmt.Sprintf("%d/%03d", w, i))
+	}
+	tx := db.NewTx()
+	bulk := db.NewBulk()
+	for i := 0; i < numKeys; i++ {
+		var err error
+		switch i % 3 {
+		case 0:
+			err = db.Set(testNamespace, key(i), rawKey(testNamespace, key(i)))
+		case 1:
+			err = tx.Set(testNamespace, key(i), rawKey(testNamespace, key(i)))
+		case 2:
+			err = bulk.Set(testNamespace, key(i), rawKey(testNamespace, key(i)))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if err := bulk.Flush(); err != nil {
+		return err
+	}
+	for i := 0; i < numKeys; i++ {
+		value, exists, err := db.Get(testNamespace, key(i))
+		if err != nil {
+			return err
+		}
+		if !exists || !bytes.Equal(value, rawKey(testNamespace, key(i))) {
+			return fmt.Errorf("worker %d read value %x for key %s", w, value, key(i))
+		}
+		if i%2 == 1 {
+			if err = db.Delete(testNamespace, key(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package leveldb
+
+import (
+	"errors"
+
+	rollupdb "github.com/celer-network/go-rollup/db"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// bulkWriteSize is the size of the batch above which a bulk writes it before the flush
+const bulkWriteSize = 1 << 20 // 1 MB
+
+// Bulk collects the operations in a batch that is written when it grows above bulkWriteSize and on flush
+type Bulk struct {
+	db        *DB
+	batch     *leveldb.Batch
+	size      int
+	isDiscard bool
+	isCommit  bool
+}
+
+func (bulk *Bulk) Set(namespace []byte, key []byte, value []byte) error {
+	key = rollupdb.PrependNamespace(namespace, key)
+	key = rollupdb.ConvNilToBytes(key)
+	value = rollupdb.ConvNilToBytes(value)
+
+	bulk.batch.Put(key, value)
+	bulk.size += len(key) + len(value)
+	return bulk.writeIfFull()
+}
+
+func (bulk *Bulk) Delete(namespace []byte, key []byte) error {
+	key = rollupdb.PrependNamespace(namespace, key)
+	key = rollupdb.ConvNilToBytes(key)
+
+	bulk.batch.Delete(key)
+	bulk.size += len(key)
+	return bulk.writeIfFull()
+}
+
+func (bulk *Bulk) writeIfFull() error {
+	if bulk.size < bulkWriteSize {
+		return nil
+	}
+	return bulk.write()
+}
+
+func (bulk *Bulk) write() error {
+	err := bulk.db.db.Write(bulk.batch, nil)
+	if err != nil {
+		return err
+	}
+	bulk.batch.Reset()
+	bulk.size = 0
+	return nil
+}
+
+func (bulk *Bulk) Flush() error {
+	if bulk.isDiscard {
+		return errors.New("Commit after dicard tx is not allowed")
+	} else if bulk.isCommit {
+		return errors.New("Commit occures two times")
+	}
+	bulk.isCommit = true
+
+	return bulk.write()
+}
+
+// DiscardLast discards the operations that have not been written yet
+func (bulk *Bulk) DiscardLast() {
+	bulk.isDiscard = true
+	bulk.batch.Reset()
+	bulk.size = 0
+}
@@ -0,0 +1,98 @@
+package leveldb
+
+import (
+	rollupdb "github.com/celer-network/go-rollup/db"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/filter"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+const (
+	// Same as go-ethereum, which is tuned for state tries with many small random writes
+	levelDbCacheSize   = 16 * opt.MiB
+	levelDbWriteBuffer = 16 * opt.MiB
+	levelDbOpenFiles   = 64
+)
+
+// NewDB creates new database or load existing database in the directory
+func NewDB(dir string) (*DB, error) {
+	db, err := leveldb.OpenFile(dir, &opt.Options{
+		BlockCacheCapacity:     levelDbCacheSize,
+		WriteBuffer:            levelDbWriteBuffer,
+		OpenFilesCacheCapacity: levelDbOpenFiles,
+		Filter:                 filter.NewBloomFilter(10),
+	})
+	if _, corrupted := err.(*errors.ErrCorrupted); corrupted {
+		db, err = leveldb.RecoverFile(dir, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &DB{db: db}, nil
+}
+
+// Enforce database and transaction implements interfaces
+var _ rollupdb.DB = (*DB)(nil)
+
+type DB struct {
+	db *leveldb.DB
+}
+
+func (db *DB) Type() string {
+	return "leveldb"
+}
+
+func (db *DB) Set(namespace []byte, key []byte, value []byte) error {
+	key = rollupdb.PrependNamespace(namespace, key)
+	key = rollupdb.ConvNilToBytes(key)
+	value = rollupdb.ConvNilToBytes(value)
+
+	return db.db.Put(key, value, nil)
+}
+
+func (db *DB) Delete(namespace []byte, key []byte) error {
+	key = rollupdb.PrependNamespace(namespace, key)
+	key = rollupdb.ConvNilToBytes(key)
+
+	return db.db.Delete(key, nil)
+}
+
+func (db *DB) Get(namespace []byte, key []byte) ([]byte, bool, error) {
+	key = rollupdb.PrependNamespace(namespace, key)
+	key = rollupdb.ConvNilToBytes(key)
+
+	value, err := db.db.Get(key, nil)
+	if err != nil {
+		if err == leveldb.ErrNotFound {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (db *DB) Exist(namespace []byte, key []byte) (bool, error) {
+	key = rollupdb.PrependNamespace(namespace, key)
+	key = rollupdb.ConvNilToBytes(key)
+
+	return db.db.Has(key, nil)
+}
+
+func (db *DB) Close() error {
+	return db.db.Close()
+}
+
+func (db *DB) NewTx() rollupdb.Transaction {
+	return &Transaction{
+		db:    db,
+		batch: new(leveldb.Batch),
+	}
+}
+
+func (db *DB) NewBulk() rollupdb.Bulk {
+	return &Bulk{
+		db:    db,
+		batch: new(leveldb.Batch),
+	}
+}
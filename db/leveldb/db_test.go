@@ -0,0 +1,29 @@
+package leveldb
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	rollupdb "github.com/celer-network/go-rollup/db"
+	"github.com/celer-network/go-rollup/db/dbtest"
+)
+
+func TestConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "leveldb-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dbtest.TestDB(t, func(t *testing.T) rollupdb.DB {
+		dbDir, err := ioutil.TempDir(dir, "db")
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := NewDB(dbDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+}
@@ -0,0 +1,90 @@
+package leveldb
+
+import (
+	"bytes"
+	"errors"
+
+	rollupdb "github.com/celer-network/go-rollup/db"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Iterator iterates over the implicit snapshot of the db taken when it is created
+type Iterator struct {
+	reverse   bool
+	prefixLen int
+	valid     bool
+	iter      iterator.Iterator
+}
+
+func (db *DB) Iterator(start []byte, end []byte) rollupdb.Iterator {
+	// if end is bigger then start, then reverse order
+	if bytes.Compare(start, end) == 1 {
+		// start is included, end is not. The keys after end and not after start are the keys from end followed
+		// by 0x00 to start followed by 0x00.
+		var lower []byte
+		if end != nil {
+			lower = append(append([]byte{}, end...), 0)
+		}
+		return db.newIterator(&util.Range{Start: lower, Limit: append(append([]byte{}, start...), 0)}, true, 0)
+	}
+	return db.newIterator(&util.Range{Start: start, Limit: end}, false, 0)
+}
+
+func (db *DB) IteratePrefix(namespace []byte, prefix []byte) rollupdb.Iterator {
+	return db.IterateRange(namespace, prefix, rollupdb.PrefixEnd(prefix), false)
+}
+
+func (db *DB) IterateRange(namespace []byte, start []byte, end []byte, reverse bool) rollupdb.Iterator {
+	rawStart, rawEnd, prefixLen := rollupdb.NamespaceRange(namespace, start, end)
+	return db.newIterator(&util.Range{Start: rawStart, Limit: rawEnd}, reverse, prefixLen)
+}
+
+func (db *DB) newIterator(r *util.Range, reverse bool, prefixLen int) *Iterator {
+	iter := &Iterator{
+		reverse:   reverse,
+		prefixLen: prefixLen,
+		iter:      db.db.NewIterator(r, nil),
+	}
+	if reverse {
+		iter.valid = iter.iter.Last()
+	} else {
+		iter.valid = iter.iter.First()
+	}
+	return iter
+}
+
+func (iter *Iterator) Next() error {
+	if !iter.Valid() {
+		return errors.New("Invalid iterator")
+	}
+	if iter.reverse {
+		iter.valid = iter.iter.Prev()
+	} else {
+		iter.valid = iter.iter.Next()
+	}
+	return iter.iter.Error()
+}
+
+func (iter *Iterator) Valid() bool {
+	return iter.valid
+}
+
+func (iter *Iterator) Key() ([]byte, error) {
+	if !iter.Valid() {
+		return nil, errors.New("Invalid iterator")
+	}
+	return append([]byte{}, iter.iter.Key()[iter.prefixLen:]...), nil
+}
+
+func (iter *Iterator) Value() ([]byte, error) {
+	if !iter.Valid() {
+		return nil, errors.New("Invalid iterator")
+	}
+	return append([]byte{}, iter.iter.Value()...), nil
+}
+
+func (iter *Iterator) Close() {
+	iter.valid = false
+	iter.iter.Release()
+}
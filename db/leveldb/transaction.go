@@ -0,0 +1,49 @@
+package leveldb
+
+import (
+	"errors"
+
+	rollupdb "github.com/celer-network/go-rollup/db"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// Transaction collects the operations in a batch that is written atomically on commit
+type Transaction struct {
+	db        *DB
+	batch     *leveldb.Batch
+	isDiscard bool
+	isCommit  bool
+}
+
+func (transaction *Transaction) Set(namespace []byte, key []byte, value []byte) error {
+	key = rollupdb.PrependNamespace(namespace, key)
+	key = rollupdb.ConvNilToBytes(key)
+	value = rollupdb.ConvNilToBytes(value)
+
+	transaction.batch.Put(key, value)
+	return nil
+}
+
+func (transaction *Transaction) Delete(namespace []byte, key []byte) error {
+	key = rollupdb.PrependNamespace(namespace, key)
+	key = rollupdb.ConvNilToBytes(key)
+
+	transaction.batch.Delete(key)
+	return nil
+}
+
+func (transaction *Transaction) Commit() error {
+	if transaction.isDiscard {
+		return errors.New("Commit after dicard tx is not allowed")
+	} else if transaction.isCommit {
+		return errors.New("Commit occures two times")
+	}
+	transaction.isCommit = true
+
+	return transaction.db.db.Write(transaction.batch, nil)
+}
+
+func (transaction *Transaction) Discard() {
+	transaction.isDiscard = true
+	transaction.batch.Reset()
+}
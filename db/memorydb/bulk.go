@@ -57,9 +57,9 @@ func (bulk *Bulk) Flush() error {
 	for e := bulk.opList.Front(); e != nil; e = e.Next() {
 		op := e.Value.(*txOp)
 		if op.isSet {
-			db.db[string(op.key)] = op.value
+			db.db.set(string(op.key), op.value)
 		} else {
-			delete(db.db, string(op.key))
+			db.db.delete(string(op.key))
 		}
 	}
 
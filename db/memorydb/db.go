@@ -8,14 +8,8 @@ import (
 )
 
 func NewDB() *DB {
-	var db map[string][]byte
-
-	if db == nil {
-		db = make(map[string][]byte)
-	}
-
 	database := &DB{
-		db: db,
+		db: newSkipList(),
 	}
 
 	return database
@@ -26,7 +20,7 @@ var _ rollupdb.DB = (*DB)(nil)
 
 type DB struct {
 	lock sync.Mutex
-	db   map[string][]byte
+	db   *skipList
 }
 
 func (db *DB) Type() string {
@@ -41,7 +35,7 @@ func (db *DB) Set(namespace []byte, key []byte, value []byte) error {
 	key = rollupdb.ConvNilToBytes(key)
 	value = rollupdb.ConvNilToBytes(value)
 
-	db.db[string(key)] = value
+	db.db.set(string(key), value)
 	return nil
 }
 
@@ -52,7 +46,7 @@ func (db *DB) Delete(namespace []byte, key []byte) error {
 	key = rollupdb.PrependNamespace(namespace, key)
 	key = rollupdb.ConvNilToBytes(key)
 
-	delete(db.db, string(key))
+	db.db.delete(string(key))
 	return nil
 }
 
@@ -63,7 +57,7 @@ func (db *DB) Get(namespace []byte, key []byte) ([]byte, bool, error) {
 	key = rollupdb.PrependNamespace(namespace, key)
 	key = rollupdb.ConvNilToBytes(key)
 
-	value, exists := db.db[string(key)]
+	value, exists := db.db.get(string(key))
 	return value, exists, nil
 }
 
@@ -74,7 +68,7 @@ func (db *DB) Exist(namespace []byte, key []byte) (bool, error) {
 	key = rollupdb.PrependNamespace(namespace, key)
 	key = rollupdb.ConvNilToBytes(key)
 
-	_, ok := db.db[string(key)]
+	_, ok := db.db.get(string(key))
 
 	return ok, nil
 }
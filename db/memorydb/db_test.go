@@ -0,0 +1,14 @@
+package memorydb
+
+import (
+	"testing"
+
+	rollupdb "github.com/celer-network/go-rollup/db"
+	"github.com/celer-network/go-rollup/db/dbtest"
+)
+
+func TestConformance(t *testing.T) {
+	dbtest.TestDB(t, func(t *testing.T) rollupdb.DB {
+		return NewDB()
+	})
+}
@@ -3,77 +3,73 @@ package memorydb
 import (
 	"bytes"
 	"errors"
-	"sort"
 
-	"github.com/celer-network/go-rollup/db"
+	rollupdb "github.com/celer-network/go-rollup/db"
 )
 
+// Iterator iterates over a snapshot of the keys and values of a range taken when it is created, so that
+// updating the db while iterating is safe
 type Iterator struct {
-	start     []byte
-	end       []byte
-	reverse   bool
-	keys      []string
+	keys      [][]byte
+	values    [][]byte
 	isInvalid bool
 	cursor    int
-	db        *DB
 }
 
-func isKeyInRange(key []byte, start []byte, end []byte, reverse bool) bool {
-	if reverse {
-		if start != nil && bytes.Compare(start, key) < 0 {
-			return false
-		}
-		if end != nil && bytes.Compare(key, end) <= 0 {
-			return false
-		}
-		return true
-	}
+func (db *DB) Iterator(start []byte, end []byte) rollupdb.Iterator {
+	db.lock.Lock()
+	defer db.lock.Unlock()
 
-	if bytes.Compare(key, start) < 0 {
-		return false
+	iter := &Iterator{}
+	// if end is bigger then start, then reverse order
+	if bytes.Compare(start, end) == 1 {
+		// start is included, end is not. The last key before start followed by 0x00 is the last key not
+		// bigger than start.
+		for x := db.db.seekBefore(append(append([]byte{}, start...), 0)); x != nil; x = x.prev {
+			if end != nil && x.key <= string(end) {
+				break
+			}
+			iter.add(x, 0)
+		}
+		return iter
 	}
-	if end != nil && bytes.Compare(end, key) <= 0 {
-		return false
+	for x := db.db.seek(string(start), nil); x != nil; x = x.next[0] {
+		if end != nil && x.key >= string(end) {
+			break
+		}
+		iter.add(x, 0)
 	}
-	return true
+	return iter
+}
 
+func (db *DB) IteratePrefix(namespace []byte, prefix []byte) rollupdb.Iterator {
+	return db.IterateRange(namespace, prefix, rollupdb.PrefixEnd(prefix), false)
 }
 
-func (db *DB) Iterator(start []byte, end []byte) db.Iterator {
+func (db *DB) IterateRange(namespace []byte, start []byte, end []byte, reverse bool) rollupdb.Iterator {
 	db.lock.Lock()
 	defer db.lock.Unlock()
 
-	var reverse bool
-
-	// if end is bigger then start, then reverse order
-	if bytes.Compare(start, end) == 1 {
-		reverse = true
-	} else {
-		reverse = false
-	}
-
-	var keys sort.StringSlice
-
-	for key := range db.db {
-		if isKeyInRange([]byte(key), start, end, reverse) {
-			keys = append(keys, key)
+	rawStart, rawEnd, prefixLen := rollupdb.NamespaceRange(namespace, start, end)
+	iter := &Iterator{}
+	if reverse {
+		for x := db.db.seekBefore(rawEnd); x != nil && x.key >= string(rawStart); x = x.prev {
+			iter.add(x, prefixLen)
 		}
+		return iter
 	}
-	if reverse {
-		sort.Sort(sort.Reverse(keys))
-	} else {
-		sort.Strings(keys)
+	for x := db.db.seek(string(rawStart), nil); x != nil; x = x.next[0] {
+		if rawEnd != nil && x.key >= string(rawEnd) {
+			break
+		}
+		iter.add(x, prefixLen)
 	}
+	return iter
+}
 
-	return &Iterator{
-		start:     start,
-		end:       end,
-		reverse:   reverse,
-		isInvalid: false,
-		keys:      keys,
-		cursor:    0,
-		db:        db,
-	}
+func (iter *Iterator) add(x *skipListNode, prefixLen int) {
+	iter.keys = append(iter.keys, []byte(x.key[prefixLen:]))
+	iter.values = append(iter.values, x.value)
 }
 
 func (iter *Iterator) Next() error {
@@ -99,7 +95,7 @@ func (iter *Iterator) Key() ([]byte, error) {
 		return nil, errors.New("Iterator is Invalid")
 	}
 
-	return []byte(iter.keys[iter.cursor]), nil
+	return iter.keys[iter.cursor], nil
 }
 
 func (iter *Iterator) Value() ([]byte, error) {
@@ -107,14 +103,11 @@ func (iter *Iterator) Value() ([]byte, error) {
 		return nil, errors.New("Iterator is Invalid")
 	}
 
-	key := []byte(iter.keys[iter.cursor])
+	return iter.values[iter.cursor], nil
+}
 
-	value, exists, err := iter.db.Get(nil, key)
-	if err != nil {
-		return nil, err
-	}
-	if !exists {
-		return nil, nil
-	}
-	return value, nil
+func (iter *Iterator) Close() {
+	iter.isInvalid = true
+	iter.keys = nil
+	iter.values = nil
 }
@@ -0,0 +1,126 @@
+package memorydb
+
+import (
+	"math/rand"
+)
+
+const (
+	skipListMaxLevel = 32
+	// Each level holds about 1/skipListBranching of the nodes of the level below
+	skipListBranching = 4
+)
+
+type skipListNode struct {
+	key   string
+	value []byte
+	next  []*skipListNode
+	// prev is the previous node at the lowest level, nil for the first node
+	prev *skipListNode
+}
+
+// skipList is an ordered map from keys to values. It is not safe for concurrent use.
+type skipList struct {
+	head   *skipListNode
+	level  int
+	length int
+	rand   *rand.Rand
+}
+
+func newSkipList() *skipList {
+	return &skipList{
+		head:  &skipListNode{next: make([]*skipListNode, skipListMaxLevel)},
+		level: 1,
+		rand:  rand.New(rand.NewSource(1)),
+	}
+}
+
+func (l *skipList) randomLevel() int {
+	level := 1
+	for level < skipListMaxLevel && l.rand.Intn(skipListBranching) == 0 {
+		level++
+	}
+	return level
+}
+
+// seek returns the first node with a key not smaller than key, or nil. If update is not nil, it is filled with
+// the last node before key at each level.
+func (l *skipList) seek(key string, update []*skipListNode) *skipListNode {
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].key < key {
+			x = x.next[i]
+		}
+		if update != nil {
+			update[i] = x
+		}
+	}
+	return x.next[0]
+}
+
+// seekBefore returns the last node with a key smaller than key, or the last node if key is nil
+func (l *skipList) seekBefore(key []byte) *skipListNode {
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && (key == nil || x.next[i].key < string(key)) {
+			x = x.next[i]
+		}
+	}
+	if x == l.head {
+		return nil
+	}
+	return x
+}
+
+func (l *skipList) get(key string) ([]byte, bool) {
+	x := l.seek(key, nil)
+	if x == nil || x.key != key {
+		return nil, false
+	}
+	return x.value, true
+}
+
+func (l *skipList) set(key string, value []byte) {
+	update := make([]*skipListNode, skipListMaxLevel)
+	x := l.seek(key, update)
+	if x != nil && x.key == key {
+		x.value = value
+		return
+	}
+	level := l.randomLevel()
+	for i := l.level; i < level; i++ {
+		update[i] = l.head
+	}
+	if level > l.level {
+		l.level = level
+	}
+	x = &skipListNode{key: key, value: value, next: make([]*skipListNode, level)}
+	for i := 0; i < level; i++ {
+		x.next[i] = update[i].next[i]
+		update[i].next[i] = x
+	}
+	if update[0] != l.head {
+		x.prev = update[0]
+	}
+	if x.next[0] != nil {
+		x.next[0].prev = x
+	}
+	l.length++
+}
+
+func (l *skipList) delete(key string) {
+	update := make([]*skipListNode, skipListMaxLevel)
+	x := l.seek(key, update)
+	if x == nil || x.key != key {
+		return
+	}
+	for i := 0; i < len(x.next); i++ {
+		update[i].next[i] = x.next[i]
+	}
+	if x.next[0] != nil {
+		x.next[0].prev = x.prev
+	}
+	for l.level > 1 && l.head.next[l.level-1] == nil {
+		l.level--
+	}
+	l.length--
+}
@@ -0,0 +1,55 @@
+package memorydb
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestSkipList(t *testing.T) {
+	l := newSkipList()
+	m := make(map[string][]byte)
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("%03d", r.Intn(1000))
+		if r.Intn(3) == 0 {
+			l.delete(key)
+			delete(m, key)
+		} else {
+			value := []byte(fmt.Sprint(i))
+			l.set(key, value)
+			m[key] = value
+		}
+	}
+	if l.length != len(m) {
+		t.Fatalf("length %d, expected %d", l.length, len(m))
+	}
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+		value, exists := l.get(key)
+		if !exists || string(value) != string(m[key]) {
+			t.Errorf("got value %s for key %s, expected %s", value, key, m[key])
+		}
+	}
+	sort.Strings(keys)
+
+	i := 0
+	for x := l.seek("", nil); x != nil; x = x.next[0] {
+		if x.key != keys[i] {
+			t.Fatalf("key %d is %s, expected %s", i, x.key, keys[i])
+		}
+		i++
+	}
+	i = len(keys) - 1
+	for x := l.seekBefore(nil); x != nil; x = x.prev {
+		if x.key != keys[i] {
+			t.Fatalf("key %d is %s, expected %s", i, x.key, keys[i])
+		}
+		i--
+	}
+	if i != -1 {
+		t.Errorf("reverse iteration stopped at %d", i)
+	}
+}
@@ -63,9 +63,9 @@ func (transaction *Transaction) Commit() error {
 	for e := transaction.opList.Front(); e != nil; e = e.Next() {
 		op := e.Value.(*txOp)
 		if op.isSet {
-			db.db[string(op.key)] = op.value
+			db.db.set(string(op.key), op.value)
 		} else {
-			delete(db.db, string(op.key))
+			db.db.delete(string(op.key))
 		}
 	}
 
@@ -1,5 +1,7 @@
 package db
 
+import "fmt"
+
 // DB is an general interface to access at storage data
 type DB interface {
 	Type() string
@@ -7,13 +9,22 @@ type DB interface {
 	Delete(namespace []byte, key []byte) error
 	Get(namespace []byte, key []byte) ([]byte, bool, error)
 	Exist(namespace []byte, key []byte) (bool, error)
+	// Iterator iterates the raw keys, including their namespace, from start to end. The order is reversed if
+	// start is bigger than end.
 	Iterator(start []byte, end []byte) Iterator
+	// IteratePrefix iterates the keys of namespace that start with prefix in ascending order. Keys are returned
+	// without the namespace.
+	IteratePrefix(namespace []byte, prefix []byte) Iterator
+	// IterateRange iterates the keys of namespace in [start, end), where a nil bound is open, in ascending or
+	// descending order. Keys are returned without the namespace.
+	IterateRange(namespace []byte, start []byte, end []byte, reverse bool) Iterator
 	NewTx() Transaction
 	NewBulk() Bulk
 	Close() error
 }
 
-// Transaction is used to batch multiple operations
+// Transaction is used to batch multiple operations. Commit writes them atomically, unless it returns a
+// *PartialCommitError.
 type Transaction interface {
 	Set(namespace []byte, key []byte, value []byte) error
 	Delete(namespace []byte, key []byte) error
@@ -21,6 +32,23 @@ type Transaction interface {
 	Discard()
 }
 
+// PartialCommitError is returned by Commit of a transaction that had to be committed in parts, when only the
+// first ones were committed. The operations of the committed parts are a prefix of the operations of the
+// transaction, in order.
+type PartialCommitError struct {
+	Committed int
+	Total     int
+	Err       error
+}
+
+func (err *PartialCommitError) Error() string {
+	return fmt.Sprintf("Committed %d of %d parts of the tx: %v", err.Committed, err.Total, err.Err)
+}
+
+func (err *PartialCommitError) Unwrap() error {
+	return err.Err
+}
+
 // Bulk is used to batch multiple transactions
 // This will internally commit transactions when reach maximum tx size
 type Bulk interface {
@@ -36,4 +64,6 @@ type Iterator interface {
 	Valid() bool
 	Key() ([]byte, error)
 	Value() ([]byte, error)
+	// Close releases the resources of the iterator
+	Close()
 }
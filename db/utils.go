@@ -10,6 +10,11 @@ var (
 	NamespaceLastKey                                      = []byte("lk")
 	NamespaceKeyToAccountInfo                             = []byte("ktai")
 	NamespaceRollupBlockNumber                            = []byte("rbn")
+	NamespaceFraudProof                                   = []byte("fp")
+	NamespaceBlockWitness                                 = []byte("bw")
+	NamespaceStateCheckpoint                              = []byte("sc")
+	NamespaceEventPosition                                = []byte("ep")
+	NamespaceDirtySlot                                    = []byte("ds")
 	EmptyKey                                              = []byte{}
 	Separator                                             = []byte("|")
 )
@@ -27,3 +32,34 @@ func ConvNilToBytes(byteArray []byte) []byte {
 	}
 	return byteArray
 }
+
+// PrefixEnd returns the smallest key that is bigger than every key starting with prefix, or nil if there is
+// no such key
+func PrefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for len(end) > 0 {
+		if end[len(end)-1] != 0xff {
+			end[len(end)-1]++
+			return end
+		}
+		end = end[:len(end)-1]
+	}
+	return nil
+}
+
+// NamespaceRange converts the range [start, end) of keys of namespace, where a nil bound is open, to a range
+// of raw keys. It also returns the length of the namespace prefix to strip from the raw keys.
+func NamespaceRange(namespace []byte, start []byte, end []byte) ([]byte, []byte, int) {
+	var prefix []byte
+	if namespace != nil {
+		prefix = append(append([]byte{}, namespace...), Separator...)
+	}
+	rawStart := append(append([]byte{}, prefix...), start...)
+	var rawEnd []byte
+	if end != nil {
+		rawEnd = append(append([]byte{}, prefix...), end...)
+	} else {
+		rawEnd = PrefixEnd(prefix)
+	}
+	return rawStart, rawEnd, len(prefix)
+}
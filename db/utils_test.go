@@ -0,0 +1,25 @@
+package db_test
+
+import (
+	"bytes"
+	"testing"
+
+	rollupdb "github.com/celer-network/go-rollup/db"
+)
+
+func TestPrefixEnd(t *testing.T) {
+	ends := []struct {
+		prefix []byte
+		end    []byte
+	}{
+		{nil, nil},
+		{[]byte{0x01}, []byte{0x02}},
+		{[]byte{0x01, 0xff}, []byte{0x02}},
+		{[]byte{0xff, 0xff}, nil},
+	}
+	for _, e := range ends {
+		if end := rollupdb.PrefixEnd(e.prefix); !bytes.Equal(end, e.end) || (end == nil) != (e.end == nil) {
+			t.Errorf("prefix end %x of %x, expected %x", end, e.prefix, e.end)
+		}
+	}
+}
@@ -19,7 +19,7 @@ require (
 	github.com/golang/protobuf v1.4.0
 	github.com/google/uuid v1.1.1 // indirect
 	github.com/gorilla/websocket v1.4.2 // indirect
-	github.com/hashicorp/golang-lru v0.5.4 // indirect
+	github.com/hashicorp/golang-lru v0.5.4
 	github.com/hashicorp/hcl v1.0.1-0.20180906183839-65a6292f0157 // indirect
 	github.com/huin/goupnp v1.0.0 // indirect
 	github.com/mattn/go-colorable v0.1.6
@@ -40,6 +40,7 @@ require (
 	github.com/spf13/viper v1.6.3
 	github.com/status-im/keycard-go v0.0.0-20200402102358-957c09536969 // indirect
 	github.com/stretchr/testify v1.5.1
+	github.com/syndtr/goleveldb v1.0.1-0.20190923125748-758128399b1d
 	golang.org/x/crypto v0.0.0-20200414173820-0848c9571904
 	google.golang.org/grpc v1.27.1
 	gopkg.in/yaml.v2 v2.2.8
@@ -0,0 +1,140 @@
+package smt
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+
+	rollupdb "github.com/celer-network/go-rollup/db"
+)
+
+type batchEntry struct {
+	// path masked to the bits that select the leaf, see isLeft
+	path  *big.Int
+	value []byte
+}
+
+type batchWriter struct {
+	bulk   rollupdb.Bulk
+	hashes [][]byte
+	values [][]byte
+}
+
+// UpdateBatch sets new values for several keys, returns the new root, and sets the new current root of the tree.
+// The result is the same as updating the keys one after the other, with the last value winning for repeated keys.
+func (smt *SparseMerkleTree) UpdateBatch(keys [][]byte, values [][]byte) ([]byte, error) {
+	smt.writeLock.Lock()
+	defer smt.writeLock.Unlock()
+	newRoot, err := smt.updateBatchForRoot(keys, values, smt.Root())
+	if err == nil {
+		smt.SetRoot(newRoot)
+	}
+	return newRoot, err
+}
+
+// UpdateBatchForRoot sets new values for several keys in the tree at a specific root, and returns the new root.
+// Keys are sorted by path so that every node on the shared part of their paths is read and hashed only once,
+// and all nodes are written in a single bulk.
+func (smt *SparseMerkleTree) UpdateBatchForRoot(keys [][]byte, values [][]byte, root []byte) ([]byte, error) {
+	smt.writeLock.Lock()
+	defer smt.writeLock.Unlock()
+	return smt.updateBatchForRoot(keys, values, root)
+}
+
+func (smt *SparseMerkleTree) updateBatchForRoot(keys [][]byte, values [][]byte, root []byte) ([]byte, error) {
+	if len(keys) != len(values) {
+		return nil, errors.New("Number of keys and values differ")
+	}
+	if len(keys) == 0 {
+		return root, nil
+	}
+	mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(smt.height-1)), big.NewInt(1))
+	entries := make([]*batchEntry, len(keys))
+	for i, key := range keys {
+		path, err := smt.getPath(key)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = &batchEntry{
+			path:  new(big.Int).And(new(big.Int).SetBytes(path), mask),
+			value: values[i],
+		}
+	}
+	return smt.updateEntriesForRoot(entries, root)
+}
+
+// updateEntriesForRoot sets new values for leaves given by their masked paths.
+func (smt *SparseMerkleTree) updateEntriesForRoot(entries []*batchEntry, root []byte) ([]byte, error) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].path.Cmp(entries[j].path) < 0
+	})
+	// Keep the last value of repeated keys
+	deduplicated := entries[:0]
+	for i, entry := range entries {
+		if i+1 < len(entries) && entries[i+1].path.Cmp(entry.path) == 0 {
+			continue
+		}
+		deduplicated = append(deduplicated, entry)
+	}
+
+	writer := &batchWriter{bulk: smt.db.NewBulk()}
+	newRoot, err := smt.updateSubtree(writer, root, 0, deduplicated)
+	if err != nil {
+		return nil, err
+	}
+	err = writer.bulk.Flush()
+	if err != nil {
+		return nil, err
+	}
+	smt.cacheNodes(writer.hashes, writer.values)
+	return newRoot, nil
+}
+
+// updateSubtree updates the entries below the node at depth and returns the new hash of the node.
+// All entries share the path to the node.
+func (smt *SparseMerkleTree) updateSubtree(
+	writer *batchWriter, nodeHash []byte, depth int, entries []*batchEntry) ([]byte, error) {
+	if depth == smt.height-1 {
+		// Copy the leaf, so that the caller cannot change the cached value
+		value := append([]byte{}, entries[0].value...)
+		return smt.writeNode(writer, value)
+	}
+	nodeValue, err := smt.getNode(nodeHash)
+	if err != nil {
+		return nil, err
+	}
+	leftHash := nodeValue[:smt.keySize()]
+	rightHash := nodeValue[smt.keySize():]
+	bit := smt.height - 2 - depth
+	// Entries are sorted, so the ones going right follow the ones going left
+	split := sort.Search(len(entries), func(i int) bool {
+		return entries[i].path.Bit(bit) == 1
+	})
+	if split > 0 {
+		leftHash, err = smt.updateSubtree(writer, leftHash, depth+1, entries[:split])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if split < len(entries) {
+		rightHash, err = smt.updateSubtree(writer, rightHash, depth+1, entries[split:])
+		if err != nil {
+			return nil, err
+		}
+	}
+	value := make([]byte, 0, 2*smt.keySize())
+	value = append(value, leftHash...)
+	value = append(value, rightHash...)
+	return smt.writeNode(writer, value)
+}
+
+func (smt *SparseMerkleTree) writeNode(writer *batchWriter, value []byte) ([]byte, error) {
+	hash := smt.digest(value)
+	err := writer.bulk.Set(smt.dbNamespace, hash, value)
+	if err != nil {
+		return nil, err
+	}
+	writer.hashes = append(writer.hashes, hash)
+	writer.values = append(writer.values, value)
+	return hash, nil
+}
@@ -0,0 +1,115 @@
+package smt
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/celer-network/go-rollup/db/memorydb"
+)
+
+func testUpdateBatch(t *testing.T, hashName string, height int, hashKey bool, keys [][]byte) {
+	values := make([][]byte, len(keys))
+	for i := range keys {
+		values[i] = []byte{byte(i), 'v'}
+	}
+	smt, err := NewSparseMerkleTree(memorydb.NewDB(), namespaceTestTrie, hashName, nil, height, hashKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batchSmt, err := NewSparseMerkleTree(memorydb.NewDB(), namespaceTestTrie, hashName, nil, height, hashKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Start from a non-empty tree
+	for _, s := range []*SparseMerkleTree{smt, batchSmt} {
+		_, err = s.Update(keys[0], []byte("initial"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, key := range keys {
+		_, err = smt.Update(key, values[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	batchRoot, err := batchSmt.UpdateBatch(keys, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(smt.Root(), batchRoot) || !bytes.Equal(batchRoot, batchSmt.Root()) {
+		t.Fatalf("batch root differs from sequential root, height %d, %d keys", height, len(keys))
+	}
+	for _, key := range keys {
+		value, err := smt.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		batchValue, err := batchSmt.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(value, batchValue) {
+			t.Errorf("batch value differs from sequential value")
+		}
+		proof, err := batchSmt.Prove(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !batchSmt.VerifyProof(proof, key, batchValue) {
+			t.Errorf("proof after batch update failed to verify")
+		}
+	}
+}
+
+func TestUpdateBatch(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for height := 1; height <= 8; height++ {
+		for numKeys := 1; numKeys <= 20; numKeys++ {
+			keys := make([][]byte, numKeys)
+			for i := range keys {
+				// Repeated keys are likely in small trees
+				keys[i] = big.NewInt(random.Int63n(1 << uint(height-1))).Bytes()
+			}
+			testUpdateBatch(t, HashKeccak256, height, false, keys)
+		}
+	}
+	for _, numKeys := range []int{1, 2, 10, 100} {
+		keys := make([][]byte, numKeys)
+		for i := range keys {
+			keys[i] = big.NewInt(random.Int63()).Bytes()
+		}
+		testUpdateBatch(t, HashKeccak256, 160, false, keys)
+		testUpdateBatch(t, HashSHA256, 256, true, keys)
+	}
+}
+
+func TestUpdateBatchMismatchedLengths(t *testing.T) {
+	smt, err := NewSparseMerkleTree(memorydb.NewDB(), namespaceTestTrie, HashKeccak256, nil, 8, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = smt.UpdateBatch([][]byte{{1}, {2}}, [][]byte{[]byte("value")})
+	if err == nil {
+		t.Error("accepted different numbers of keys and values")
+	}
+}
+
+func BenchmarkUpdateBatch(b *testing.B) {
+	benchmarkTrees(b, func(b *testing.B, smt *SparseMerkleTree) {
+		keys := make([][]byte, 100)
+		values := make([][]byte, 100)
+		for i := 0; i < b.N; i++ {
+			for j := range keys {
+				keys[j] = big.NewInt(int64((i*100 + j) % 1000)).Bytes()
+				values[j] = big.NewInt(int64(i)).Bytes()
+			}
+			_, err := smt.UpdateBatch(keys, values)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
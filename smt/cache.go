@@ -0,0 +1,64 @@
+package smt
+
+import (
+	"errors"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Option configures a SparseMerkleTree.
+type Option func(*SparseMerkleTree) error
+
+// WithCacheSize enables an LRU cache of up to size nodes in front of the DB.
+// Nodes are keyed by their hash, so cached nodes never go stale when the root changes. Nodes are only cached
+// after they have been written to the DB successfully.
+func WithCacheSize(size int) Option {
+	return func(smt *SparseMerkleTree) error {
+		if size <= 0 {
+			return errors.New("Cache size must be positive")
+		}
+		cache, err := lru.New(size)
+		if err != nil {
+			return err
+		}
+		smt.nodeCache = cache
+		return nil
+	}
+}
+
+// getNode gets the value of a node by its hash, from the cache if possible.
+func (smt *SparseMerkleTree) getNode(hash []byte) ([]byte, error) {
+	if smt.nodeCache != nil {
+		if value, ok := smt.nodeCache.Get(string(hash)); ok {
+			return value.([]byte), nil
+		}
+	}
+	value, exists, err := smt.db.Get(smt.dbNamespace, hash)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("Corrupt db")
+	}
+	if smt.nodeCache != nil {
+		smt.nodeCache.Add(string(hash), value)
+	}
+	return value, nil
+}
+
+// cacheNodes adds nodes that have been written to the DB to the cache.
+func (smt *SparseMerkleTree) cacheNodes(hashes [][]byte, values [][]byte) {
+	if smt.nodeCache == nil {
+		return
+	}
+	for i, hash := range hashes {
+		smt.nodeCache.Add(string(hash), values[i])
+	}
+}
+
+// uncacheNode removes a node that has been deleted from the DB from the cache.
+func (smt *SparseMerkleTree) uncacheNode(hash []byte) {
+	if smt.nodeCache != nil {
+		smt.nodeCache.Remove(string(hash))
+	}
+}
@@ -0,0 +1,183 @@
+package smt
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	rollupdb "github.com/celer-network/go-rollup/db"
+	"github.com/celer-network/go-rollup/db/badgerdb"
+	"github.com/celer-network/go-rollup/db/memorydb"
+)
+
+const benchmarkCacheSize = 1 << 16
+
+func TestCachedSparseMerkleTree(t *testing.T) {
+	smt, err := NewSparseMerkleTree(memorydb.NewDB(), namespaceTestTrie, HashKeccak256, nil, 160, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cachedSmt, err := NewSparseMerkleTree(
+		memorydb.NewDB(), namespaceTestTrie, HashKeccak256, nil, 160, false, WithCacheSize(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var roots [][]byte
+	for i := 0; i < 50; i++ {
+		key := big.NewInt(int64(i % 20)).Bytes()
+		value := []byte{byte(i), byte(i >> 8)}
+		root, err := smt.Update(key, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cachedRoot, err := cachedSmt.Update(key, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(root, cachedRoot) {
+			t.Fatalf("roots differ after update %d", i)
+		}
+		roots = append(roots, root)
+	}
+
+	// Reading at old roots must not be affected by cached nodes of newer roots
+	for i, root := range roots {
+		for j := 0; j < 20; j++ {
+			key := big.NewInt(int64(j)).Bytes()
+			value, err := smt.GetForRoot(key, root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cachedValue, err := cachedSmt.GetForRoot(key, root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(value, cachedValue) {
+				t.Errorf("values of key %d differ at root %d", j, i)
+			}
+			proof, err := smt.ProveForRoot(key, root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cachedProof, err := cachedSmt.ProveForRoot(key, root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k := range proof {
+				if !bytes.Equal(proof[k], cachedProof[k]) {
+					t.Errorf("proofs of key %d differ at root %d", j, i)
+					break
+				}
+			}
+		}
+	}
+	if cachedSmt.nodeCache.Len() > 64 {
+		t.Error("cache grew beyond its size")
+	}
+}
+
+func TestCachedLeafIsCopied(t *testing.T) {
+	smt, err := NewSparseMerkleTree(
+		memorydb.NewDB(), namespaceTestTrie, HashKeccak256, nil, 8, false, WithCacheSize(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := []byte("testValue")
+	_, err = smt.Update([]byte{1}, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value[0] = 'x'
+	cachedValue, err := smt.Get([]byte{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cachedValue, []byte("testValue")) {
+		t.Error("cached leaf changed with the caller's slice")
+	}
+}
+
+func TestWithCacheSizeInvalid(t *testing.T) {
+	_, err := NewSparseMerkleTree(
+		memorydb.NewDB(), namespaceTestTrie, HashKeccak256, nil, 8, false, WithCacheSize(0))
+	if err == nil {
+		t.Error("accepted a cache size of 0")
+	}
+}
+
+func newBenchmarkBadgerDB(b *testing.B) (rollupdb.DB, func()) {
+	dir, err := ioutil.TempDir("", "smt-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	db, err := badgerdb.NewDB(dir)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func benchmarkTrees(b *testing.B, run func(b *testing.B, smt *SparseMerkleTree)) {
+	backends := []struct {
+		name  string
+		newDB func(b *testing.B) (rollupdb.DB, func())
+	}{
+		{"memorydb", func(b *testing.B) (rollupdb.DB, func()) { return memorydb.NewDB(), func() {} }},
+		{"badgerdb", newBenchmarkBadgerDB},
+	}
+	for _, backend := range backends {
+		for _, cached := range []bool{false, true} {
+			name := backend.name + "/uncached"
+			var options []Option
+			if cached {
+				name = backend.name + "/cached"
+				options = append(options, WithCacheSize(benchmarkCacheSize))
+			}
+			b.Run(name, func(b *testing.B) {
+				db, cleanup := backend.newDB(b)
+				defer cleanup()
+				smt, err := NewSparseMerkleTree(
+					db, namespaceTestTrie, HashKeccak256, nil, 160, false, options...)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for i := 0; i < 1000; i++ {
+					_, err := smt.Update(big.NewInt(int64(i)).Bytes(), []byte("benchmarkValue"))
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ResetTimer()
+				run(b, smt)
+			})
+		}
+	}
+}
+
+func BenchmarkUpdate(b *testing.B) {
+	benchmarkTrees(b, func(b *testing.B, smt *SparseMerkleTree) {
+		for i := 0; i < b.N; i++ {
+			_, err := smt.Update(big.NewInt(int64(i%1000)).Bytes(), big.NewInt(int64(i)).Bytes())
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkProve(b *testing.B) {
+	benchmarkTrees(b, func(b *testing.B, smt *SparseMerkleTree) {
+		for i := 0; i < b.N; i++ {
+			_, err := smt.Prove(big.NewInt(int64(i % 1000)).Bytes())
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
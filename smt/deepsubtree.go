@@ -1,7 +1,8 @@
 package smt
 
 import (
-	"hash"
+	"errors"
+	"sync"
 
 	rollupdb "github.com/celer-network/go-rollup/db"
 )
@@ -12,24 +13,55 @@ type DeepSparseMerkleSubTree struct {
 }
 
 // NewDeepSparseMerkleSubTree creates a new deep Sparse Merkle subtree on an empty DB.
-func NewDeepSparseMerkleSubTree(db rollupdb.DB, hasher hash.Hash, height int, hashKey bool) *DeepSparseMerkleSubTree {
+func NewDeepSparseMerkleSubTree(
+	db rollupdb.DB, dbNamespace []byte, hashName string, height int, hashKey bool) (*DeepSparseMerkleSubTree, error) {
+	newHasher, err := GetHash(hashName)
+	if err != nil {
+		return nil, err
+	}
+	hasher := newHasher()
 	smt := &SparseMerkleTree{
-		hasher:  hasher,
-		db:      db,
-		height:  height,
-		hashKey: hashKey,
+		hashName:     hashName,
+		hasherPool:   sync.Pool{New: func() interface{} { return newHasher() }},
+		hasherSize:   hasher.Size(),
+		defaultNodes: defaultNodes(hasher),
+		db:           db,
+		dbNamespace:  dbNamespace,
+		height:       height,
+		hashKey:      hashKey,
 	}
 
-	return &DeepSparseMerkleSubTree{SparseMerkleTree: smt}
+	return &DeepSparseMerkleSubTree{SparseMerkleTree: smt}, nil
 }
 
-// AddBranches adds new branches to the tree.
-// These branches are generated by smt.ProveForRoot, and should be verified by VerifyProof first.
+// AddBranches adds the branches of keys to the tree.
+// These branches are generated by smt.ProveMultiForRoot, and should be verified by VerifyMultiProof first. For a
+// single key, this is the proof generated by smt.ProveForRoot in reverse order.
 // Set updateRoot to true if the current root of the tree should be updated.
-func (dsmst *DeepSparseMerkleSubTree) AddBranches(proof [][]byte, key []byte, value []byte, updateRoot bool) ([]byte, error) {
-	newRoot, err := dsmst.updateWithSideNodes(dsmst.digest(key), value, reverseProof(proof))
-	if err == nil && updateRoot {
+func (dsmst *DeepSparseMerkleSubTree) AddBranches(
+	proof [][]byte, keys [][]byte, values [][]byte, updateRoot bool) ([]byte, error) {
+	hasher := dsmst.getHasher()
+	defer dsmst.putHasher(hasher)
+	entries, err := multiProofEntries(keys, values, hasher, dsmst.height, dsmst.hashKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("No keys to add")
+	}
+	writer := &batchWriter{bulk: dsmst.db.NewBulk()}
+	newRoot, err := rootFromMultiProof(
+		proof, entries, &multiProofHasher{hasher: hasher, height: dsmst.height, writer: writer, smt: dsmst.SparseMerkleTree})
+	if err != nil {
+		return nil, err
+	}
+	err = writer.bulk.Flush()
+	if err != nil {
+		return nil, err
+	}
+	dsmst.cacheNodes(writer.hashes, writer.values)
+	if updateRoot {
 		dsmst.SetRoot(newRoot)
 	}
-	return newRoot, err
+	return newRoot, nil
 }
@@ -5,14 +5,16 @@ import (
 	"testing"
 
 	"github.com/celer-network/go-rollup/db/memorydb"
-	"github.com/minio/sha256-simd"
 )
 
 func TestDeepSparseMerkleSubTree(t *testing.T) {
 	dsmstDb := memorydb.NewDB()
-	dsmst := NewDeepSparseMerkleSubTree(dsmstDb, sha256.New(), 256, true)
+	dsmst, err := NewDeepSparseMerkleSubTree(dsmstDb, namespaceTestTrie, HashSHA256, 256, true)
+	if err != nil {
+		t.Fatal(err)
+	}
 	smtDb := memorydb.NewDB()
-	smt, err := NewSparseMerkleTree(smtDb, namespaceTestTrie, sha256.New(), nil, 256, true)
+	smt, err := NewSparseMerkleTree(smtDb, namespaceTestTrie, HashSHA256, nil, 256, true)
 	if err != nil {
 		t.Error(err)
 	}
@@ -22,10 +24,10 @@ func TestDeepSparseMerkleSubTree(t *testing.T) {
 	smt.Update([]byte("testKey3"), []byte("testValue3"))
 	smt.Update([]byte("testKey4"), []byte("testValue4"))
 
-	proof1, _ := smt.Prove([]byte("testKey1"))
-	proof2, _ := smt.Prove([]byte("testKey2"))
-	dsmst.AddBranches(proof1, []byte("testKey1"), []byte("testValue1"), true)
-	dsmst.AddBranches(proof2, []byte("testKey2"), []byte("testValue2"), true)
+	proof1, _ := smt.ProveMulti([][]byte{[]byte("testKey1")})
+	proof2, _ := smt.ProveMulti([][]byte{[]byte("testKey2")})
+	dsmst.AddBranches(proof1, [][]byte{[]byte("testKey1")}, [][]byte{[]byte("testValue1")}, true)
+	dsmst.AddBranches(proof2, [][]byte{[]byte("testKey2")}, [][]byte{[]byte("testValue2")}, true)
 
 	value, err := dsmst.Get([]byte("testKey1"))
 	if err != nil {
@@ -2,34 +2,48 @@ package smt
 
 import (
 	"hash"
+	"sync"
 )
 
-var defaultNodesMap map[hash.Hash][][]byte
+var (
+	// Default nodes by hasher fingerprint, so that all instances of a hash function share them
+	defaultNodesMap  map[string][][]byte
+	defaultNodesLock sync.RWMutex
+)
 
 func init() {
-	defaultNodesMap = make(map[hash.Hash][][]byte)
+	defaultNodesMap = make(map[string][][]byte)
 }
 
+// defaultNodes returns the default nodes of a full tree for the hash function of hasher, from the root down to
+// the leaf. The returned slice is shared and must not be modified.
 func defaultNodes(hasher hash.Hash) [][]byte {
-	nodes, ok := defaultNodesMap[hasher]
-
-	if !ok {
-		depth := hasher.Size() * 8
-		defaultNodesMap[hasher] = make([][]byte, depth)
-		nodes = defaultNodesMap[hasher]
+	hasher.Write(DefaultValue)
+	bottom := hasher.Sum(nil)
+	hasher.Reset()
+	fingerprint := string(bottom)
+
+	defaultNodesLock.RLock()
+	nodes, ok := defaultNodesMap[fingerprint]
+	defaultNodesLock.RUnlock()
+	if ok {
+		return nodes
+	}
 
-		hasher.Write(DefaultValue)
-		bottom := hasher.Sum(nil)
+	depth := hasher.Size() * 8
+	nodes = make([][]byte, depth)
+	nodes[depth-1] = bottom
+	for i := depth - 1; i > 0; i-- {
+		hasher.Write(append(nodes[i], nodes[i]...))
+		nodes[i-1] = hasher.Sum(nil)
 		hasher.Reset()
-
-		nodes[depth-1] = bottom
-
-		for i := depth - 1; i > 0; i-- {
-			hasher.Write(append(nodes[i], nodes[i]...))
-			nodes[i-1] = hasher.Sum(nil)
-			hasher.Reset()
-		}
 	}
 
+	defaultNodesLock.Lock()
+	defer defaultNodesLock.Unlock()
+	if existing, ok := defaultNodesMap[fingerprint]; ok {
+		return existing
+	}
+	defaultNodesMap[fingerprint] = nodes
 	return nodes
 }
@@ -0,0 +1,55 @@
+package smt
+
+import (
+	"bytes"
+	"math/big"
+)
+
+// DiffEntry is a key whose value differs between two roots. A missing value is DefaultValue.
+type DiffEntry struct {
+	// Key is a path, like the keys of LeafIterator
+	Key    []byte
+	ValueA []byte
+	ValueB []byte
+}
+
+// Diff returns the keys whose values differ between rootA and rootB, in path order. Both roots are walked at
+// once, and subtrees with the same hash under both roots are skipped, so the cost depends on the size of the
+// difference rather than the size of the tree.
+func (smt *SparseMerkleTree) Diff(rootA []byte, rootB []byte) ([]*DiffEntry, error) {
+	var entries []*DiffEntry
+	err := smt.diffSubtrees(&entries, rootA, rootB, 0, new(big.Int))
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (smt *SparseMerkleTree) diffSubtrees(
+	entries *[]*DiffEntry, hashA []byte, hashB []byte, depth int, path *big.Int) error {
+	if bytes.Equal(hashA, hashB) {
+		return nil
+	}
+	valueA, err := smt.getNode(hashA)
+	if err != nil {
+		return err
+	}
+	valueB, err := smt.getNode(hashB)
+	if err != nil {
+		return err
+	}
+	if depth == smt.height-1 {
+		*entries = append(*entries, &DiffEntry{
+			Key:    smt.pathBytes(path),
+			ValueA: valueA,
+			ValueB: valueB,
+		})
+		return nil
+	}
+	err = smt.diffSubtrees(entries, valueA[:smt.keySize()], valueB[:smt.keySize()], depth+1, path)
+	if err != nil {
+		return err
+	}
+	rightPath := new(big.Int).SetBit(path, smt.height-2-depth, 1)
+	return smt.diffSubtrees(entries, valueA[smt.keySize():], valueB[smt.keySize():], depth+1, rightPath)
+}
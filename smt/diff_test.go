@@ -0,0 +1,80 @@
+package smt
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/celer-network/go-rollup/db/memorydb"
+)
+
+func TestDiff(t *testing.T) {
+	smt, err := NewSparseMerkleTree(memorydb.NewDB(), namespaceTestTrie, HashKeccak256, nil, 160, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emptyRoot := smt.Root()
+	for i := int64(0); i < 20; i++ {
+		_, err = smt.Update(big.NewInt(i).Bytes(), []byte{byte(i + 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	rootA := smt.Root()
+
+	entries, err := smt.Diff(rootA, rootA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Error("diff of a root with itself is not empty")
+	}
+
+	// Change one key, add one and remove one
+	_, err = smt.UpdateBatch(
+		[][]byte{big.NewInt(3).Bytes(), big.NewInt(100).Bytes(), big.NewInt(7).Bytes()},
+		[][]byte{{42}, {101}, DefaultValue})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootB := smt.Root()
+	entries, err = smt.Diff(rootA, rootB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		slot   int64
+		valueA []byte
+		valueB []byte
+	}{
+		{3, []byte{4}, []byte{42}},
+		{7, []byte{8}, DefaultValue},
+		{100, DefaultValue, []byte{101}},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("diff has %d entries instead of %d", len(entries), len(expected))
+	}
+	for i, e := range expected {
+		if new(big.Int).SetBytes(entries[i].Key).Int64() != e.slot {
+			t.Errorf("diff entry %d has key %x instead of slot %d", i, entries[i].Key, e.slot)
+		}
+		if !bytes.Equal(entries[i].ValueA, e.valueA) || !bytes.Equal(entries[i].ValueB, e.valueB) {
+			t.Errorf("diff entry %d has wrong values", i)
+		}
+	}
+
+	// Against the empty tree, the diff lists every leaf
+	entries, err = smt.Diff(emptyRoot, rootB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, _ := collectLeaves(t, smt, rootB, nil, nil)
+	if len(entries) != len(keys) {
+		t.Fatalf("diff against empty tree has %d entries instead of %d", len(entries), len(keys))
+	}
+	for i := range keys {
+		if !bytes.Equal(entries[i].Key, keys[i]) || !bytes.Equal(entries[i].ValueA, DefaultValue) {
+			t.Error("diff against empty tree differs from leaves")
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package smt
+
+import (
+	"fmt"
+	"hash"
+	"sort"
+	"sync"
+
+	"github.com/minio/sha256-simd"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
+// Names of the built-in hash functions
+const (
+	HashKeccak256  = "keccak256"
+	HashSHA256     = "sha256"
+	HashBlake2b256 = "blake2b256"
+)
+
+var (
+	hashFunctions     = make(map[string]func() hash.Hash)
+	hashFunctionsLock sync.RWMutex
+)
+
+func init() {
+	RegisterHash(HashKeccak256, sha3.NewLegacyKeccak256)
+	RegisterHash(HashSHA256, sha256.New)
+	RegisterHash(HashBlake2b256, func() hash.Hash {
+		// Only fails for keys longer than 64 bytes
+		hasher, _ := blake2b.New256(nil)
+		return hasher
+	})
+}
+
+// RegisterHash makes a hash function available to trees by name. The name is recorded in the DB of every tree
+// created with it, so it must not change once trees have been created.
+func RegisterHash(name string, newHasher func() hash.Hash) {
+	hashFunctionsLock.Lock()
+	defer hashFunctionsLock.Unlock()
+	if _, exists := hashFunctions[name]; exists {
+		panic(fmt.Sprintf("Hash %s registered twice", name))
+	}
+	hashFunctions[name] = newHasher
+}
+
+// GetHash returns the constructor of a registered hash function.
+func GetHash(name string) (func() hash.Hash, error) {
+	hashFunctionsLock.RLock()
+	defer hashFunctionsLock.RUnlock()
+	newHasher, ok := hashFunctions[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown hash %s", name)
+	}
+	return newHasher, nil
+}
+
+// HashNames returns the names of all registered hash functions, sorted.
+func HashNames() []string {
+	hashFunctionsLock.RLock()
+	defer hashFunctionsLock.RUnlock()
+	names := make([]string, 0, len(hashFunctions))
+	for name := range hashFunctions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,139 @@
+package smt
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/celer-network/go-rollup/db/memorydb"
+)
+
+func TestHashes(t *testing.T) {
+	for _, name := range []string{HashKeccak256, HashSHA256, HashBlake2b256} {
+		newHasher, err := GetHash(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if newHasher().Size() != 32 {
+			t.Errorf("%s: unexpected hash size %d", name, newHasher().Size())
+		}
+		smt, err := NewSparseMerkleTree(memorydb.NewDB(), namespaceTestTrie, name, nil, 160, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if smt.HashName() != name {
+			t.Errorf("%s: tree has hash %s", name, smt.HashName())
+		}
+		_, err = smt.Update([]byte("testKey"), []byte("testValue"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		proof, err := smt.Prove([]byte("testKey"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !VerifyProof(proof, smt.Root(), []byte("testKey"), []byte("testValue"), newHasher(), 160, false) {
+			t.Errorf("%s: valid proof failed to verify", name)
+		}
+	}
+	_, err := GetHash("md5")
+	if err == nil {
+		t.Error("GetHash did not return error on unknown hash")
+	}
+	_, err = NewSparseMerkleTree(memorydb.NewDB(), namespaceTestTrie, "md5", nil, 160, false)
+	if err == nil {
+		t.Error("NewSparseMerkleTree did not return error on unknown hash")
+	}
+}
+
+func TestReopenWithWrongHash(t *testing.T) {
+	db := memorydb.NewDB()
+	smt, err := NewSparseMerkleTree(db, namespaceTestTrie, HashKeccak256, nil, 160, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := smt.Update([]byte("testKey"), []byte("testValue"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewSparseMerkleTree(db, namespaceTestTrie, HashSHA256, root, 160, false)
+	if err == nil {
+		t.Error("reopening a tree with the wrong hash did not return error")
+	}
+	reopened, err := NewSparseMerkleTree(db, namespaceTestTrie, HashKeccak256, root, 160, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := reopened.Get([]byte("testKey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(value, []byte("testValue")) {
+		t.Error("did not get correct value from reopened tree")
+	}
+}
+
+func TestReopenLegacyTree(t *testing.T) {
+	db := memorydb.NewDB()
+	smt, err := NewSparseMerkleTree(db, namespaceTestTrie, HashKeccak256, nil, 160, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Trees created before hashes were recorded have an empty init marker
+	err = db.Set(namespaceTestTrie, initMarker, []byte{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewSparseMerkleTree(db, namespaceTestTrie, HashSHA256, smt.Root(), 160, false)
+	if err == nil {
+		t.Error("reopening a legacy tree with the wrong hash did not return error")
+	}
+	_, err = NewSparseMerkleTree(db, namespaceTestTrie, HashKeccak256, smt.Root(), 160, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	marker, _, err := db.Get(namespaceTestTrie, initMarker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(marker) != HashKeccak256 {
+		t.Errorf("init marker of legacy tree not upgraded: %q", marker)
+	}
+}
+
+func BenchmarkHash(b *testing.B) {
+	node := make([]byte, 64)
+	for _, name := range HashNames() {
+		newHasher, err := GetHash(name)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(name, func(b *testing.B) {
+			hasher := newHasher()
+			b.SetBytes(int64(len(node)))
+			for i := 0; i < b.N; i++ {
+				hasher.Write(node)
+				hasher.Sum(nil)
+				hasher.Reset()
+			}
+		})
+	}
+}
+
+func BenchmarkHashUpdate(b *testing.B) {
+	for _, name := range HashNames() {
+		b.Run(name, func(b *testing.B) {
+			smt, err := NewSparseMerkleTree(memorydb.NewDB(), namespaceTestTrie, name, nil, 160, false)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err = smt.Update(big.NewInt(int64(i)).Bytes(), []byte("testValue"))
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package smt
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+)
+
+// LeafIterator walks the non-default leaves under a root depth-first, in path order. Default subtrees and
+// subtrees outside the bounds are skipped without reading them.
+// Keys are paths: the padded key for trees that do not hash keys, the hash of the key otherwise, with only the
+// height-1 bits that select the leaf set.
+type LeafIterator struct {
+	smt   *SparseMerkleTree
+	start *big.Int
+	end   *big.Int
+	stack []*iteratorFrame
+	key   []byte
+	value []byte
+}
+
+type iteratorFrame struct {
+	hash  []byte
+	depth int
+	path  *big.Int
+}
+
+// NewLeafIterator creates an iterator over the leaves under root with start <= key < end, positioned at the
+// first leaf. Nil bounds are open. Bounds are paths like the keys of the iterator.
+func (smt *SparseMerkleTree) NewLeafIterator(root []byte, start []byte, end []byte) (*LeafIterator, error) {
+	if len(start) > smt.keySize() || len(end) > smt.keySize() {
+		return nil, errors.New("Bound too long")
+	}
+	it := &LeafIterator{
+		smt:   smt,
+		stack: []*iteratorFrame{{hash: root, depth: 0, path: new(big.Int)}},
+	}
+	if start != nil {
+		it.start = new(big.Int).SetBytes(start)
+	}
+	if end != nil {
+		it.end = new(big.Int).SetBytes(end)
+	}
+	err := it.Next()
+	if err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+// Valid returns whether the iterator is positioned at a leaf.
+func (it *LeafIterator) Valid() bool {
+	return it.key != nil
+}
+
+// Key returns the path of the current leaf.
+func (it *LeafIterator) Key() []byte {
+	return it.key
+}
+
+// Value returns the value of the current leaf.
+func (it *LeafIterator) Value() []byte {
+	return it.value
+}
+
+// Next moves the iterator to the next leaf. The iterator is no longer valid after the last leaf.
+func (it *LeafIterator) Next() error {
+	smt := it.smt
+	it.key = nil
+	it.value = nil
+	for len(it.stack) > 0 {
+		frame := it.stack[len(it.stack)-1]
+		it.stack = it.stack[:len(it.stack)-1]
+		if !it.overlaps(frame) ||
+			bytes.Equal(frame.hash, smt.defaultNode(smt.hasherSize*8-smt.height+frame.depth)) {
+			continue
+		}
+		nodeValue, err := smt.getNode(frame.hash)
+		if err != nil {
+			return err
+		}
+		if frame.depth == smt.height-1 {
+			it.key = smt.pathBytes(frame.path)
+			it.value = nodeValue
+			return nil
+		}
+		// Push right first, so that left is visited first
+		it.stack = append(it.stack,
+			&iteratorFrame{
+				hash:  nodeValue[smt.keySize():],
+				depth: frame.depth + 1,
+				path:  new(big.Int).SetBit(frame.path, smt.height-2-frame.depth, 1),
+			},
+			&iteratorFrame{
+				hash:  nodeValue[:smt.keySize()],
+				depth: frame.depth + 1,
+				path:  frame.path,
+			})
+	}
+	return nil
+}
+
+// overlaps returns whether the subtree of frame contains paths within the bounds.
+func (it *LeafIterator) overlaps(frame *iteratorFrame) bool {
+	// The subtree covers [path, path + 2^(height-1-depth))
+	last := new(big.Int).Lsh(big.NewInt(1), uint(it.smt.height-1-frame.depth))
+	last.Add(last, frame.path)
+	last.Sub(last, big.NewInt(1))
+	if it.start != nil && last.Cmp(it.start) < 0 {
+		return false
+	}
+	if it.end != nil && frame.path.Cmp(it.end) >= 0 {
+		return false
+	}
+	return true
+}
+
+// pathBytes converts a masked path back to hasher-size bytes.
+func (smt *SparseMerkleTree) pathBytes(path *big.Int) []byte {
+	data := path.Bytes()
+	padded := make([]byte, smt.keySize())
+	copy(padded[smt.keySize()-len(data):], data)
+	return padded
+}
@@ -0,0 +1,133 @@
+package smt
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/celer-network/go-rollup/db/memorydb"
+)
+
+func collectLeaves(t *testing.T, smt *SparseMerkleTree, root []byte, start []byte, end []byte) ([][]byte, [][]byte) {
+	it, err := smt.NewLeafIterator(root, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys, values [][]byte
+	for it.Valid() {
+		keys = append(keys, it.Key())
+		values = append(values, it.Value())
+		err = it.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return keys, values
+}
+
+func TestLeafIterator(t *testing.T) {
+	smt, err := NewSparseMerkleTree(memorydb.NewDB(), namespaceTestTrie, HashKeccak256, nil, 160, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, _ := collectLeaves(t, smt, smt.Root(), nil, nil)
+	if len(keys) != 0 {
+		t.Error("iterator over empty tree returned leaves")
+	}
+
+	slots := []int64{42, 0, 7, 1000, 8, 9, 123456}
+	for _, slot := range slots {
+		_, err = smt.Update(big.NewInt(slot).Bytes(), []byte(fmt.Sprintf("testValue%d", slot)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Setting a key back to the default value removes its leaf
+	_, err = smt.Update(big.NewInt(9).Bytes(), DefaultValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []int64{0, 7, 8, 42, 1000, 123456}
+
+	keys, values := collectLeaves(t, smt, smt.Root(), nil, nil)
+	if len(keys) != len(expected) {
+		t.Fatalf("iterator returned %d leaves instead of %d", len(keys), len(expected))
+	}
+	for i, slot := range expected {
+		if new(big.Int).SetBytes(keys[i]).Int64() != slot {
+			t.Errorf("leaf %d has key %x instead of slot %d", i, keys[i], slot)
+		}
+		if !bytes.Equal(values[i], []byte(fmt.Sprintf("testValue%d", slot))) {
+			t.Errorf("leaf %d has wrong value", i)
+		}
+	}
+
+	for _, tc := range []struct {
+		start    int64
+		end      int64
+		expected []int64
+	}{
+		{7, 42, []int64{7, 8}},
+		{8, 43, []int64{8, 42}},
+		{1, 7, nil},
+		{-1, 8, []int64{0, 7}},
+		{1000, -1, []int64{1000, 123456}},
+	} {
+		var start, end []byte
+		if tc.start >= 0 {
+			start = big.NewInt(tc.start).Bytes()
+		}
+		if tc.end >= 0 {
+			end = big.NewInt(tc.end).Bytes()
+		}
+		keys, _ = collectLeaves(t, smt, smt.Root(), start, end)
+		if len(keys) != len(tc.expected) {
+			t.Errorf("range [%d, %d) returned %d leaves instead of %d", tc.start, tc.end, len(keys), len(tc.expected))
+			continue
+		}
+		for i, slot := range tc.expected {
+			if new(big.Int).SetBytes(keys[i]).Int64() != slot {
+				t.Errorf("range [%d, %d) returned %x instead of slot %d", tc.start, tc.end, keys[i], slot)
+			}
+		}
+	}
+
+	_, err = smt.NewLeafIterator(smt.Root(), make([]byte, 33), nil)
+	if err == nil {
+		t.Error("NewLeafIterator did not return error on long bound")
+	}
+}
+
+func TestLeafIteratorHashedKeys(t *testing.T) {
+	smt, err := NewSparseMerkleTree(memorydb.NewDB(), namespaceTestTrie, HashSHA256, nil, 256, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for i := 0; i < 50; i++ {
+		key := []byte(fmt.Sprintf("testKey%d", i))
+		_, err = smt.Update(key, []byte("testValue"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		path, err := smt.getPath(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Only the lower height-1 bits select the leaf
+		path[0] &= 0x7f
+		paths = append(paths, string(path))
+	}
+	sort.Strings(paths)
+	keys, _ := collectLeaves(t, smt, smt.Root(), nil, nil)
+	if len(keys) != len(paths) {
+		t.Fatalf("iterator returned %d leaves instead of %d", len(keys), len(paths))
+	}
+	for i := range keys {
+		if string(keys[i]) != paths[i] {
+			t.Error("iterator keys are not the sorted key hashes")
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package smt
+
+import (
+	"bytes"
+	"errors"
+	"hash"
+	"math/big"
+	"sort"
+)
+
+// A multi-proof proves several keys under the same root. It holds the sibling nodes that cannot be computed from
+// the keys themselves, in depth-first order: walking down from the root, a node whose subtrees both contain
+// proven keys needs no sibling, a node with proven keys in one subtree only needs the other subtree's hash.
+
+// multiProofPaths returns the distinct paths of keys, sorted so that every subtree is a contiguous range.
+func multiProofPaths(keys [][]byte, hasher hash.Hash, height int, hashKey bool) ([]*big.Int, error) {
+	mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(height-1)), big.NewInt(1))
+	paths := make([]*big.Int, 0, len(keys))
+	for _, key := range keys {
+		path, err := computePath(key, hasher, hashKey)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, new(big.Int).And(new(big.Int).SetBytes(path), mask))
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return paths[i].Cmp(paths[j]) < 0
+	})
+	distinct := paths[:0]
+	for i, path := range paths {
+		if i == 0 || path.Cmp(paths[i-1]) != 0 {
+			distinct = append(distinct, path)
+		}
+	}
+	return distinct, nil
+}
+
+// multiProofEntries pairs keys with values, sorted like multiProofPaths. Repeated keys must have equal values.
+func multiProofEntries(
+	keys [][]byte, values [][]byte, hasher hash.Hash, height int, hashKey bool) ([]*batchEntry, error) {
+	if len(keys) != len(values) {
+		return nil, errors.New("Number of keys and values differ")
+	}
+	mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(height-1)), big.NewInt(1))
+	entries := make([]*batchEntry, len(keys))
+	for i, key := range keys {
+		path, err := computePath(key, hasher, hashKey)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = &batchEntry{
+			path:  new(big.Int).And(new(big.Int).SetBytes(path), mask),
+			value: values[i],
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].path.Cmp(entries[j].path) < 0
+	})
+	distinct := entries[:0]
+	for i, entry := range entries {
+		if i > 0 && entry.path.Cmp(entries[i-1].path) == 0 {
+			if !bytes.Equal(entry.value, entries[i-1].value) {
+				return nil, errors.New("Repeated key with different values")
+			}
+			continue
+		}
+		distinct = append(distinct, entry)
+	}
+	return distinct, nil
+}
+
+// splitPaths returns the index of the first path that goes right at depth.
+func splitPaths(numPaths int, path func(i int) *big.Int, depth int, height int) int {
+	bit := height - 2 - depth
+	return sort.Search(numPaths, func(i int) bool {
+		return path(i).Bit(bit) == 1
+	})
+}
+
+// multiProofSiblingDepths returns the depth of each sibling of a multi-proof for paths, in proof order.
+func multiProofSiblingDepths(paths []*big.Int, depth int, height int) []int {
+	if depth == height-1 {
+		return nil
+	}
+	split := splitPaths(len(paths), func(i int) *big.Int { return paths[i] }, depth, height)
+	if split == 0 || split == len(paths) {
+		return append([]int{depth + 1}, multiProofSiblingDepths(paths, depth+1, height)...)
+	}
+	return append(
+		multiProofSiblingDepths(paths[:split], depth+1, height),
+		multiProofSiblingDepths(paths[split:], depth+1, height)...)
+}
+
+// ProveMulti generates a multi-proof for several keys.
+func (smt *SparseMerkleTree) ProveMulti(keys [][]byte) ([][]byte, error) {
+	return smt.ProveMultiForRoot(keys, smt.Root())
+}
+
+// ProveMultiForRoot generates a multi-proof for several keys, at a specific root.
+func (smt *SparseMerkleTree) ProveMultiForRoot(keys [][]byte, root []byte) ([][]byte, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("No keys to prove")
+	}
+	hasher := smt.getHasher()
+	defer smt.putHasher(hasher)
+	paths, err := multiProofPaths(keys, hasher, smt.height, smt.hashKey)
+	if err != nil {
+		return nil, err
+	}
+	var proof [][]byte
+	err = smt.proveMultiSubtree(&proof, root, 0, paths)
+	if err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+func (smt *SparseMerkleTree) proveMultiSubtree(proof *[][]byte, nodeHash []byte, depth int, paths []*big.Int) error {
+	if depth == smt.height-1 {
+		return nil
+	}
+	nodeValue, err := smt.getNode(nodeHash)
+	if err != nil {
+		return err
+	}
+	leftHash := nodeValue[:smt.keySize()]
+	rightHash := nodeValue[smt.keySize():]
+	split := splitPaths(len(paths), func(i int) *big.Int { return paths[i] }, depth, smt.height)
+	switch {
+	case split == 0:
+		*proof = append(*proof, leftHash)
+		return smt.proveMultiSubtree(proof, rightHash, depth+1, paths)
+	case split == len(paths):
+		*proof = append(*proof, rightHash)
+		return smt.proveMultiSubtree(proof, leftHash, depth+1, paths)
+	default:
+		err = smt.proveMultiSubtree(proof, leftHash, depth+1, paths[:split])
+		if err != nil {
+			return err
+		}
+		return smt.proveMultiSubtree(proof, rightHash, depth+1, paths[split:])
+	}
+}
+
+func (smt *SparseMerkleTree) VerifyMultiProof(proof [][]byte, keys [][]byte, values [][]byte) bool {
+	hasher := smt.getHasher()
+	defer smt.putHasher(hasher)
+	return VerifyMultiProof(proof, smt.Root(), keys, values, hasher, smt.height, smt.hashKey)
+}
+
+func (smt *SparseMerkleTree) CompactMultiProof(proof [][]byte, keys [][]byte) ([][]byte, error) {
+	hasher := smt.getHasher()
+	defer smt.putHasher(hasher)
+	return CompactMultiProof(proof, keys, hasher, smt.height, smt.hashKey)
+}
+
+func (smt *SparseMerkleTree) DecompactMultiProof(proof [][]byte, keys [][]byte) ([][]byte, error) {
+	hasher := smt.getHasher()
+	defer smt.putHasher(hasher)
+	return DecompactMultiProof(proof, keys, hasher, smt.height, smt.hashKey)
+}
+
+// multiProofHasher recomputes the root of a multi-proof, optionally storing the computed nodes.
+type multiProofHasher struct {
+	hasher   hash.Hash
+	height   int
+	siblings [][]byte
+	position int
+	writer   *batchWriter
+	smt      *SparseMerkleTree
+}
+
+func (h *multiProofHasher) digest(data []byte) ([]byte, error) {
+	if h.writer != nil {
+		return h.smt.writeNode(h.writer, data)
+	}
+	h.hasher.Write(data)
+	sum := h.hasher.Sum(nil)
+	h.hasher.Reset()
+	return sum, nil
+}
+
+func (h *multiProofHasher) nextSibling() ([]byte, error) {
+	if h.position >= len(h.siblings) {
+		return nil, errors.New("Multi-proof too short")
+	}
+	sibling := h.siblings[h.position]
+	if len(sibling) != h.hasher.Size() {
+		return nil, errors.New("Invalid sibling size")
+	}
+	h.position++
+	return sibling, nil
+}
+
+func (h *multiProofHasher) subtreeRoot(depth int, entries []*batchEntry) ([]byte, error) {
+	if depth == h.height-1 {
+		// Copy the leaf, so that the caller cannot change a cached value
+		return h.digest(append([]byte{}, entries[0].value...))
+	}
+	split := splitPaths(len(entries), func(i int) *big.Int { return entries[i].path }, depth, h.height)
+	var left, right []byte
+	var err error
+	switch {
+	case split == 0:
+		left, err = h.nextSibling()
+		if err != nil {
+			return nil, err
+		}
+		right, err = h.subtreeRoot(depth+1, entries)
+	case split == len(entries):
+		right, err = h.nextSibling()
+		if err != nil {
+			return nil, err
+		}
+		left, err = h.subtreeRoot(depth+1, entries)
+	default:
+		left, err = h.subtreeRoot(depth+1, entries[:split])
+		if err != nil {
+			return nil, err
+		}
+		right, err = h.subtreeRoot(depth+1, entries[split:])
+	}
+	if err != nil {
+		return nil, err
+	}
+	node := make([]byte, 0, 2*h.hasher.Size())
+	node = append(node, left...)
+	node = append(node, right...)
+	return h.digest(node)
+}
+
+// rootFromMultiProof computes the root implied by a multi-proof and the values of its keys.
+func rootFromMultiProof(proof [][]byte, entries []*batchEntry, h *multiProofHasher) ([]byte, error) {
+	h.siblings = proof
+	root, err := h.subtreeRoot(0, entries)
+	if err != nil {
+		return nil, err
+	}
+	if h.position != len(proof) {
+		return nil, errors.New("Multi-proof too long")
+	}
+	return root, nil
+}
+
+// VerifyMultiProof verifies a multi-proof generated by ProveMulti.
+func VerifyMultiProof(
+	proof [][]byte, root []byte, keys [][]byte, values [][]byte, hasher hash.Hash, height int, hashKey bool) bool {
+	if len(keys) == 0 {
+		return false
+	}
+	entries, err := multiProofEntries(keys, values, hasher, height, hashKey)
+	if err != nil {
+		return false
+	}
+	computedRoot, err := rootFromMultiProof(proof, entries, &multiProofHasher{hasher: hasher, height: height})
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(computedRoot, root)
+}
+
+// VerifyCompactMultiProof verifies a compacted multi-proof.
+func VerifyCompactMultiProof(
+	proof [][]byte, root []byte, keys [][]byte, values [][]byte, hasher hash.Hash, height int, hashKey bool) bool {
+	decompactedProof, err := DecompactMultiProof(proof, keys, hasher, height, hashKey)
+	if err != nil {
+		return false
+	}
+	return VerifyMultiProof(decompactedProof, root, keys, values, hasher, height, hashKey)
+}
+
+// CompactMultiProof compacts a multi-proof by replacing default siblings with one bit each. The first element
+// of the result is the bitmap, with a set bit for every default sibling in proof order.
+func CompactMultiProof(proof [][]byte, keys [][]byte, hasher hash.Hash, height int, hashKey bool) ([][]byte, error) {
+	paths, err := multiProofPaths(keys, hasher, height, hashKey)
+	if err != nil {
+		return nil, err
+	}
+	depths := multiProofSiblingDepths(paths, 0, height)
+	if len(proof) != len(depths) {
+		return nil, errors.New("bad proof size")
+	}
+	hasherSizeBits := hasher.Size() * 8
+	defaults := defaultNodes(hasher)
+	bits := emptyBytes((len(depths) + 7) / 8)
+	compactProof := [][]byte{bits}
+	for i, depth := range depths {
+		if bytes.Equal(proof[i], defaults[hasherSizeBits-height+depth]) {
+			setBit(bits, i)
+		} else {
+			compactProof = append(compactProof, proof[i])
+		}
+	}
+	return compactProof, nil
+}
+
+// DecompactMultiProof decompacts a multi-proof, so that it can be used for VerifyMultiProof.
+func DecompactMultiProof(proof [][]byte, keys [][]byte, hasher hash.Hash, height int, hashKey bool) ([][]byte, error) {
+	paths, err := multiProofPaths(keys, hasher, height, hashKey)
+	if err != nil {
+		return nil, err
+	}
+	depths := multiProofSiblingDepths(paths, 0, height)
+	if len(proof) == 0 || len(proof[0]) != (len(depths)+7)/8 {
+		return nil, errors.New("invalid proof size")
+	}
+	// The padding bits have no sibling, so they would be counted as default siblings that are not there
+	if hasSetBitsFrom(proof[0], len(depths)) {
+		return nil, errors.New("invalid proof bitmask")
+	}
+	if len(proof) != len(depths)-countSetBits(proof[0])+1 {
+		return nil, errors.New("invalid proof size")
+	}
+	hasherSizeBits := hasher.Size() * 8
+	defaults := defaultNodes(hasher)
+	bits := proof[0]
+	compactProof := proof[1:]
+	decompactedProof := make([][]byte, len(depths))
+	position := 0
+	for i, depth := range depths {
+		if hasBit(bits, i) == 1 {
+			decompactedProof[i] = defaults[hasherSizeBits-height+depth]
+		} else {
+			decompactedProof[i] = compactProof[position]
+			position++
+		}
+	}
+	return decompactedProof, nil
+}
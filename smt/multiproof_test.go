@@ -0,0 +1,204 @@
+package smt
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/celer-network/go-rollup/db/memorydb"
+	"github.com/minio/sha256-simd"
+	"golang.org/x/crypto/sha3"
+)
+
+func TestMultiProof(t *testing.T) {
+	smt, err := NewSparseMerkleTree(memorydb.NewDB(), namespaceTestTrie, HashSHA256, nil, 256, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys, values [][]byte
+	for i := 0; i < 20; i++ {
+		keys = append(keys, []byte(fmt.Sprintf("testKey%d", i)))
+		values = append(values, []byte(fmt.Sprintf("testValue%d", i)))
+	}
+	_, err = smt.UpdateBatch(keys[:10], values[:10])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Proven keys include present and absent keys, in any order and repeated
+	provenKeys := [][]byte{keys[3], keys[0], keys[15], keys[7], keys[3]}
+	provenValues := [][]byte{values[3], values[0], DefaultValue, values[7], values[3]}
+	proof, err := smt.ProveMulti(provenKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !smt.VerifyMultiProof(proof, provenKeys, provenValues) {
+		t.Error("valid multi-proof failed to verify")
+	}
+	numSiblings := 0
+	for _, key := range provenKeys[:4] {
+		single, err := smt.Prove(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		numSiblings += len(single)
+	}
+	if len(proof) >= numSiblings {
+		t.Error("multi-proof not smaller than separate proofs")
+	}
+
+	badValues := [][]byte{values[3], values[0], DefaultValue, values[8], values[3]}
+	if smt.VerifyMultiProof(proof, provenKeys, badValues) {
+		t.Error("invalid multi-proof verification returned true")
+	}
+	conflictingValues := [][]byte{values[3], values[0], DefaultValue, values[7], values[4]}
+	if smt.VerifyMultiProof(proof, provenKeys, conflictingValues) {
+		t.Error("multi-proof with conflicting values for a key returned true")
+	}
+	if smt.VerifyMultiProof(proof[1:], provenKeys, provenValues) {
+		t.Error("truncated multi-proof verification returned true")
+	}
+	if smt.VerifyMultiProof(append(proof, proof[0]), provenKeys, provenValues) {
+		t.Error("extended multi-proof verification returned true")
+	}
+	if smt.VerifyMultiProof(proof, provenKeys[:3], provenValues[:3]) {
+		t.Error("multi-proof verified for a subset of its keys")
+	}
+
+	compactProof, err := smt.CompactMultiProof(proof, provenKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compactProof) >= len(proof) {
+		t.Error("compact multi-proof not smaller than multi-proof")
+	}
+	if !VerifyCompactMultiProof(compactProof, smt.Root(), provenKeys, provenValues, sha256.New(), 256, true) {
+		t.Error("valid compact multi-proof failed to verify")
+	}
+	decompactedProof, err := smt.DecompactMultiProof(compactProof, provenKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decompactedProof) != len(proof) {
+		t.Fatal("decompacted multi-proof has a different length")
+	}
+	for i := range proof {
+		if !bytes.Equal(decompactedProof[i], proof[i]) {
+			t.Error("decompacted multi-proof differs from multi-proof")
+		}
+	}
+	if _, err = smt.DecompactMultiProof(compactProof[1:], provenKeys); err == nil {
+		t.Error("decompacting a truncated multi-proof did not fail")
+	}
+
+	// A bit set in the padding of the bitmask must not stand for a sibling
+	if len(proof)%8 == 0 {
+		t.Fatal("bitmask without padding")
+	}
+	paddedBits := append([]byte{}, compactProof[0]...)
+	setBit(paddedBits, len(proof))
+	paddedProof := append([][]byte{paddedBits}, compactProof[1:len(compactProof)-1]...)
+	if _, err = smt.DecompactMultiProof(paddedProof, provenKeys); err == nil {
+		t.Error("decompacting a multi-proof with padding bits set did not fail")
+	}
+	if VerifyCompactMultiProof(paddedProof, smt.Root(), provenKeys, provenValues, sha256.New(), 256, true) {
+		t.Error("compact multi-proof with padding bits set verified")
+	}
+}
+
+func TestMultiProofNumericalKey(t *testing.T) {
+	// Same shape as the state tree: keccak, height 160, keys used as paths
+	smt, err := NewSparseMerkleTree(memorydb.NewDB(), namespaceTestTrie, HashKeccak256, nil, 160, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys, values [][]byte
+	for i := int64(0); i < 8; i++ {
+		keys = append(keys, big.NewInt(i).Bytes())
+		values = append(values, []byte{byte(i + 1)})
+	}
+	_, err = smt.UpdateBatch(keys, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provenKeys := [][]byte{keys[1], keys[2], keys[6]}
+	provenValues := [][]byte{values[1], values[2], values[6]}
+	proof, err := smt.ProveMulti(provenKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !smt.VerifyMultiProof(proof, provenKeys, provenValues) {
+		t.Error("valid multi-proof failed to verify")
+	}
+	// The paths join in the subtree of the first 8 leaves, above it every level needs one sibling
+	if len(proof) != 156+4 {
+		t.Errorf("unexpected multi-proof length %d", len(proof))
+	}
+	compactProof, err := smt.CompactMultiProof(proof, provenKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the siblings inside the populated subtree of 8 leaves are not default
+	if len(compactProof) != 1+4 {
+		t.Errorf("unexpected compact multi-proof length %d", len(compactProof))
+	}
+	if !VerifyCompactMultiProof(
+		compactProof, smt.Root(), provenKeys, provenValues, sha3.NewLegacyKeccak256(), 160, false) {
+		t.Error("valid compact multi-proof failed to verify")
+	}
+}
+
+func TestDeepSparseMerkleSubTreeMultiBranches(t *testing.T) {
+	smt, err := NewSparseMerkleTree(memorydb.NewDB(), namespaceTestTrie, HashSHA256, nil, 256, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := [][]byte{[]byte("testKey1"), []byte("testKey2"), []byte("testKey3"), []byte("testKey4")}
+	values := [][]byte{[]byte("testValue1"), []byte("testValue2"), []byte("testValue3"), []byte("testValue4")}
+	_, err = smt.UpdateBatch(keys, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dsmst, err := NewDeepSparseMerkleSubTree(memorydb.NewDB(), namespaceTestTrie, HashSHA256, 256, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := smt.ProveMulti(keys[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := dsmst.AddBranches(proof, keys[:2], values[:2], true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(root, smt.Root()) {
+		t.Error("deep subtree root differs from tree root")
+	}
+	for i := 0; i < 2; i++ {
+		value, err := dsmst.Get(keys[i])
+		if err != nil {
+			t.Error("returned error when getting value in deep subtree")
+		}
+		if !bytes.Equal(value, values[i]) {
+			t.Error("did not get correct value in deep subtree")
+		}
+	}
+
+	_, err = dsmst.Update(keys[0], []byte("testValue5"))
+	if err != nil {
+		t.Error("returned error when updating deep subtree")
+	}
+	_, err = smt.Update(keys[0], []byte("testValue5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dsmst.Root(), smt.Root()) {
+		t.Error("deep subtree root differs from tree root after update")
+	}
+
+	if _, err = dsmst.AddBranches(proof[1:], keys[:2], values[:2], false); err == nil {
+		t.Error("adding a truncated multi-proof did not fail")
+	}
+}
@@ -0,0 +1,65 @@
+package smt
+
+import (
+	"bytes"
+	"errors"
+	"hash"
+)
+
+// A non-membership proof shows that a key is empty, i.e. holds DefaultValue. It is a regular Merkle proof for
+// DefaultValue, so its side nodes are mostly default nodes and compact well.
+
+// ProveNonMembership generates a proof that a key is empty.
+func (smt *SparseMerkleTree) ProveNonMembership(key []byte) ([][]byte, error) {
+	return smt.ProveNonMembershipForRoot(key, smt.Root())
+}
+
+// ProveNonMembershipForRoot generates a proof that a key is empty, at a specific root.
+func (smt *SparseMerkleTree) ProveNonMembershipForRoot(key []byte, root []byte) ([][]byte, error) {
+	value, err := smt.GetForRoot(key, root)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(value, DefaultValue) {
+		return nil, errors.New("Key is not empty")
+	}
+	return smt.ProveForRoot(key, root)
+}
+
+// ProveNonMembershipCompact generates a compacted proof that a key is empty.
+func (smt *SparseMerkleTree) ProveNonMembershipCompact(key []byte) ([][]byte, error) {
+	return smt.ProveNonMembershipCompactForRoot(key, smt.Root())
+}
+
+// ProveNonMembershipCompactForRoot generates a compacted proof that a key is empty, at a specific root.
+func (smt *SparseMerkleTree) ProveNonMembershipCompactForRoot(key []byte, root []byte) ([][]byte, error) {
+	proof, err := smt.ProveNonMembershipForRoot(key, root)
+	if err != nil {
+		return nil, err
+	}
+	return smt.CompactProof(proof)
+}
+
+func (smt *SparseMerkleTree) VerifyNonMembershipProof(proof [][]byte, key []byte) bool {
+	hasher := smt.getHasher()
+	defer smt.putHasher(hasher)
+	return VerifyNonMembershipProof(proof, smt.Root(), key, hasher, smt.height, smt.hashKey)
+}
+
+func (smt *SparseMerkleTree) VerifyCompactNonMembershipProof(proof [][]byte, key []byte) bool {
+	hasher := smt.getHasher()
+	defer smt.putHasher(hasher)
+	return VerifyCompactNonMembershipProof(proof, smt.Root(), key, hasher, smt.height, smt.hashKey)
+}
+
+// VerifyNonMembershipProof verifies a proof that a key is empty.
+func VerifyNonMembershipProof(
+	proof [][]byte, root []byte, key []byte, hasher hash.Hash, height int, hashKey bool) bool {
+	return VerifyProof(proof, root, key, DefaultValue, hasher, height, hashKey)
+}
+
+// VerifyCompactNonMembershipProof verifies a compacted proof that a key is empty.
+func VerifyCompactNonMembershipProof(
+	proof [][]byte, root []byte, key []byte, hasher hash.Hash, height int, hashKey bool) bool {
+	return VerifyCompactProof(proof, root, key, DefaultValue, hasher, height, hashKey)
+}
@@ -0,0 +1,105 @@
+package smt
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/celer-network/go-rollup/db/memorydb"
+	"github.com/minio/sha256-simd"
+)
+
+func TestNonMembershipProofs(t *testing.T) {
+	smt, err := NewSparseMerkleTree(memorydb.NewDB(), namespaceTestTrie, HashSHA256, nil, 256, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every side node of an empty tree is the default node of its level
+	proof, err := smt.ProveNonMembership([]byte("testKey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, node := range proof {
+		if !bytes.Equal(node, defaultNodes(sha256.New())[sha256.Size*8-1-i]) {
+			t.Errorf("side node %d of empty tree is not the default node", i)
+		}
+	}
+	if !smt.VerifyNonMembershipProof(proof, []byte("testKey")) {
+		t.Error("valid non-membership proof failed to verify")
+	}
+	compactProof, err := smt.ProveNonMembershipCompact([]byte("testKey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compactProof) != 1 {
+		t.Errorf("compact non-membership proof of empty tree has %d elements", len(compactProof))
+	}
+	if !smt.VerifyCompactNonMembershipProof(compactProof, []byte("testKey")) {
+		t.Error("valid compact non-membership proof failed to verify")
+	}
+
+	smt.Update([]byte("testKey"), []byte("testValue"))
+	_, err = smt.ProveNonMembership([]byte("testKey"))
+	if err == nil {
+		t.Error("ProveNonMembership did not return error on non-empty key")
+	}
+	_, err = smt.ProveNonMembershipCompact([]byte("testKey"))
+	if err == nil {
+		t.Error("ProveNonMembershipCompact did not return error on non-empty key")
+	}
+	if smt.VerifyNonMembershipProof(proof, []byte("testKey")) {
+		t.Error("stale non-membership proof verification returned true")
+	}
+	memberProof, err := smt.Prove([]byte("testKey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if smt.VerifyNonMembershipProof(memberProof, []byte("testKey")) {
+		t.Error("non-membership proof verification of non-empty key returned true")
+	}
+
+	// With one key set, an empty key has exactly one side node that is not default
+	proof, err = smt.ProveNonMembership([]byte("testKey2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !smt.VerifyNonMembershipProof(proof, []byte("testKey2")) {
+		t.Error("valid non-membership proof failed to verify")
+	}
+	if smt.VerifyNonMembershipProof(proof, []byte("testKey3")) {
+		t.Error("invalid non-membership proof verification returned true")
+	}
+	compactProof, err = smt.ProveNonMembershipCompact([]byte("testKey2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compactProof) != 2 {
+		t.Errorf("compact non-membership proof has %d elements", len(compactProof))
+	}
+	if !smt.VerifyCompactNonMembershipProof(compactProof, []byte("testKey2")) {
+		t.Error("valid compact non-membership proof failed to verify")
+	}
+	if smt.VerifyCompactNonMembershipProof(compactProof, []byte("testKey")) {
+		t.Error("invalid compact non-membership proof verification returned true")
+	}
+	decompactedProof, err := smt.DecompactProof(compactProof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range proof {
+		if !bytes.Equal(decompactedProof[i], proof[i]) {
+			t.Error("decompacted non-membership proof differs from non-membership proof")
+		}
+	}
+
+	// A bit set after the bits of the siblings must not stand for a sibling
+	paddedBits := append([]byte{}, compactProof[0]...)
+	setBit(paddedBits, len(proof))
+	paddedProof := [][]byte{paddedBits}
+	if _, err = smt.DecompactProof(paddedProof); err == nil {
+		t.Error("decompacting a proof with padding bits set did not fail")
+	}
+	if smt.VerifyCompactNonMembershipProof(paddedProof, []byte("testKey2")) {
+		t.Error("compact non-membership proof with padding bits set verified")
+	}
+}
@@ -7,26 +7,36 @@ import (
 )
 
 func (smt *SparseMerkleTree) VerifyProof(proof [][]byte, key []byte, value []byte) bool {
-	return VerifyProof(proof, smt.root, key, value, smt.hasher, smt.height)
+	hasher := smt.getHasher()
+	defer smt.putHasher(hasher)
+	return VerifyProof(proof, smt.Root(), key, value, hasher, smt.height, smt.hashKey)
 }
 
 func (smt *SparseMerkleTree) VerifyCompactProof(proof [][]byte, key []byte, value []byte) bool {
-	return VerifyCompactProof(proof, smt.root, key, value, smt.hasher, smt.height)
+	hasher := smt.getHasher()
+	defer smt.putHasher(hasher)
+	return VerifyCompactProof(proof, smt.Root(), key, value, hasher, smt.height, smt.hashKey)
 }
 
 func (smt *SparseMerkleTree) CompactProof(proof [][]byte) ([][]byte, error) {
-	return CompactProof(proof, smt.hasher, smt.height)
+	hasher := smt.getHasher()
+	defer smt.putHasher(hasher)
+	return CompactProof(proof, hasher, smt.height)
 }
 
 func (smt *SparseMerkleTree) DecompactProof(proof [][]byte) ([][]byte, error) {
-	return DecompactProof(proof, smt.hasher, smt.height)
+	hasher := smt.getHasher()
+	defer smt.putHasher(hasher)
+	return DecompactProof(proof, hasher, smt.height)
 }
 
-// VerifyProof verifies a Merkle proof.
-func VerifyProof(proof [][]byte, root []byte, key []byte, value []byte, hasher hash.Hash, height int) bool {
-	hasher.Write(key)
-	path := hasher.Sum(nil)
-	hasher.Reset()
+// VerifyProof verifies a Merkle proof. hashKey must match the tree that generated the proof.
+func VerifyProof(
+	proof [][]byte, root []byte, key []byte, value []byte, hasher hash.Hash, height int, hashKey bool) bool {
+	path, err := computePath(key, hasher, hashKey)
+	if err != nil {
+		return false
+	}
 
 	hasher.Write(value)
 	currentHash := hasher.Sum(nil)
@@ -37,11 +47,11 @@ func VerifyProof(proof [][]byte, root []byte, key []byte, value []byte, hasher h
 	}
 
 	for i := height - 2; i >= 0; i-- {
-		node := make([]byte, hasher.Size())
-		copy(node, proof[height-2-i])
-		if len(node) != hasher.Size() {
+		if len(proof[height-2-i]) != hasher.Size() {
 			return false
 		}
+		node := make([]byte, hasher.Size())
+		copy(node, proof[height-2-i])
 		if !isLeft(path, i, height) {
 			hasher.Write(append(node, currentHash...))
 			currentHash = hasher.Sum(nil)
@@ -57,12 +67,13 @@ func VerifyProof(proof [][]byte, root []byte, key []byte, value []byte, hasher h
 }
 
 // VerifyCompactProof verifies a compacted Merkle proof.
-func VerifyCompactProof(proof [][]byte, root []byte, key []byte, value []byte, hasher hash.Hash, height int) bool {
+func VerifyCompactProof(
+	proof [][]byte, root []byte, key []byte, value []byte, hasher hash.Hash, height int, hashKey bool) bool {
 	decompactedProof, err := DecompactProof(proof, hasher, height)
 	if err != nil {
 		return false
 	}
-	return VerifyProof(decompactedProof, root, key, value, hasher, height)
+	return VerifyProof(decompactedProof, root, key, value, hasher, height, hashKey)
 }
 
 // CompactProof compacts a proof, to reduce its size.
@@ -76,7 +87,7 @@ func CompactProof(proof [][]byte, hasher hash.Hash, height int) ([][]byte, error
 	for i := 0; i < height-1; i++ {
 		node := make([]byte, hasher.Size())
 		copy(node, proof[i])
-		if bytes.Compare(node, defaultNodes(hasher)[i]) == 0 {
+		if bytes.Compare(node, defaultProofNode(hasher, i)) == 0 {
 			setBit(bits, i)
 		} else {
 			compactProof = append(compactProof, node)
@@ -87,9 +98,15 @@ func CompactProof(proof [][]byte, hasher hash.Hash, height int) ([][]byte, error
 
 // DecompactProof decompacts a proof, so that it can be used for VerifyProof.
 func DecompactProof(proof [][]byte, hasher hash.Hash, height int) ([][]byte, error) {
-	if len(proof) == 0 ||
-		len(proof[0]) != hasher.Size() ||
-		len(proof) != (height-1-countSetBits(proof[0]))+1 {
+	if len(proof) == 0 || len(proof[0]) != hasher.Size() {
+		return nil, errors.New("invalid proof size")
+	}
+	// The bitmask has a bit for each sibling, and the bits after them would be counted as default siblings that
+	// are not there
+	if hasSetBitsFrom(proof[0], height-1) {
+		return nil, errors.New("invalid proof bitmask")
+	}
+	if len(proof) != (height-1-countSetBits(proof[0]))+1 {
 		return nil, errors.New("invalid proof size")
 	}
 
@@ -98,8 +115,8 @@ func DecompactProof(proof [][]byte, hasher hash.Hash, height int) ([][]byte, err
 	compactProof := proof[1:]
 	position := 0
 	for i := 0; i < height-1; i++ {
-		if !isLeft(bits, i, height) {
-			decompactedProof[i] = defaultNodes(hasher)[i]
+		if hasBit(bits, i) == 1 {
+			decompactedProof[i] = defaultProofNode(hasher, i)
 		} else {
 			decompactedProof[i] = compactProof[position]
 			position++
@@ -108,6 +125,27 @@ func DecompactProof(proof [][]byte, hasher hash.Hash, height int) ([][]byte, err
 	return decompactedProof, nil
 }
 
+// computePath returns the path of a key in a tree that may or may not hash its keys.
+func computePath(key []byte, hasher hash.Hash, hashKey bool) ([]byte, error) {
+	if hashKey {
+		hasher.Write(key)
+		path := hasher.Sum(nil)
+		hasher.Reset()
+		return path, nil
+	}
+	if len(key) > hasher.Size() {
+		return nil, errors.New("Key too long")
+	}
+	path := make([]byte, hasher.Size())
+	copy(path[hasher.Size()-len(key):], key)
+	return path, nil
+}
+
+// defaultProofNode returns the default value of the proof node at position, counted from the leaf like proofs are.
+func defaultProofNode(hasher hash.Hash, position int) []byte {
+	return defaultNodes(hasher)[hasher.Size()*8-1-position]
+}
+
 func reverseProof(proof [][]byte) [][]byte {
 	for i := len(proof)/2 - 1; i >= 0; i-- {
 		opp := len(proof) - 1 - i
@@ -1,7 +1,9 @@
 package smt
 
 import (
+	"bytes"
 	"crypto/rand"
+	"math/big"
 	"reflect"
 	"testing"
 
@@ -11,7 +13,7 @@ import (
 
 func TestProofs(t *testing.T) {
 	db := memorydb.NewDB()
-	smt, err := NewSparseMerkleTree(db, namespaceTestTrie, sha256.New(), nil, 256, true)
+	smt, err := NewSparseMerkleTree(db, namespaceTestTrie, HashSHA256, nil, 256, true)
 	if err != nil {
 		t.Error(err)
 	}
@@ -213,19 +215,19 @@ func TestProofs(t *testing.T) {
 		t.Error("error returned when trying to prove inclusion")
 		t.Log(err)
 	}
-	result = VerifyCompactProof(proof, root, []byte("testKey2"), []byte("testValue"), smt.hasher, smt.height)
+	result = VerifyCompactProof(proof, root, []byte("testKey2"), []byte("testValue"), sha256.New(), smt.height, smt.hashKey)
 	if !result {
 		t.Error("valid proof failed to verify")
 	}
-	result = VerifyCompactProof(proof, root, []byte("testKey2"), []byte("badValue"), smt.hasher, smt.height)
+	result = VerifyCompactProof(proof, root, []byte("testKey2"), []byte("badValue"), sha256.New(), smt.height, smt.hashKey)
 	if result {
 		t.Error("invalid proof verification returned true")
 	}
-	result = VerifyCompactProof(proof, root, []byte("testKey3"), []byte("testValue"), smt.hasher, smt.height)
+	result = VerifyCompactProof(proof, root, []byte("testKey3"), []byte("testValue"), sha256.New(), smt.height, smt.hashKey)
 	if result {
 		t.Error("invalid proof verification returned true")
 	}
-	result = VerifyCompactProof(badProof, root, []byte("testKey"), []byte("testValue"), smt.hasher, smt.height)
+	result = VerifyCompactProof(badProof, root, []byte("testKey"), []byte("testValue"), sha256.New(), smt.height, smt.hashKey)
 	if result {
 		t.Error("invalid proof verification returned true")
 	}
@@ -235,20 +237,103 @@ func TestProofs(t *testing.T) {
 		t.Error("error returned when trying to prove inclusion")
 		t.Log(err)
 	}
-	result = VerifyProof(proof, root, []byte("testKey2"), []byte("testValue"), smt.hasher, smt.height)
+	result = VerifyProof(proof, root, []byte("testKey2"), []byte("testValue"), sha256.New(), smt.height, smt.hashKey)
 	if !result {
 		t.Error("valid proof failed to verify")
 	}
-	result = VerifyProof(proof, root, []byte("testKey2"), []byte("badValue"), smt.hasher, smt.height)
+	result = VerifyProof(proof, root, []byte("testKey2"), []byte("badValue"), sha256.New(), smt.height, smt.hashKey)
 	if result {
 		t.Error("invalid proof verification returned true")
 	}
-	result = VerifyProof(proof, root, []byte("testKey3"), []byte("testValue"), smt.hasher, smt.height)
+	result = VerifyProof(proof, root, []byte("testKey3"), []byte("testValue"), sha256.New(), smt.height, smt.hashKey)
 	if result {
 		t.Error("invalid proof verification returned true")
 	}
-	result = VerifyProof(badProof, root, []byte("testKey"), []byte("testValue"), smt.hasher, smt.height)
+	result = VerifyProof(badProof, root, []byte("testKey"), []byte("testValue"), sha256.New(), smt.height, smt.hashKey)
 	if result {
 		t.Error("invalid proof verification returned true")
 	}
 }
+
+func TestProofsKeyModes(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		hashName string
+		height   int
+		hashKey  bool
+	}{
+		{"hashed keys", HashSHA256, 256, true},
+		{"padded keys", HashSHA256, 256, false},
+		{"state tree", HashKeccak256, 160, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			smt, err := NewSparseMerkleTree(memorydb.NewDB(), namespaceTestTrie, tc.hashName, nil, tc.height, tc.hashKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys := [][]byte{big.NewInt(0).Bytes(), big.NewInt(1).Bytes(), big.NewInt(1000).Bytes()}
+			for i, key := range keys {
+				_, err = smt.Update(key, []byte{byte(i + 1)})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			emptyKey := big.NewInt(2).Bytes()
+
+			dsmst, err := NewDeepSparseMerkleSubTree(memorydb.NewDB(), namespaceTestTrie, tc.hashName, tc.height, tc.hashKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			newHasher, err := GetHash(tc.hashName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, key := range keys {
+				proof, err := smt.Prove(key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				value := []byte{byte(i + 1)}
+				if !smt.VerifyProof(proof, key, value) {
+					t.Error("valid proof failed to verify")
+				}
+				if !VerifyProof(proof, smt.Root(), key, value, newHasher(), tc.height, tc.hashKey) {
+					t.Error("valid proof failed to verify with standalone function")
+				}
+				if VerifyProof(proof, smt.Root(), key, value, newHasher(), tc.height, !tc.hashKey) {
+					t.Error("proof verified with the wrong key mode")
+				}
+				if smt.VerifyProof(proof, emptyKey, value) {
+					t.Error("invalid proof verification returned true")
+				}
+				compactProof, err := smt.CompactProof(proof)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !smt.VerifyCompactProof(compactProof, key, value) {
+					t.Error("valid compact proof failed to verify")
+				}
+				// A proof is the multi-proof of its key in reverse order
+				multiProof := reverseProof(append([][]byte{}, proof...))
+				root, err := dsmst.AddBranches(multiProof, [][]byte{key}, [][]byte{value}, true)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(root, smt.Root()) {
+					t.Error("deep subtree root differs from tree root")
+				}
+			}
+
+			proof, err := smt.ProveNonMembership(emptyKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !VerifyNonMembershipProof(proof, smt.Root(), emptyKey, newHasher(), tc.height, tc.hashKey) {
+				t.Error("valid non-membership proof failed to verify")
+			}
+			if smt.VerifyNonMembershipProof(proof, keys[0]) {
+				t.Error("invalid non-membership proof verification returned true")
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package smt
+
+import (
+	"bytes"
+	"errors"
+
+	rollupdb "github.com/celer-network/go-rollup/db"
+)
+
+// DefaultRetainedRootsLimit is the number of retained roots kept by a tree unless configured otherwise.
+const DefaultRetainedRootsLimit = 16
+
+// pruneChunkSize is the number of nodes deleted at once while pruning
+const pruneChunkSize = 10000
+
+// pruneMarkSuffix is appended to the namespace of a tree to get the namespace of its prune marks
+var pruneMarkSuffix = []byte("_pm")
+
+// retainedRootsKey stores the concatenated retained roots. It is shorter than a hash, so it can never be
+// mistaken for a node.
+var retainedRootsKey = []byte("retained")
+
+// PruneStats describes the outcome of a Prune.
+type PruneStats struct {
+	RetainedRoots int   `json:"retainedRoots"`
+	MarkedNodes   int   `json:"markedNodes"`
+	DeletedNodes  int   `json:"deletedNodes"`
+	DeletedBytes  int64 `json:"deletedBytes"`
+}
+
+// WithRetainedRootsLimit sets how many of the most recently retained roots survive pruning.
+func WithRetainedRootsLimit(limit int) Option {
+	return func(smt *SparseMerkleTree) error {
+		if limit <= 0 {
+			return errors.New("Retained roots limit must be positive")
+		}
+		smt.retainedRootsLimit = limit
+		return nil
+	}
+}
+
+// RetainedRoots returns the retained roots, oldest first.
+func (smt *SparseMerkleTree) RetainedRoots() ([][]byte, error) {
+	data, exists, err := smt.db.Get(smt.dbNamespace, retainedRootsKey)
+	if err != nil || !exists {
+		return nil, err
+	}
+	keySize := smt.keySize()
+	if len(data)%keySize != 0 {
+		return nil, errors.New("Corrupt retained roots")
+	}
+	roots := make([][]byte, len(data)/keySize)
+	for i := range roots {
+		roots[i] = data[i*keySize : (i+1)*keySize]
+	}
+	return roots, nil
+}
+
+// RetainRoot keeps the nodes of root when pruning, until it is pushed out by newer retained roots.
+func (smt *SparseMerkleTree) RetainRoot(root []byte) error {
+	smt.writeLock.Lock()
+	defer smt.writeLock.Unlock()
+	roots, err := smt.RetainedRoots()
+	if err != nil {
+		return err
+	}
+	if len(roots) > 0 && bytes.Equal(roots[len(roots)-1], root) {
+		return nil
+	}
+	roots = append(roots, root)
+	if len(roots) > smt.retainedRootsLimit {
+		roots = roots[len(roots)-smt.retainedRootsLimit:]
+	}
+	return smt.db.Set(smt.dbNamespace, retainedRootsKey, bytes.Join(roots, nil))
+}
+
+// Prune deletes all nodes that are not reachable from the retained roots or the current root, using
+// mark-and-sweep. Default nodes are always kept. Reading at other roots fails after they are pruned.
+//
+// The marks are stored in the DB, so that memory does not grow with the tree. Marking runs without blocking
+// writers, as only Prune deletes the nodes of the retained and current roots. The sweep deletes in chunks, and
+// every chunk holds the write lock only to mark the nodes of roots that appeared since, and to delete.
+func (smt *SparseMerkleTree) Prune() (*PruneStats, error) {
+	if smt.dbNamespace == nil {
+		return nil, errors.New("Cannot prune a tree without a DB namespace")
+	}
+	smt.pruneLock.Lock()
+	defer smt.pruneLock.Unlock()
+
+	marker := newPruneMarker(smt)
+	// Marks left by an interrupted Prune may be stale
+	err := marker.clear()
+	if err != nil {
+		return nil, err
+	}
+
+	// Mark
+	numRoots, err := marker.markRoots()
+	if err != nil {
+		return nil, err
+	}
+
+	// Sweep
+	stats := &PruneStats{RetainedRoots: numRoots - 1}
+	keyPrefixLen := len(smt.dbNamespace) + len(rollupdb.Separator)
+	var chunk [][]byte
+	var chunkBytes []int64
+	iter := smt.db.IteratePrefix(smt.dbNamespace, nil)
+	defer iter.Close()
+	for iter.Valid() {
+		key, err := iter.Key()
+		if err != nil {
+			return nil, err
+		}
+		// Skip metadata such as the init marker
+		if len(key) == smt.keySize() {
+			marked, err := marker.isMarked(key)
+			if err != nil {
+				return nil, err
+			}
+			if !marked {
+				value, err := iter.Value()
+				if err != nil {
+					return nil, err
+				}
+				chunk = append(chunk, append([]byte{}, key...))
+				chunkBytes = append(chunkBytes, int64(keyPrefixLen+len(key)+len(value)))
+			}
+		}
+		if len(chunk) == pruneChunkSize {
+			err = smt.sweepChunk(marker, chunk, chunkBytes, stats)
+			if err != nil {
+				return nil, err
+			}
+			chunk, chunkBytes = chunk[:0], chunkBytes[:0]
+		}
+		err = iter.Next()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(chunk) > 0 {
+		err = smt.sweepChunk(marker, chunk, chunkBytes, stats)
+		if err != nil {
+			return nil, err
+		}
+	}
+	stats.MarkedNodes = marker.count
+	err = marker.clear()
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// sweepChunk deletes the nodes of keys that are still unmarked after marking the current roots
+func (smt *SparseMerkleTree) sweepChunk(
+	marker *pruneMarker, keys [][]byte, sizes []int64, stats *PruneStats) error {
+	smt.writeLock.Lock()
+	defer smt.writeLock.Unlock()
+	// Nodes written since the roots were marked may have reused the hash of an unmarked node
+	_, err := marker.markRoots()
+	if err != nil {
+		return err
+	}
+	var deleted [][]byte
+	bulk := smt.db.NewBulk()
+	for i, key := range keys {
+		marked, err := marker.isMarked(key)
+		if err != nil {
+			return err
+		}
+		if marked {
+			continue
+		}
+		err = bulk.Delete(smt.dbNamespace, key)
+		if err != nil {
+			return err
+		}
+		deleted = append(deleted, key)
+		stats.DeletedBytes += sizes[i]
+	}
+	err = bulk.Flush()
+	if err != nil {
+		return err
+	}
+	for _, key := range deleted {
+		smt.uncacheNode(key)
+	}
+	stats.DeletedNodes += len(deleted)
+	return nil
+}
+
+// pruneMarker marks the reachable nodes of a tree in a separate DB namespace. A marked node is only ever
+// stored after its whole subtree is marked, so marking stops at marked nodes.
+type pruneMarker struct {
+	smt          *SparseMerkleTree
+	namespace    []byte
+	defaultNodes map[string]bool
+	count        int
+}
+
+func newPruneMarker(smt *SparseMerkleTree) *pruneMarker {
+	defaultNodes := make(map[string]bool)
+	hasherSizeBits := smt.hasherSize * 8
+	for i := hasherSizeBits - smt.height; i < hasherSizeBits; i++ {
+		defaultNodes[string(smt.defaultNode(i))] = true
+	}
+	return &pruneMarker{
+		smt:          smt,
+		namespace:    append(append([]byte{}, smt.dbNamespace...), pruneMarkSuffix...),
+		defaultNodes: defaultNodes,
+		count:        len(defaultNodes),
+	}
+}
+
+func (m *pruneMarker) isMarked(hash []byte) (bool, error) {
+	if m.defaultNodes[string(hash)] {
+		return true, nil
+	}
+	return m.smt.db.Exist(m.namespace, hash)
+}
+
+// markRoots marks the nodes of the retained roots and the current root, and returns the number of roots
+func (m *pruneMarker) markRoots() (int, error) {
+	roots, err := m.smt.RetainedRoots()
+	if err != nil {
+		return 0, err
+	}
+	roots = append(roots, m.smt.Root())
+	for _, root := range roots {
+		// Flush every root, so that the subtrees it shares with the next roots are skipped
+		bulk := m.smt.db.NewBulk()
+		err = m.markNodes(bulk, root, 0)
+		if err != nil {
+			return 0, err
+		}
+		err = bulk.Flush()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(roots), nil
+}
+
+// markNodes marks the subtree below the node at depth
+func (m *pruneMarker) markNodes(bulk rollupdb.Bulk, hash []byte, depth int) error {
+	marked, err := m.isMarked(hash)
+	if err != nil || marked {
+		return err
+	}
+	if depth < m.smt.height-1 {
+		value, err := m.smt.getNode(hash)
+		if err != nil {
+			return err
+		}
+		err = m.markNodes(bulk, value[:m.smt.keySize()], depth+1)
+		if err != nil {
+			return err
+		}
+		err = m.markNodes(bulk, value[m.smt.keySize():], depth+1)
+		if err != nil {
+			return err
+		}
+	}
+	m.count++
+	return bulk.Set(m.namespace, hash, rollupdb.EmptyKey)
+}
+
+// clear deletes all marks in chunks
+func (m *pruneMarker) clear() error {
+	for {
+		var keys [][]byte
+		iter := m.smt.db.IteratePrefix(m.namespace, nil)
+		for iter.Valid() && len(keys) < pruneChunkSize {
+			key, err := iter.Key()
+			if err != nil {
+				iter.Close()
+				return err
+			}
+			keys = append(keys, append([]byte{}, key...))
+			err = iter.Next()
+			if err != nil {
+				iter.Close()
+				return err
+			}
+		}
+		iter.Close()
+		if len(keys) == 0 {
+			return nil
+		}
+		bulk := m.smt.db.NewBulk()
+		for _, key := range keys {
+			err := bulk.Delete(m.namespace, key)
+			if err != nil {
+				return err
+			}
+		}
+		err := bulk.Flush()
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package smt
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/celer-network/go-rollup/db/memorydb"
+)
+
+func TestPrune(t *testing.T) {
+	db := memorydb.NewDB()
+	smt, err := NewSparseMerkleTree(
+		db, namespaceTestTrie, HashKeccak256, nil, 160, false, WithCacheSize(1024), WithRetainedRootsLimit(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roots [][]byte
+	for i := 0; i < 6; i++ {
+		for j := 0; j < 4; j++ {
+			_, err := smt.Update(big.NewInt(int64(j)).Bytes(), []byte{byte(i), byte(j)})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err = smt.RetainRoot(smt.Root())
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, smt.Root())
+	}
+	// Not retained, but current
+	_, err = smt.Update(big.NewInt(10).Bytes(), []byte("current"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := smt.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.RetainedRoots != 3 || stats.DeletedNodes == 0 || stats.DeletedBytes == 0 {
+		t.Errorf("unexpected prune stats %+v", stats)
+	}
+	for i, root := range roots {
+		value, err := smt.GetForRoot(big.NewInt(1).Bytes(), root)
+		if i < 3 {
+			if err == nil {
+				t.Errorf("root %d should have been pruned", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("retained root %d was pruned: %v", i, err)
+		}
+		if !bytes.Equal(value, []byte{byte(i), 1}) {
+			t.Errorf("wrong value at retained root %d", i)
+		}
+	}
+	value, err := smt.Get(big.NewInt(10).Bytes())
+	if err != nil || !bytes.Equal(value, []byte("current")) {
+		t.Error("current root was pruned")
+	}
+
+	stats, err = smt.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.DeletedNodes != 0 {
+		t.Errorf("second prune deleted %d nodes", stats.DeletedNodes)
+	}
+
+	// The tree stays usable and agrees with an unpruned tree
+	restored, err := NewSparseMerkleTree(db, namespaceTestTrie, HashKeccak256, smt.Root(), 160, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unpruned, err := NewSparseMerkleTree(memorydb.NewDB(), namespaceTestTrie, HashKeccak256, nil, 160, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for j := 0; j < 4; j++ {
+		unpruned.Update(big.NewInt(int64(j)).Bytes(), []byte{5, byte(j)})
+	}
+	unpruned.Update(big.NewInt(10).Bytes(), []byte("current"))
+	restored.Update(big.NewInt(11).Bytes(), []byte("new"))
+	unpruned.Update(big.NewInt(11).Bytes(), []byte("new"))
+	if !bytes.Equal(restored.Root(), unpruned.Root()) {
+		t.Error("pruned tree diverged from unpruned tree")
+	}
+}
+
+func TestRetainRoot(t *testing.T) {
+	smt, err := NewSparseMerkleTree(
+		memorydb.NewDB(), namespaceTestTrie, HashKeccak256, nil, 8, false, WithRetainedRootsLimit(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		smt.Update([]byte{byte(i)}, []byte("value"))
+		// Retaining the same root twice keeps one copy
+		smt.RetainRoot(smt.Root())
+		smt.RetainRoot(smt.Root())
+	}
+	roots, err := smt.RetainedRoots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 2 || !bytes.Equal(roots[1], smt.Root()) {
+		t.Errorf("unexpected retained roots %x", roots)
+	}
+}
+
+func TestPruneConcurrentWrites(t *testing.T) {
+	db := memorydb.NewDB()
+	smt, err := NewSparseMerkleTree(
+		db, namespaceTestTrie, HashKeccak256, nil, 160, false, WithCacheSize(1024), WithRetainedRootsLimit(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Leave more stale nodes than fit in one sweep chunk
+	for i := 0; i < 2*pruneChunkSize/160; i++ {
+		_, err := smt.Update(big.NewInt(int64(i%8)).Bytes(), []byte{byte(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = smt.RetainRoot(smt.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Write and retain roots while pruning, going back to earlier values, whose nodes may be swept
+	done := make(chan struct{})
+	values := make(map[int64][]byte)
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			key := int64(i % 8)
+			value := []byte{byte(i % 3)}
+			_, err := smt.Update(big.NewInt(key).Bytes(), value)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			values[key] = value
+			if i%10 == 0 {
+				err = smt.RetainRoot(smt.Root())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}
+	}()
+	var deletedNodes int
+	for pruning := true; pruning; {
+		select {
+		case <-done:
+			pruning = false
+		default:
+		}
+		stats, err := smt.Prune()
+		if err != nil {
+			t.Fatal(err)
+		}
+		deletedNodes += stats.DeletedNodes
+	}
+	if deletedNodes < pruneChunkSize {
+		t.Errorf("deleted %d nodes, expected more than a chunk", deletedNodes)
+	}
+
+	roots, err := smt.RetainedRoots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, root := range append(roots, smt.Root()) {
+		for key := int64(0); key < 8; key++ {
+			if _, err := smt.GetForRoot(big.NewInt(key).Bytes(), root); err != nil {
+				t.Fatalf("key %d at root %x was pruned: %v", key, root, err)
+			}
+		}
+	}
+	for key, value := range values {
+		got, err := smt.Get(big.NewInt(key).Bytes())
+		if err != nil || !bytes.Equal(got, value) {
+			t.Errorf("key %d has value %x, expected %x: %v", key, got, value, err)
+		}
+	}
+	// The marks are removed
+	iter := db.IteratePrefix(append(append([]byte{}, namespaceTestTrie...), pruneMarkSuffix...), nil)
+	defer iter.Close()
+	if iter.Valid() {
+		t.Error("prune marks left in the db")
+	}
+}
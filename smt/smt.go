@@ -2,10 +2,12 @@
 package smt
 
 import (
-	"errors"
+	"fmt"
 	"hash"
+	"sync"
 
 	rollupdb "github.com/celer-network/go-rollup/db"
+	lru "github.com/hashicorp/golang-lru"
 )
 
 const left = 0
@@ -17,31 +19,71 @@ var (
 )
 
 // SparseMerkleTree is a Sparse Merkle tree.
+// It is safe for concurrent readers, and writers are serialized.
 type SparseMerkleTree struct {
-	hasher      hash.Hash
-	db          rollupdb.DB
-	dbNamespace []byte
-	root        []byte
-	height      int
-	hashKey     bool
+	hashName string
+	// Hashers are not safe for concurrent use, so every call takes its own from the pool
+	hasherPool   sync.Pool
+	hasherSize   int
+	defaultNodes [][]byte
+	db           rollupdb.DB
+	dbNamespace  []byte
+	rootLock     sync.RWMutex
+	root         []byte
+	height       int
+	hashKey      bool
+	nodeCache    *lru.Cache
+	// Serializes writers with pruning, so that new nodes are never swept before their root is set
+	writeLock sync.Mutex
+	// Serializes Prune calls, which share the prune marks
+	pruneLock          sync.Mutex
+	retainedRootsLimit int
 }
 
 // NewSparseMerkleTree creates or restores a Sparse Merkle tree with a DB.
-func NewSparseMerkleTree(db rollupdb.DB, dbNamespace []byte, hasher hash.Hash, root []byte, height int, hashKey bool) (*SparseMerkleTree, error) {
+func NewSparseMerkleTree(
+	db rollupdb.DB,
+	dbNamespace []byte,
+	hashName string,
+	root []byte,
+	height int,
+	hashKey bool,
+	options ...Option,
+) (*SparseMerkleTree, error) {
+	newHasher, err := GetHash(hashName)
+	if err != nil {
+		return nil, err
+	}
+	hasher := newHasher()
 	smt := SparseMerkleTree{
-		hasher:      hasher,
-		db:          db,
-		dbNamespace: dbNamespace,
-		height:      height,
-		hashKey:     hashKey,
+		hashName:           hashName,
+		hasherPool:         sync.Pool{New: func() interface{} { return newHasher() }},
+		hasherSize:         hasher.Size(),
+		defaultNodes:       defaultNodes(hasher),
+		db:                 db,
+		dbNamespace:        dbNamespace,
+		height:             height,
+		hashKey:            hashKey,
+		retainedRootsLimit: DefaultRetainedRootsLimit,
+	}
+	for _, option := range options {
+		err := option(&smt)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	hasherSizeBits := hasher.Size() * 8
-	_, exists, err := db.Get(dbNamespace, initMarker)
+	storedHashName, exists, err := db.Get(dbNamespace, initMarker)
 	if err != nil {
 		return nil, err
 	}
-	if !exists {
+	if exists {
+		err = smt.checkHashName(storedHashName)
+		if err != nil {
+			return nil, err
+		}
+	} else {
 		bulk := db.NewBulk()
 		for i := hasherSizeBits - height; i < hasherSizeBits-1; i++ {
 			err := bulk.Set(dbNamespace, smt.defaultNode(i), append(smt.defaultNode(i+1), smt.defaultNode(i+1)...))
@@ -53,7 +95,10 @@ func NewSparseMerkleTree(db rollupdb.DB, dbNamespace []byte, hasher hash.Hash, r
 		if err != nil {
 			return nil, err
 		}
-		err = bulk.Set(dbNamespace, initMarker, []byte{})
+		err = bulk.Set(dbNamespace, initMarker, []byte(hashName))
+		if err != nil {
+			return nil, err
+		}
 		err = bulk.Flush()
 		if err != nil {
 			return nil, err
@@ -70,13 +115,46 @@ func NewSparseMerkleTree(db rollupdb.DB, dbNamespace []byte, hasher hash.Hash, r
 	return &smt, nil
 }
 
+// checkHashName checks the hash recorded in the init marker against the hash of the tree. Trees created before
+// hashes were recorded have an empty marker: they are accepted if their default leaf matches the hash, and the
+// marker is upgraded.
+func (smt *SparseMerkleTree) checkHashName(storedHashName []byte) error {
+	if len(storedHashName) > 0 {
+		if string(storedHashName) != smt.hashName {
+			return fmt.Errorf("Tree was created with hash %s, not %s", storedHashName, smt.hashName)
+		}
+		return nil
+	}
+	_, exists, err := smt.db.Get(smt.dbNamespace, smt.defaultNode(smt.hasherSize*8-1))
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("Tree was not created with hash %s", smt.hashName)
+	}
+	return smt.db.Set(smt.dbNamespace, initMarker, []byte(smt.hashName))
+}
+
+func (smt *SparseMerkleTree) getHasher() hash.Hash {
+	return smt.hasherPool.Get().(hash.Hash)
+}
+
+func (smt *SparseMerkleTree) putHasher(hasher hash.Hash) {
+	hasher.Reset()
+	smt.hasherPool.Put(hasher)
+}
+
 // Root gets the root of the tree.
 func (smt *SparseMerkleTree) Root() []byte {
+	smt.rootLock.RLock()
+	defer smt.rootLock.RUnlock()
 	return smt.root
 }
 
 // SetRoot sets the root of the tree.
 func (smt *SparseMerkleTree) SetRoot(root []byte) {
+	smt.rootLock.Lock()
+	defer smt.rootLock.Unlock()
 	smt.root = root
 }
 
@@ -84,23 +162,28 @@ func (smt *SparseMerkleTree) Height() int {
 	return smt.height
 }
 
+// HashName returns the name of the hash function of the tree.
+func (smt *SparseMerkleTree) HashName() string {
+	return smt.hashName
+}
+
 func (smt *SparseMerkleTree) IsHashKey() bool {
 	return smt.hashKey
 }
 
 func (smt *SparseMerkleTree) keySize() int {
-	return smt.hasher.Size()
+	return smt.hasherSize
 }
 
 func (smt *SparseMerkleTree) defaultNode(height int) []byte {
-	return defaultNodes(smt.hasher)[height]
+	return smt.defaultNodes[height]
 }
 
 func (smt *SparseMerkleTree) digest(data []byte) []byte {
-	smt.hasher.Write(data)
-	sum := smt.hasher.Sum(nil)
-	smt.hasher.Reset()
-	return sum
+	hasher := smt.getHasher()
+	defer smt.putHasher(hasher)
+	hasher.Write(data)
+	return hasher.Sum(nil)
 }
 
 // Get gets a key from the tree.
@@ -117,13 +200,10 @@ func (smt *SparseMerkleTree) GetForRoot(key []byte, root []byte) ([]byte, error)
 	}
 	currentHash := root
 	for i := 0; i < smt.height-1; i++ {
-		currentValue, exists, err := smt.db.Get(smt.dbNamespace, currentHash)
+		currentValue, err := smt.getNode(currentHash)
 		if err != nil {
 			return nil, err
 		}
-		if !exists {
-			return nil, errors.New("Corrupt db")
-		}
 		if !isLeft(path, i, smt.height) {
 			currentHash = currentValue[smt.keySize():]
 		} else {
@@ -131,20 +211,14 @@ func (smt *SparseMerkleTree) GetForRoot(key []byte, root []byte) ([]byte, error)
 		}
 	}
 
-	value, exists, err := smt.db.Get(smt.dbNamespace, currentHash)
-	if err != nil {
-		return nil, err
-	}
-	if !exists {
-		return nil, errors.New("Corrupt db")
-	}
-
-	return value, nil
+	return smt.getNode(currentHash)
 }
 
 // Update sets a new value for a key in the tree, returns the new root, and sets the new current root of the tree.
 func (smt *SparseMerkleTree) Update(key []byte, value []byte) ([]byte, error) {
-	newRoot, err := smt.UpdateForRoot(key, value, smt.Root())
+	smt.writeLock.Lock()
+	defer smt.writeLock.Unlock()
+	newRoot, err := smt.updateForRoot(key, value, smt.Root())
 	if err == nil {
 		smt.SetRoot(newRoot)
 	}
@@ -153,6 +227,12 @@ func (smt *SparseMerkleTree) Update(key []byte, value []byte) ([]byte, error) {
 
 // UpdateForRoot sets a new value for a key in the tree at a specific root, and returns the new root.
 func (smt *SparseMerkleTree) UpdateForRoot(key []byte, value []byte, root []byte) ([]byte, error) {
+	smt.writeLock.Lock()
+	defer smt.writeLock.Unlock()
+	return smt.updateForRoot(key, value, root)
+}
+
+func (smt *SparseMerkleTree) updateForRoot(key []byte, value []byte, root []byte) ([]byte, error) {
 	path, err := smt.getPath(key)
 	if err != nil {
 		return nil, err
@@ -168,11 +248,16 @@ func (smt *SparseMerkleTree) UpdateForRoot(key []byte, value []byte, root []byte
 
 func (smt *SparseMerkleTree) updateWithSideNodes(path []byte, value []byte, sideNodes [][]byte) ([]byte, error) {
 	bulk := smt.db.NewBulk()
+	hashes := make([][]byte, 0, smt.height)
+	values := make([][]byte, 0, smt.height)
 	currentHash := smt.digest(value)
 	err := bulk.Set(smt.dbNamespace, currentHash, value)
 	if err != nil {
 		return nil, err
 	}
+	hashes = append(hashes, currentHash)
+	// Copy the leaf, so that the caller cannot change the cached value
+	values = append(values, append([]byte{}, value...))
 	currentValue := currentHash
 
 	for i := smt.height - 2; i >= 0; i-- {
@@ -188,49 +273,40 @@ func (smt *SparseMerkleTree) updateWithSideNodes(path []byte, value []byte, side
 		if err != nil {
 			return nil, err
 		}
+		hashes = append(hashes, currentHash)
+		values = append(values, currentValue)
 		currentValue = currentHash
 	}
 	err = bulk.Flush()
 	if err != nil {
 		return nil, err
 	}
+	smt.cacheNodes(hashes, values)
 
 	return currentHash, nil
 }
 
 func (smt *SparseMerkleTree) sideNodesForRoot(path []byte, root []byte) ([][]byte, error) {
-	currentValue, exists, err := smt.db.Get(smt.dbNamespace, root)
+	currentValue, err := smt.getNode(root)
 	if err != nil {
 		return nil, err
 	}
-	if !exists {
-		return nil, errors.New("Corrupt db")
-	}
 
 	sideNodes := make([][]byte, smt.height-1)
 	for i := 0; i < smt.height-1; i++ {
 		if !isLeft(path, i, smt.height) {
 			sideNodes[i] = currentValue[:smt.keySize()]
-			currentValue, exists, err = smt.db.Get(smt.dbNamespace, currentValue[smt.keySize():])
-			if err != nil {
-				return nil, err
-			}
-			if !exists {
-				return nil, errors.New("Corrupt db")
-			}
+			currentValue, err = smt.getNode(currentValue[smt.keySize():])
 		} else {
 			sideNodes[i] = currentValue[smt.keySize():]
-			currentValue, exists, err = smt.db.Get(smt.dbNamespace, currentValue[:smt.keySize()])
-			if err != nil {
-				return nil, err
-			}
-			if !exists {
-				return nil, errors.New("Corrupt db")
-			}
+			currentValue, err = smt.getNode(currentValue[:smt.keySize()])
+		}
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	return sideNodes, err
+	return sideNodes, nil
 }
 
 // Prove generates a Merkle proof for a key.
@@ -274,27 +350,8 @@ func (smt *SparseMerkleTree) ProveCompactForRoot(key []byte, root []byte) ([][]b
 	return compactedProof, err
 }
 
-func (smt *SparseMerkleTree) padKey(key []byte) ([]byte, error) {
-	keyLength := len(key)
-	requiredKeyLength := smt.hasher.Size()
-	if keyLength > requiredKeyLength {
-		return nil, errors.New("Key too long")
-	}
-	padded := make([]byte, requiredKeyLength)
-	copy(padded[requiredKeyLength-keyLength:], key)
-	return padded, nil
-}
-
 func (smt *SparseMerkleTree) getPath(key []byte) ([]byte, error) {
-	var path []byte
-	var err error
-	if smt.hashKey {
-		path = smt.digest(key)
-	} else {
-		path, err = smt.padKey(key)
-		if err != nil {
-			return nil, err
-		}
-	}
-	return path, nil
+	hasher := smt.getHasher()
+	defer smt.putHasher(hasher)
+	return computePath(key, hasher, smt.hashKey)
 }
@@ -2,12 +2,13 @@ package smt
 
 import (
 	"bytes"
+	"errors"
 	"math/big"
+	"sync"
 	"testing"
 
 	"github.com/celer-network/go-rollup/db/memorydb"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/minio/sha256-simd"
 	"golang.org/x/crypto/sha3"
 )
 
@@ -15,7 +16,7 @@ var namespaceTestTrie = []byte("tt")
 
 func TestSMTNumericalKey(t *testing.T) {
 	db := memorydb.NewDB()
-	smt, err := NewSparseMerkleTree(db, namespaceTestTrie, sha3.NewLegacyKeccak256(), nil, 4, false)
+	smt, err := NewSparseMerkleTree(db, namespaceTestTrie, HashKeccak256, nil, 4, false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -51,7 +52,7 @@ func TestSMTNumericalKey(t *testing.T) {
 
 func TestSparseMerkleTree(t *testing.T) {
 	db := memorydb.NewDB()
-	smt, err := NewSparseMerkleTree(db, namespaceTestTrie, sha256.New(), nil, 256, true)
+	smt, err := NewSparseMerkleTree(db, namespaceTestTrie, HashSHA256, nil, 256, true)
 	if err != nil {
 		t.Error(err)
 	}
@@ -145,7 +146,7 @@ func TestSparseMerkleTree(t *testing.T) {
 		t.Error("did not get correct value when getting non-empty key")
 	}
 
-	smt2, err := NewSparseMerkleTree(db, namespaceTestTrie, sha256.New(), smt.Root(), smt.Height(), smt.IsHashKey())
+	smt2, err := NewSparseMerkleTree(db, namespaceTestTrie, HashSHA256, smt.Root(), smt.Height(), smt.IsHashKey())
 	if err != nil {
 		t.Error("error importing smt")
 	}
@@ -158,3 +159,62 @@ func TestSparseMerkleTree(t *testing.T) {
 		t.Error("did not get correct value when getting non-empty key")
 	}
 }
+
+func TestConcurrentReaders(t *testing.T) {
+	smt, err := NewSparseMerkleTree(
+		memorydb.NewDB(), namespaceTestTrie, HashKeccak256, nil, 160, false, WithCacheSize(256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const numWrites = 50
+	done := make(chan struct{})
+	errs := make(chan error, 8)
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			key := big.NewInt(int64(r)).Bytes()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				// A reader always works on one root, which may be replaced by the writer at any time
+				root := smt.Root()
+				value, err := smt.GetForRoot(key, root)
+				if err != nil {
+					errs <- err
+					return
+				}
+				proof, err := smt.ProveForRoot(key, root)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if !VerifyProof(proof, root, key, value, sha3.NewLegacyKeccak256(), 160, false) {
+					errs <- errors.New("proof failed to verify")
+					return
+				}
+			}
+		}(r)
+	}
+	for i := 0; i < numWrites; i++ {
+		key := big.NewInt(int64(i % 8)).Bytes()
+		if i%2 == 0 {
+			_, err = smt.Update(key, []byte{byte(i)})
+		} else {
+			_, err = smt.UpdateBatch([][]byte{key, big.NewInt(int64(i)).Bytes()}, [][]byte{{byte(i)}, {byte(i)}})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
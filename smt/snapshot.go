@@ -0,0 +1,291 @@
+package smt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// A snapshot holds all non-default leaves under a root, in path order:
+//
+//	magic | version | hash name | height | hashKey | root | (1 | path | value)* | 0 | leaf count
+//
+// Byte strings are prefixed with their uvarint length and numbers are uvarints. Paths are hasher-size bytes,
+// as keys are not known for trees that hash them.
+
+var snapshotMagic = []byte("SMTS")
+
+const (
+	snapshotVersion = 1
+	// Leaves are imported in batches, so that imports do not hold the whole state in memory
+	snapshotImportBatchSize = 1024
+	// Bounds the length of byte strings, so that a corrupt length cannot exhaust memory
+	maxSnapshotBytesLength = 1 << 20
+)
+
+type snapshotHeader struct {
+	hashName string
+	height   int
+	hashKey  bool
+	root     []byte
+}
+
+// ExportSnapshot writes all non-default leaves under root to w, and returns the number of leaves written.
+func (smt *SparseMerkleTree) ExportSnapshot(w io.Writer, root []byte) (int, error) {
+	writer := bufio.NewWriter(w)
+	header := &snapshotHeader{
+		hashName: smt.hashName,
+		height:   smt.height,
+		hashKey:  smt.hashKey,
+		root:     root,
+	}
+	err := writeSnapshotHeader(writer, header)
+	if err != nil {
+		return 0, err
+	}
+	it, err := smt.NewLeafIterator(root, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for it.Valid() {
+		count++
+		err = writer.WriteByte(1)
+		if err != nil {
+			return 0, err
+		}
+		err = writeBytes(writer, it.Key())
+		if err != nil {
+			return 0, err
+		}
+		err = writeBytes(writer, it.Value())
+		if err != nil {
+			return 0, err
+		}
+		err = it.Next()
+		if err != nil {
+			return 0, err
+		}
+	}
+	err = writer.WriteByte(0)
+	if err != nil {
+		return 0, err
+	}
+	err = writeUvarint(writer, uint64(count))
+	if err != nil {
+		return 0, err
+	}
+	return count, writer.Flush()
+}
+
+// ImportSnapshot rebuilds the tree from a snapshot written by ExportSnapshot, checks that the rebuilt root is
+// the root of the snapshot, sets it as the current root and returns it. The snapshot must have been exported
+// from a tree with the same hash, height and key mode. If expectedRoot is not nil, the snapshot must be of
+// expectedRoot, which is checked before anything is imported. Once the root is checked, onLeaf is called for
+// every imported leaf in path order, unless it is nil. It gets the path of the leaf, which is the padded key for
+// trees that do not hash keys.
+func (smt *SparseMerkleTree) ImportSnapshot(
+	r io.Reader, expectedRoot []byte, onLeaf func(path []byte, value []byte) error) ([]byte, error) {
+	reader := bufio.NewReader(r)
+	header, err := readSnapshotHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+	if header.hashName != smt.hashName || header.height != smt.height || header.hashKey != smt.hashKey {
+		return nil, fmt.Errorf(
+			"Snapshot of a tree with hash %s, height %d and hashKey %t does not match the tree",
+			header.hashName, header.height, header.hashKey)
+	}
+	if expectedRoot != nil && !bytes.Equal(header.root, expectedRoot) {
+		return nil, fmt.Errorf("Snapshot of root %x, expected %x", header.root, expectedRoot)
+	}
+	root, err := smt.importLeaves(reader)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(root, header.root) {
+		return nil, errors.New("Snapshot root mismatch")
+	}
+	smt.SetRoot(root)
+	if onLeaf == nil {
+		return root, nil
+	}
+	it, err := smt.NewLeafIterator(root, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	for it.Valid() {
+		err = onLeaf(it.Key(), it.Value())
+		if err != nil {
+			return nil, err
+		}
+		err = it.Next()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return root, nil
+}
+
+func (smt *SparseMerkleTree) importLeaves(reader *bufio.Reader) ([]byte, error) {
+	smt.writeLock.Lock()
+	defer smt.writeLock.Unlock()
+	maxPath := new(big.Int).Lsh(big.NewInt(1), uint(smt.height-1))
+	root := smt.defaultNode(smt.hasherSize*8 - smt.height)
+	var entries []*batchEntry
+	var lastPath *big.Int
+	count := uint64(0)
+	for {
+		marker, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if marker == 0 {
+			break
+		}
+		if marker != 1 {
+			return nil, errors.New("Corrupt snapshot")
+		}
+		pathData, err := readBytes(reader)
+		if err != nil {
+			return nil, err
+		}
+		value, err := readBytes(reader)
+		if err != nil {
+			return nil, err
+		}
+		path := new(big.Int).SetBytes(pathData)
+		if len(pathData) != smt.keySize() || path.Cmp(maxPath) >= 0 ||
+			(lastPath != nil && path.Cmp(lastPath) <= 0) {
+			return nil, errors.New("Corrupt snapshot")
+		}
+		lastPath = path
+		entries = append(entries, &batchEntry{path: path, value: value})
+		count++
+		if len(entries) == snapshotImportBatchSize {
+			root, err = smt.updateEntriesForRoot(entries, root)
+			if err != nil {
+				return nil, err
+			}
+			entries = nil
+		}
+	}
+	if len(entries) > 0 {
+		var err error
+		root, err = smt.updateEntriesForRoot(entries, root)
+		if err != nil {
+			return nil, err
+		}
+	}
+	expectedCount, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	if expectedCount != count {
+		return nil, errors.New("Snapshot leaf count mismatch")
+	}
+	return root, nil
+}
+
+func writeSnapshotHeader(writer *bufio.Writer, header *snapshotHeader) error {
+	_, err := writer.Write(snapshotMagic)
+	if err != nil {
+		return err
+	}
+	err = writer.WriteByte(snapshotVersion)
+	if err != nil {
+		return err
+	}
+	err = writeBytes(writer, []byte(header.hashName))
+	if err != nil {
+		return err
+	}
+	err = writeUvarint(writer, uint64(header.height))
+	if err != nil {
+		return err
+	}
+	hashKey := byte(0)
+	if header.hashKey {
+		hashKey = 1
+	}
+	err = writer.WriteByte(hashKey)
+	if err != nil {
+		return err
+	}
+	return writeBytes(writer, header.root)
+}
+
+func readSnapshotHeader(reader *bufio.Reader) (*snapshotHeader, error) {
+	magic := make([]byte, len(snapshotMagic))
+	_, err := io.ReadFull(reader, magic)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(magic, snapshotMagic) {
+		return nil, errors.New("Not a snapshot")
+	}
+	version, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if version != snapshotVersion {
+		return nil, fmt.Errorf("Unsupported snapshot version %d", version)
+	}
+	hashName, err := readBytes(reader)
+	if err != nil {
+		return nil, err
+	}
+	height, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	hashKey, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	root, err := readBytes(reader)
+	if err != nil {
+		return nil, err
+	}
+	return &snapshotHeader{
+		hashName: string(hashName),
+		height:   int(height),
+		hashKey:  hashKey == 1,
+		root:     root,
+	}, nil
+}
+
+func writeUvarint(writer *bufio.Writer, n uint64) error {
+	buf := make([]byte, binary.MaxVarintLen64)
+	_, err := writer.Write(buf[:binary.PutUvarint(buf, n)])
+	return err
+}
+
+func writeBytes(writer *bufio.Writer, data []byte) error {
+	err := writeUvarint(writer, uint64(len(data)))
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	return err
+}
+
+func readBytes(reader *bufio.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	if length > maxSnapshotBytesLength {
+		return nil, errors.New("Corrupt snapshot")
+	}
+	data := make([]byte, length)
+	_, err = io.ReadFull(reader, data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
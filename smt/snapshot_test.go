@@ -0,0 +1,170 @@
+package smt
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/celer-network/go-rollup/db/memorydb"
+)
+
+func testSnapshot(t *testing.T, hashName string, height int, hashKey bool, keys [][]byte) {
+	smt, err := NewSparseMerkleTree(memorydb.NewDB(), namespaceTestTrie, hashName, nil, height, hashKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make([][]byte, len(keys))
+	for i := range keys {
+		values[i] = []byte(fmt.Sprintf("testValue%d", i))
+	}
+	root, err := smt.UpdateBatch(keys, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The exported root is not the current root
+	_, err = smt.Update(keys[0], []byte("newValue"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var snapshot bytes.Buffer
+	count, err := smt.ExportSnapshot(&snapshot, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != len(keys) {
+		t.Errorf("exported %d leaves instead of %d", count, len(keys))
+	}
+
+	imported, err := NewSparseMerkleTree(memorydb.NewDB(), namespaceTestTrie, hashName, nil, height, hashKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths [][]byte
+	importedRoot, err := imported.ImportSnapshot(bytes.NewReader(snapshot.Bytes()), nil, func(path []byte, value []byte) error {
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(importedRoot, root) || !bytes.Equal(imported.Root(), root) {
+		t.Error("imported root differs from exported root")
+	}
+	if len(paths) != len(keys) {
+		t.Errorf("onLeaf called for %d leaves instead of %d", len(paths), len(keys))
+	}
+	for i := 1; i < len(paths); i++ {
+		if bytes.Compare(paths[i-1], paths[i]) >= 0 {
+			t.Error("leaves not imported in path order")
+		}
+	}
+	for i, key := range keys {
+		value, err := imported.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(value, values[i]) {
+			t.Error("did not get correct value from imported tree")
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	var numericalKeys, stringKeys [][]byte
+	for i := 0; i < 2*snapshotImportBatchSize+1; i++ {
+		numericalKeys = append(numericalKeys, big.NewInt(int64(i*7)).Bytes())
+		stringKeys = append(stringKeys, []byte(fmt.Sprintf("testKey%d", i)))
+	}
+	testSnapshot(t, HashKeccak256, 160, false, numericalKeys)
+	testSnapshot(t, HashSHA256, 256, true, stringKeys)
+	testSnapshot(t, HashKeccak256, 8, false, numericalKeys[:20])
+}
+
+func TestSnapshotEmptyTree(t *testing.T) {
+	smt, err := NewSparseMerkleTree(memorydb.NewDB(), namespaceTestTrie, HashKeccak256, nil, 160, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snapshot bytes.Buffer
+	count, err := smt.ExportSnapshot(&snapshot, smt.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("exported %d leaves of an empty tree", count)
+	}
+	imported, err := NewSparseMerkleTree(memorydb.NewDB(), namespaceTestTrie, HashKeccak256, nil, 160, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := imported.ImportSnapshot(&snapshot, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(root, smt.Root()) {
+		t.Error("imported root of an empty tree differs")
+	}
+}
+
+func TestSnapshotInvalid(t *testing.T) {
+	smt, err := NewSparseMerkleTree(memorydb.NewDB(), namespaceTestTrie, HashKeccak256, nil, 160, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i < 10; i++ {
+		_, err = smt.Update(big.NewInt(i).Bytes(), []byte("testValue"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var snapshot bytes.Buffer
+	_, err = smt.ExportSnapshot(&snapshot, smt.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := snapshot.Bytes()
+
+	newTree := func(hashName string, height int) *SparseMerkleTree {
+		tree, err := NewSparseMerkleTree(memorydb.NewDB(), namespaceTestTrie, hashName, nil, height, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tree
+	}
+	_, err = newTree(HashSHA256, 160).ImportSnapshot(bytes.NewReader(data), nil, nil)
+	if err == nil {
+		t.Error("importing a snapshot with a different hash did not return error")
+	}
+	_, err = newTree(HashKeccak256, 100).ImportSnapshot(bytes.NewReader(data), nil, nil)
+	if err == nil {
+		t.Error("importing a snapshot with a different height did not return error")
+	}
+
+	_, err = newTree(HashKeccak256, 160).ImportSnapshot(bytes.NewReader(data), []byte("otherRoot"), nil)
+	if err == nil {
+		t.Error("importing a snapshot of another root did not return error")
+	}
+
+	tampered := append([]byte{}, data...)
+	valueIndex := bytes.LastIndex(tampered, []byte("testValue"))
+	tampered[valueIndex] = 'T'
+	imported := newTree(HashKeccak256, 160)
+	_, err = imported.ImportSnapshot(bytes.NewReader(tampered), nil, nil)
+	if err == nil {
+		t.Error("importing a tampered snapshot did not return error")
+	}
+	if !bytes.Equal(imported.Root(), newTree(HashKeccak256, 160).Root()) {
+		t.Error("importing a tampered snapshot changed the root")
+	}
+
+	_, err = newTree(HashKeccak256, 160).ImportSnapshot(bytes.NewReader(data[:len(data)-10]), nil, nil)
+	if err == nil {
+		t.Error("importing a truncated snapshot did not return error")
+	}
+	_, err = newTree(HashKeccak256, 160).ImportSnapshot(bytes.NewReader([]byte("not a snapshot")), nil, nil)
+	if err == nil {
+		t.Error("importing garbage did not return error")
+	}
+}
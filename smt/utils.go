@@ -34,6 +34,16 @@ func countSetBits(data []byte) int {
 	return count
 }
 
+// hasSetBitsFrom returns whether a bit at position or after is set
+func hasSetBitsFrom(data []byte, position int) bool {
+	for i := position; i < len(data)*8; i++ {
+		if hasBit(data, i) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
 func emptyBytes(length int) []byte {
 	b := make([]byte, length)
 	return b
@@ -1,53 +1,143 @@
 package statemachine
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math/big"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/rs/zerolog/log"
-	"golang.org/x/crypto/sha3"
 
 	"github.com/ethereum/go-ethereum/common"
 
 	rollupdb "github.com/celer-network/go-rollup/db"
+	"github.com/celer-network/go-rollup/db/memorydb"
 	"github.com/celer-network/go-rollup/smt"
 	"github.com/celer-network/go-rollup/types"
 )
 
-const stateTreeHeight = 160
+const (
+	stateTreeHeight = 160
+	// DefaultStateTreeCacheSize is the number of state tree nodes cached unless configured otherwise
+	DefaultStateTreeCacheSize = 1 << 16
+	stateTreeRetainedRoots    = 16
+	stateTreePruneInterval    = 10 * time.Minute
+)
 
 var (
 	errAccountNotFound = errors.New("Account not found")
 )
 
+// AccountDiff is an account that differs between two state roots. Before or After is nil for an empty slot.
+type AccountDiff struct {
+	SlotIndex *big.Int           `json:"slotIndex"`
+	Before    *types.AccountInfo `json:"before"`
+	After     *types.AccountInfo `json:"after"`
+}
+
+// StateCheckpoint is a state root at a block boundary, which a state machine restarts from
+type StateCheckpoint struct {
+	// Number of the first block that is not applied to StateRoot
+	NextBlockNumber uint64 `json:"nextBlockNumber"`
+	StateRoot       []byte `json:"stateRoot"`
+	// Next account slot at StateRoot, as accounts created out of slot order leave gaps below it
+	NextSlotIndex *big.Int `json:"nextSlotIndex"`
+}
+
 type StateMachine struct {
-	db         rollupdb.DB
+	db rollupdb.DB
+	// Holds the token mappings, which a stateless state machine shares with the validator
+	tokenDB    rollupdb.DB
 	smt        *smt.SparseMerkleTree
 	serializer *types.Serializer
+	// Held for reading while the state roots within a block are read, as pruning only keeps the retained and
+	// the current state roots
+	pruneLock sync.RWMutex
+}
+
+type options struct {
+	stateTreeCacheSize int
 }
 
-func NewStateMachine(db rollupdb.DB, serializer *types.Serializer) (*StateMachine, error) {
-	// TODO: restore from db
+// Option configures a StateMachine
+type Option func(opts *options)
 
-	smt, err := smt.NewSparseMerkleTree(db, rollupdb.NamespaceStateTrie, sha3.NewLegacyKeccak256(), nil, stateTreeHeight, false)
+// WithStateTreeCacheSize sets the number of state tree nodes cached in memory. Zero keeps the default, so
+// that an unset config value can be passed as is.
+func WithStateTreeCacheSize(size int) Option {
+	return func(opts *options) {
+		if size != 0 {
+			opts.stateTreeCacheSize = size
+		}
+	}
+}
+
+// NewStateMachine creates a state machine on db. It resumes from the last checkpoint saved in db, if any, or
+// starts from the empty state.
+func NewStateMachine(db rollupdb.DB, serializer *types.Serializer, opts ...Option) (*StateMachine, error) {
+	config := &options{stateTreeCacheSize: DefaultStateTreeCacheSize}
+	for _, opt := range opts {
+		opt(config)
+	}
+	smt, err := smt.NewSparseMerkleTree(
+		db,
+		rollupdb.NamespaceStateTrie,
+		smt.HashKeccak256,
+		nil,
+		stateTreeHeight,
+		false,
+		smt.WithCacheSize(config.stateTreeCacheSize),
+		smt.WithRetainedRootsLimit(stateTreeRetainedRoots),
+	)
 	if err != nil {
 		return nil, err
 	}
-	return &StateMachine{
+	sm := &StateMachine{
 		db:         db,
+		tokenDB:    db,
 		smt:        smt,
 		serializer: serializer,
-	}, nil
+	}
+	// The account indexes are written with every transaction, so they are ahead of the checkpoint if the state
+	// machine stopped within a block
+	revertedSlots, err := sm.RevertToCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	checkpoint, exists, err := sm.GetStateCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		log.Info().
+			Uint64("nextBlockNumber", checkpoint.NextBlockNumber).
+			Str("stateRoot", common.Bytes2Hex(checkpoint.StateRoot)).
+			Int("revertedSlots", revertedSlots).
+			Msg("Restored state machine")
+	}
+	return sm, nil
 }
 
 func (sm *StateMachine) ApplyTransaction(tx types.Transaction) (*types.StateUpdate, error) {
+	return sm.ApplyTransactionWithSlot(tx, nil)
+}
+
+// ApplyTransactionWithSlot applies tx like ApplyTransaction, but creates a new account at createSlotIndex
+// instead of the next account slot, unless it is nil. Validators apply create transitions at the slot they name,
+// as the contract only requires that slot to be empty.
+func (sm *StateMachine) ApplyTransactionWithSlot(
+	tx types.Transaction, createSlotIndex *big.Int) (*types.StateUpdate, error) {
 	log.Debug().Msg("Apply transaction")
 	var accountInfoUpdates []*types.AccountInfoUpdate
 	var err error
 	switch tx.GetTransactionType() {
 	case types.TransactionTypeDeposit:
-		accountInfoUpdates, err = sm.applyDeposit(tx.(*types.DepositTransaction))
+		accountInfoUpdates, err = sm.applyDeposit(tx.(*types.DepositTransaction), createSlotIndex)
 		if err != nil {
 			log.Error().Err(err).Stack().Send()
 			return nil, err
@@ -59,7 +149,7 @@ func (sm *StateMachine) ApplyTransaction(tx types.Transaction) (*types.StateUpda
 			return nil, err
 		}
 	case types.TransactionTypeTransfer:
-		accountInfoUpdates, err = sm.applyTransfer(tx.(*types.TransferTransaction))
+		accountInfoUpdates, err = sm.applyTransfer(tx.(*types.TransferTransaction), createSlotIndex)
 		if err != nil {
 			log.Error().Err(err).Stack().Send()
 			return nil, err
@@ -104,7 +194,8 @@ func (sm *StateMachine) ApplyTransaction(tx types.Transaction) (*types.StateUpda
 	}, nil
 }
 
-func (sm *StateMachine) applyDeposit(tx *types.DepositTransaction) ([]*types.AccountInfoUpdate, error) {
+func (sm *StateMachine) applyDeposit(
+	tx *types.DepositTransaction, createSlotIndex *big.Int) ([]*types.AccountInfoUpdate, error) {
 	tokenIndex, err := sm.getTokenIndex(tx.Token)
 	if err != nil {
 		return nil, err
@@ -117,7 +208,7 @@ func (sm *StateMachine) applyDeposit(tx *types.DepositTransaction) ([]*types.Acc
 	if err != nil {
 		if errors.Is(err, errAccountNotFound) {
 			var createErr error
-			accountInfo, createErr = sm.createAccount(account, tokenIndex+1)
+			accountInfo, createErr = sm.createAccount(account, tokenIndex+1, createSlotIndex)
 			if createErr != nil {
 				return nil, err
 			}
@@ -214,7 +305,8 @@ func (sm *StateMachine) applyWithdraw(tx *types.WithdrawTransaction) ([]*types.A
 		}}, nil
 }
 
-func (sm *StateMachine) applyTransfer(tx *types.TransferTransaction) ([]*types.AccountInfoUpdate, error) {
+func (sm *StateMachine) applyTransfer(
+	tx *types.TransferTransaction, createSlotIndex *big.Int) ([]*types.AccountInfoUpdate, error) {
 	tokenIndex, err := sm.getTokenIndex(tx.Token)
 	if err != nil {
 		return nil, err
@@ -227,7 +319,7 @@ func (sm *StateMachine) applyTransfer(tx *types.TransferTransaction) ([]*types.A
 	if err != nil {
 		if errors.Is(err, errAccountNotFound) {
 			var createErr error
-			recipientAccountInfo, createErr = sm.createAccount(recipient, tokenIndex+1)
+			recipientAccountInfo, createErr = sm.createAccount(recipient, tokenIndex+1, createSlotIndex)
 			if createErr != nil {
 				return nil, err
 			}
@@ -291,11 +383,10 @@ func (sm *StateMachine) applyTransfer(tx *types.TransferTransaction) ([]*types.A
 		TransferNonces: recipientTransferNonces,
 		WithdrawNonces: recipientWithdrawNonces,
 	}
-	err = sm.setAccountInfo(sender, updatedSender)
-	if err != nil {
-		return nil, err
-	}
-	err = sm.setAccountInfo(recipient, updatedRecipient)
+	err = sm.setAccountInfos(
+		[]common.Address{sender, recipient},
+		[]*types.AccountInfo{updatedSender, updatedRecipient},
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -312,7 +403,9 @@ func (sm *StateMachine) applyTransfer(tx *types.TransferTransaction) ([]*types.A
 	}, nil
 }
 
-func (sm *StateMachine) createAccount(address common.Address, numTokens uint64) (*types.AccountInfo, error) {
+// createAccount creates an account at slotIndex, or at the next account slot if slotIndex is nil
+func (sm *StateMachine) createAccount(
+	address common.Address, numTokens uint64, slotIndex *big.Int) (*types.AccountInfo, error) {
 	balances := make([]*big.Int, numTokens)
 	for i := 0; i < len(balances); i++ {
 		balances[i] = big.NewInt(0)
@@ -331,26 +424,31 @@ func (sm *StateMachine) createAccount(address common.Address, numTokens uint64)
 		TransferNonces: transferNonces,
 		WithdrawNonces: withdrawNonces,
 	}
-	lastKeyBytes, exists, err := sm.db.Get(rollupdb.NamespaceLastKey, rollupdb.EmptyKey)
+	nextKey, err := sm.GetNextAccountSlotIndex()
 	if err != nil {
 		return nil, err
 	}
-	var lastKey *big.Int
-	if !exists {
-		lastKey = big.NewInt(-1)
-	} else {
-		lastKey = new(big.Int).SetBytes(lastKeyBytes)
+	newKey := nextKey
+	if slotIndex != nil {
+		newKey = slotIndex
 	}
-	newKey := new(big.Int).Add(lastKey, big.NewInt(1))
 	newKeyBytes := newKey.Bytes()
 	data, err := accountInfo.Serialize(sm.serializer)
 	// log.Log().Int("data length", len(data)).Send()
 	// log.Log().Bytes("data", data).Err(err).Msg("createAccount")
 	tx := sm.db.NewTx()
-	err = tx.Set(rollupdb.NamespaceLastKey, rollupdb.EmptyKey, newKey.Bytes())
+	// The dirty mark goes first, so that a partial commit is reverted on restart
+	err = tx.Set(rollupdb.NamespaceDirtySlot, newKeyBytes, rollupdb.EmptyKey)
 	if err != nil {
 		return nil, err
 	}
+	// The next account slot follows the last allocated one
+	if newKey.Cmp(nextKey) >= 0 {
+		err = tx.Set(rollupdb.NamespaceLastKey, rollupdb.EmptyKey, newKey.Bytes())
+		if err != nil {
+			return nil, err
+		}
+	}
 	err = tx.Set(rollupdb.NamespaceAccountAddressToKey, address.Bytes(), newKeyBytes)
 	if err != nil {
 		return nil, err
@@ -374,6 +472,18 @@ func (sm *StateMachine) createAccount(address common.Address, numTokens uint64)
 	return accountInfo, nil
 }
 
+// GetNextAccountSlotIndex returns the slot index that the next created account is allocated
+func (sm *StateMachine) GetNextAccountSlotIndex() (*big.Int, error) {
+	lastKeyBytes, exists, err := sm.db.Get(rollupdb.NamespaceLastKey, rollupdb.EmptyKey)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return big.NewInt(0), nil
+	}
+	return new(big.Int).Add(new(big.Int).SetBytes(lastKeyBytes), big.NewInt(1)), nil
+}
+
 func (sm *StateMachine) getAccountInfo(address common.Address) (*types.AccountInfo, error) {
 	key, exists, err := sm.db.Get(rollupdb.NamespaceAccountAddressToKey, address.Bytes())
 	if err != nil {
@@ -398,28 +508,56 @@ func (sm *StateMachine) getAccountInfo(address common.Address) (*types.AccountIn
 }
 
 func (sm *StateMachine) setAccountInfo(address common.Address, info *types.AccountInfo) error {
-	data, err := info.Serialize(sm.serializer)
-	if err != nil {
-		return err
+	return sm.setAccountInfos([]common.Address{address}, []*types.AccountInfo{info})
+}
+
+// setAccountInfos sets several accounts with a single update of the state tree
+func (sm *StateMachine) setAccountInfos(addresses []common.Address, infos []*types.AccountInfo) error {
+	keys := make([][]byte, len(addresses))
+	values := make([][]byte, len(addresses))
+	for i, address := range addresses {
+		data, err := infos[i].Serialize(sm.serializer)
+		if err != nil {
+			return err
+		}
+		key, exists, err := sm.db.Get(rollupdb.NamespaceAccountAddressToKey, address.Bytes())
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errAccountNotFound
+		}
+		err = sm.setAccountIndex(key, data)
+		if err != nil {
+			return err
+		}
+		keys[i] = key
+		values[i] = data
 	}
-	key, exists, err := sm.db.Get(rollupdb.NamespaceAccountAddressToKey, address.Bytes())
+	_, err := sm.smt.UpdateBatch(keys, values)
+	return err
+}
+
+// setAccountIndex sets the account of a slot in the account indexes, and marks the slot dirty until the next
+// checkpoint. The dirty mark goes first, so that a partial commit is reverted on restart.
+func (sm *StateMachine) setAccountIndex(key []byte, data []byte) error {
+	tx := sm.db.NewTx()
+	err := tx.Set(rollupdb.NamespaceDirtySlot, key, rollupdb.EmptyKey)
 	if err != nil {
+		tx.Discard()
 		return err
 	}
-	if !exists {
-		return errAccountNotFound
-	}
-	err = sm.db.Set(rollupdb.NamespaceKeyToAccountInfo, key, data)
+	err = tx.Set(rollupdb.NamespaceKeyToAccountInfo, key, data)
 	if err != nil {
+		tx.Discard()
 		return err
 	}
-	_, err = sm.smt.Update(key, data)
-	return err
+	return tx.Commit()
 }
 
 func (sm *StateMachine) getTokenIndex(tokenAddress common.Address) (uint64, error) {
 	log.Printf("getTokenIndex for %s", tokenAddress.Hex())
-	tokenIndexBytes, exists, err := sm.db.Get(
+	tokenIndexBytes, exists, err := sm.tokenDB.Get(
 		rollupdb.NamespaceTokenAddressToTokenIndex,
 		tokenAddress.Bytes(),
 	)
@@ -434,23 +572,34 @@ func (sm *StateMachine) getTokenIndex(tokenAddress common.Address) (uint64, erro
 }
 
 func (sm *StateMachine) GetStateSnapshot(key []byte) (*types.StateSnapshot, error) {
-	infoData, err := sm.smt.Get(key)
+	return sm.GetStateSnapshotForRoot(key, sm.smt.Root())
+}
+
+// GetStateSnapshotForRoot gets the snapshot of a slot at a specific state root. It only reads from the db, so
+// it can be called concurrently as long as the nodes of the root are not pruned.
+func (sm *StateMachine) GetStateSnapshotForRoot(key []byte, root []byte) (*types.StateSnapshot, error) {
+	infoData, err := sm.smt.GetForRoot(key, root)
 	if err != nil {
 		return nil, err
 	}
-	proof, err := sm.smt.Prove(key)
+	proof, err := sm.smt.ProveForRoot(key, root)
 	if err != nil {
 		return nil, err
 	}
-	info, err := sm.serializer.DeserializeAccountInfo(infoData)
-	if err != nil {
-		return nil, err
+	var info *types.AccountInfo
+	if bytes.Equal(infoData, smt.DefaultValue) {
+		info = types.NewEmptyAccountInfo()
+	} else {
+		info, err = sm.serializer.DeserializeAccountInfo(infoData)
+		if err != nil {
+			return nil, err
+		}
 	}
 	inclusionProof := types.ConvertToInclusionProof(proof)
 	return &types.StateSnapshot{
 		AccountInfo:    info,
 		SlotIndex:      new(big.Int).SetBytes(key),
-		StateRoot:      sm.smt.Root(),
+		StateRoot:      root,
 		InclusionProof: inclusionProof,
 	}, nil
 }
@@ -459,6 +608,385 @@ func (sm *StateMachine) GetStateRoot() []byte {
 	return sm.smt.Root()
 }
 
+// EmptyStateRoot returns the state root without accounts, which is the state root before the first block
+func EmptyStateRoot() ([]byte, error) {
+	tree, err := smt.NewSparseMerkleTree(
+		memorydb.NewDB(), rollupdb.NamespaceStateTrie, smt.HashKeccak256, nil, stateTreeHeight, false)
+	if err != nil {
+		return nil, err
+	}
+	return tree.Root(), nil
+}
+
+// RetainStateRoot keeps the current state root readable through pruning, and saves it as the checkpoint to
+// restart from. Call it at block boundaries, with the number of the block the state root is before.
+func (sm *StateMachine) RetainStateRoot(nextBlockNumber uint64) error {
+	root := sm.smt.Root()
+	err := sm.smt.RetainRoot(root)
+	if err != nil {
+		return err
+	}
+	nextSlotIndex, err := sm.GetNextAccountSlotIndex()
+	if err != nil {
+		return err
+	}
+	err = sm.saveStateCheckpoint(&StateCheckpoint{
+		NextBlockNumber: nextBlockNumber,
+		StateRoot:       root,
+		NextSlotIndex:   nextSlotIndex,
+	})
+	if err != nil {
+		return err
+	}
+	return sm.clearDirtySlots()
+}
+
+// clearDirtySlots clears the dirty marks of the slots once their accounts are in a checkpoint
+func (sm *StateMachine) clearDirtySlots() error {
+	slotIndices, err := sm.getDirtySlots()
+	if err != nil {
+		return err
+	}
+	bulk := sm.db.NewBulk()
+	for _, slotIndex := range slotIndices {
+		err = bulk.Delete(rollupdb.NamespaceDirtySlot, slotIndex.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+	return bulk.Flush()
+}
+
+// GetStateCheckpoint returns the last checkpoint saved by RetainStateRoot or ImportStateSnapshot
+func (sm *StateMachine) GetStateCheckpoint() (*StateCheckpoint, bool, error) {
+	data, exists, err := sm.db.Get(rollupdb.NamespaceStateCheckpoint, rollupdb.EmptyKey)
+	if err != nil || !exists {
+		return nil, false, err
+	}
+	var checkpoint StateCheckpoint
+	err = json.Unmarshal(data, &checkpoint)
+	if err != nil {
+		return nil, false, fmt.Errorf("Invalid state checkpoint: %w", err)
+	}
+	return &checkpoint, true, nil
+}
+
+func (sm *StateMachine) saveStateCheckpoint(checkpoint *StateCheckpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	return sm.db.Set(rollupdb.NamespaceStateCheckpoint, rollupdb.EmptyKey, data)
+}
+
+// RevertToCheckpoint makes the state root of the last checkpoint, or the empty state root if there is none,
+// the current state root again, and restores the account indexes and the next account slot changed since then.
+// It returns the number of reverted slots.
+func (sm *StateMachine) RevertToCheckpoint() (int, error) {
+	checkpoint, exists, err := sm.GetStateCheckpoint()
+	if err != nil {
+		return 0, err
+	}
+	var root []byte
+	nextSlotIndex := big.NewInt(0)
+	if exists {
+		root = checkpoint.StateRoot
+		nextSlotIndex = checkpoint.NextSlotIndex
+	} else {
+		root, err = EmptyStateRoot()
+		if err != nil {
+			return 0, err
+		}
+	}
+	dirtySlots, err := sm.getDirtySlots()
+	if err != nil {
+		return 0, err
+	}
+	numReverted := len(dirtySlots)
+	for {
+		err = sm.revertAccountIndexes(dirtySlots, root, nextSlotIndex)
+		var partialErr *rollupdb.PartialCommitError
+		if !errors.As(err, &partialErr) {
+			break
+		}
+		// The dirty marks are cleared after the slots are reverted, so the slots left dirty are reverted again
+		remaining, getErr := sm.getDirtySlots()
+		if getErr != nil {
+			return 0, getErr
+		}
+		if len(remaining) >= len(dirtySlots) {
+			break
+		}
+		log.Warn().Err(err).Int("remainingSlots", len(remaining)).Msg("Account indexes partially reverted")
+		dirtySlots = remaining
+	}
+	if err != nil {
+		return 0, err
+	}
+	sm.smt.SetRoot(root)
+	return numReverted, nil
+}
+
+// RevertStateRoot makes root the current state root again, and restores the account indexes of the slots that
+// changed since then. root must be an earlier state root of this state machine whose nodes are not pruned.
+func (sm *StateMachine) RevertStateRoot(root []byte) error {
+	entries, err := sm.smt.Diff(sm.smt.Root(), root)
+	if err != nil {
+		return err
+	}
+	slotIndices := make([]*big.Int, len(entries))
+	for i, entry := range entries {
+		slotIndices[i] = new(big.Int).SetBytes(entry.Key)
+	}
+	err = sm.revertAccountIndexes(slotIndices, root, nil)
+	if err != nil {
+		return err
+	}
+	sm.smt.SetRoot(root)
+	return nil
+}
+
+// revertAccountIndexes resets the account indexes of the given slots, in ascending order, to their accounts at
+// root, and clears their dirty marks. The next account slot is reset to nextSlotIndex, or, if it is nil, to the
+// first slot created after root. The dirty marks are cleared last, so that a slot whose mark is cleared by a
+// partial commit is reverted.
+func (sm *StateMachine) revertAccountIndexes(slotIndices []*big.Int, root []byte, nextSlotIndex *big.Int) error {
+	tx := sm.db.NewTx()
+	var firstCreatedSlot *big.Int
+	for _, slotIndex := range slotIndices {
+		key := slotIndex.Bytes()
+		value, err := sm.smt.GetForRoot(key, root)
+		if err != nil {
+			tx.Discard()
+			return err
+		}
+		if !bytes.Equal(value, smt.DefaultValue) {
+			err = tx.Set(rollupdb.NamespaceKeyToAccountInfo, key, value)
+			if err != nil {
+				tx.Discard()
+				return err
+			}
+			continue
+		}
+		// The account was created after root
+		data, exists, err := sm.db.Get(rollupdb.NamespaceKeyToAccountInfo, key)
+		if err != nil {
+			tx.Discard()
+			return err
+		}
+		if exists {
+			info, err := sm.serializer.DeserializeAccountInfo(data)
+			if err != nil {
+				tx.Discard()
+				return err
+			}
+			err = tx.Delete(rollupdb.NamespaceAccountAddressToKey, info.Account.Bytes())
+			if err != nil {
+				tx.Discard()
+				return err
+			}
+			err = tx.Delete(rollupdb.NamespaceKeyToAccountInfo, key)
+			if err != nil {
+				tx.Discard()
+				return err
+			}
+		}
+		if firstCreatedSlot == nil {
+			firstCreatedSlot = slotIndex
+		}
+	}
+	// Without a known next slot, accounts are taken as allocated in slot order, so the slots from the first
+	// created one on are free again
+	if nextSlotIndex == nil {
+		nextSlotIndex = firstCreatedSlot
+	}
+	if nextSlotIndex != nil {
+		var err error
+		if nextSlotIndex.Sign() == 0 {
+			err = tx.Delete(rollupdb.NamespaceLastKey, rollupdb.EmptyKey)
+		} else {
+			lastKey := new(big.Int).Sub(nextSlotIndex, big.NewInt(1))
+			err = tx.Set(rollupdb.NamespaceLastKey, rollupdb.EmptyKey, lastKey.Bytes())
+		}
+		if err != nil {
+			tx.Discard()
+			return err
+		}
+	}
+	for _, slotIndex := range slotIndices {
+		err := tx.Delete(rollupdb.NamespaceDirtySlot, slotIndex.Bytes())
+		if err != nil {
+			tx.Discard()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// getDirtySlots returns the slots changed since the last checkpoint, in ascending order
+func (sm *StateMachine) getDirtySlots() ([]*big.Int, error) {
+	it := sm.db.IteratePrefix(rollupdb.NamespaceDirtySlot, nil)
+	defer it.Close()
+	var slotIndices []*big.Int
+	for it.Valid() {
+		key, err := it.Key()
+		if err != nil {
+			return nil, err
+		}
+		slotIndices = append(slotIndices, new(big.Int).SetBytes(key))
+		err = it.Next()
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Keys are minimal big-endian, so a longer key is a bigger slot index
+	sort.Slice(slotIndices, func(i, j int) bool { return slotIndices[i].Cmp(slotIndices[j]) < 0 })
+	return slotIndices, nil
+}
+
+// GetRetainedStateRoots returns the state roots retained at recent block boundaries, oldest first
+func (sm *StateMachine) GetRetainedStateRoots() ([][]byte, error) {
+	return sm.smt.RetainedRoots()
+}
+
+// ForEachAccount calls fn for every account in the state tree at root, in slot order
+func (sm *StateMachine) ForEachAccount(root []byte, fn func(slotIndex *big.Int, info *types.AccountInfo) error) error {
+	it, err := sm.smt.NewLeafIterator(root, nil, nil)
+	if err != nil {
+		return err
+	}
+	for it.Valid() {
+		info, err := sm.serializer.DeserializeAccountInfo(it.Value())
+		if err != nil {
+			return err
+		}
+		err = fn(new(big.Int).SetBytes(it.Key()), info)
+		if err != nil {
+			return err
+		}
+		err = it.Next()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DiffStateRoots returns the accounts that differ between two state roots, in slot order
+func (sm *StateMachine) DiffStateRoots(rootA []byte, rootB []byte) ([]*AccountDiff, error) {
+	entries, err := sm.smt.Diff(rootA, rootB)
+	if err != nil {
+		return nil, err
+	}
+	diffs := make([]*AccountDiff, len(entries))
+	for i, entry := range entries {
+		before, err := sm.deserializeSlot(entry.ValueA)
+		if err != nil {
+			return nil, err
+		}
+		after, err := sm.deserializeSlot(entry.ValueB)
+		if err != nil {
+			return nil, err
+		}
+		diffs[i] = &AccountDiff{
+			SlotIndex: new(big.Int).SetBytes(entry.Key),
+			Before:    before,
+			After:     after,
+		}
+	}
+	return diffs, nil
+}
+
+// deserializeSlot deserializes the account in a slot, or returns nil for an empty slot
+func (sm *StateMachine) deserializeSlot(data []byte) (*types.AccountInfo, error) {
+	if bytes.Equal(data, smt.DefaultValue) {
+		return nil, nil
+	}
+	return sm.serializer.DeserializeAccountInfo(data)
+}
+
+// ExportStateSnapshot writes all accounts of the state tree at root to w, and returns the number of accounts
+func (sm *StateMachine) ExportStateSnapshot(w io.Writer, root []byte) (int, error) {
+	return sm.smt.ExportSnapshot(w, root)
+}
+
+// ImportStateSnapshot restores the state tree at the end of block blockNumber from a snapshot written by
+// ExportStateSnapshot. The snapshot must be of stateRoot, the post state root of the block committed on chain.
+// The account indexes are rebuilt from the accounts once the root of the snapshot is checked. The root is made
+// the current and a retained state root, and saved as the checkpoint to restart from.
+func (sm *StateMachine) ImportStateSnapshot(r io.Reader, blockNumber uint64, stateRoot []byte) error {
+	bulk := sm.db.NewBulk()
+	var lastKey *big.Int
+	_, err := sm.smt.ImportSnapshot(r, stateRoot, func(path []byte, value []byte) error {
+		info, err := sm.serializer.DeserializeAccountInfo(value)
+		if err != nil {
+			return err
+		}
+		slotIndex := new(big.Int).SetBytes(path)
+		key := slotIndex.Bytes()
+		err = bulk.Set(rollupdb.NamespaceAccountAddressToKey, info.Account.Bytes(), key)
+		if err != nil {
+			return err
+		}
+		err = bulk.Set(rollupdb.NamespaceKeyToAccountInfo, key, value)
+		if err != nil {
+			return err
+		}
+		// Leaves come in slot order
+		lastKey = slotIndex
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if lastKey != nil {
+		err = bulk.Set(rollupdb.NamespaceLastKey, rollupdb.EmptyKey, lastKey.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+	err = bulk.Flush()
+	if err != nil {
+		return err
+	}
+	return sm.RetainStateRoot(blockNumber + 1)
+}
+
+// PruneStateTree deletes the state tree nodes that are neither in a retained nor in the current state root
+func (sm *StateMachine) PruneStateTree() (*smt.PruneStats, error) {
+	sm.pruneLock.Lock()
+	defer sm.pruneLock.Unlock()
+	return sm.smt.Prune()
+}
+
+// PausePruning keeps PruneStateTree from deleting the state roots within a block until the returned function is
+// called. Hold it while reading at intermediate state roots, such as the pre state roots of fraud proofs.
+func (sm *StateMachine) PausePruning() func() {
+	sm.pruneLock.RLock()
+	return sm.pruneLock.RUnlock
+}
+
+// StartPruning prunes the state tree periodically in the background
+func (sm *StateMachine) StartPruning() {
+	go func() {
+		ticker := time.NewTicker(stateTreePruneInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			stats, err := sm.PruneStateTree()
+			if err != nil {
+				log.Err(err).Msg("Failed to prune state tree")
+				continue
+			}
+			log.Info().
+				Int("retainedRoots", stats.RetainedRoots).
+				Int("markedNodes", stats.MarkedNodes).
+				Int("deletedNodes", stats.DeletedNodes).
+				Int64("deletedBytes", stats.DeletedBytes).
+				Msg("Pruned state tree")
+		}
+	}()
+}
+
 func maybeExpandAccountInfo(
 	tokenIndex uint64,
 	accountInfo *types.AccountInfo) ([]*big.Int, []*big.Int, []*big.Int) {
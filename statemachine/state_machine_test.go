@@ -0,0 +1,453 @@
+package statemachine
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	rollupdb "github.com/celer-network/go-rollup/db"
+	"github.com/celer-network/go-rollup/types"
+)
+
+func TestRevertStateRoot(t *testing.T) {
+	sm, _, _ := newTestStateMachine(t)
+	for _, account := range testAccounts[:2] {
+		_, err := sm.ApplyTransaction(
+			&types.DepositTransaction{Account: account, Token: testToken, Amount: big.NewInt(100)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := sm.GetStateRoot()
+
+	// Update slot 0 and create slots 2 and 3
+	txs := []types.Transaction{
+		&types.TransferTransaction{
+			Sender:    testAccounts[0],
+			Recipient: testAccounts[2],
+			Token:     testToken,
+			Amount:    big.NewInt(10),
+			Nonce:     big.NewInt(0),
+		},
+		&types.DepositTransaction{Account: testAccounts[3], Token: testToken, Amount: big.NewInt(30)},
+	}
+	for _, tx := range txs {
+		_, err := sm.ApplyTransaction(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	postRoot := sm.GetStateRoot()
+
+	err := sm.RevertStateRoot(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sm.GetStateRoot(), root) {
+		t.Fatal("state root not reverted")
+	}
+	info, err := sm.getAccountInfo(testAccounts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Balances[0].Cmp(big.NewInt(100)) != 0 || info.TransferNonces[0].Sign() != 0 {
+		t.Errorf("account not reverted: balance %s, transfer nonce %s", info.Balances[0], info.TransferNonces[0])
+	}
+	for _, account := range testAccounts[2:] {
+		if _, err = sm.getAccountInfo(account); err != errAccountNotFound {
+			t.Errorf("created account %s not removed: %v", account.Hex(), err)
+		}
+	}
+	nextSlotIndex, err := sm.GetNextAccountSlotIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nextSlotIndex.Cmp(big.NewInt(2)) != 0 {
+		t.Errorf("next slot index %d, expected 2", nextSlotIndex.Uint64())
+	}
+
+	// Applying the same transactions again gives the same state
+	for _, tx := range txs {
+		_, err = sm.ApplyTransaction(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(sm.GetStateRoot(), postRoot) {
+		t.Error("state root differs after applying the reverted transactions again")
+	}
+}
+
+func TestRevertStateRootToEmpty(t *testing.T) {
+	sm, _, _ := newTestStateMachine(t)
+	root := sm.GetStateRoot()
+	_, err := sm.ApplyTransaction(
+		&types.DepositTransaction{Account: testAccounts[0], Token: testToken, Amount: big.NewInt(100)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sm.RevertStateRoot(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextSlotIndex, err := sm.GetNextAccountSlotIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nextSlotIndex.Sign() != 0 {
+		t.Errorf("next slot index %d, expected 0", nextSlotIndex.Uint64())
+	}
+	if _, err = sm.getAccountInfo(testAccounts[0]); err != errAccountNotFound {
+		t.Errorf("created account not removed: %v", err)
+	}
+}
+
+func TestStateCheckpoint(t *testing.T) {
+	sm, db, serializer := newTestStateMachine(t)
+	if _, exists, err := sm.GetStateCheckpoint(); err != nil || exists {
+		t.Fatalf("checkpoint of a new state machine: %t, %v", exists, err)
+	}
+	_, err := sm.ApplyTransaction(
+		&types.DepositTransaction{Account: testAccounts[0], Token: testToken, Amount: big.NewInt(100)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := sm.GetStateRoot()
+	err = sm.RetainStateRoot(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := NewStateMachine(db, serializer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(restored.GetStateRoot(), root) {
+		t.Error("state root not restored")
+	}
+	checkpoint, exists, err := restored.GetStateCheckpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists || checkpoint.NextBlockNumber != 3 || !bytes.Equal(checkpoint.StateRoot, root) {
+		t.Errorf("unexpected checkpoint %+v", checkpoint)
+	}
+}
+
+func TestImportStateSnapshot(t *testing.T) {
+	sm, _, serializer := newTestStateMachine(t)
+	for _, account := range testAccounts[:3] {
+		_, err := sm.ApplyTransaction(
+			&types.DepositTransaction{Account: account, Token: testToken, Amount: big.NewInt(100)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := sm.GetStateRoot()
+	var snapshot bytes.Buffer
+	_, err := sm.ExportStateSnapshot(&snapshot, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported, db, _ := newTestStateMachine(t)
+	err = imported.ImportStateSnapshot(bytes.NewReader(snapshot.Bytes()), 5, imported.GetStateRoot())
+	if err == nil {
+		t.Error("importing a snapshot of another state root did not return error")
+	}
+	err = imported.ImportStateSnapshot(bytes.NewReader(snapshot.Bytes()), 5, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := imported.getAccountInfo(testAccounts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Balances[0].Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("imported balance %s", info.Balances[0])
+	}
+
+	// A restarted node resumes after the block of the snapshot
+	restored, err := NewStateMachine(db, serializer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, exists, err := restored.GetStateCheckpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists || checkpoint.NextBlockNumber != 6 || !bytes.Equal(restored.GetStateRoot(), root) {
+		t.Errorf("unexpected checkpoint %+v", checkpoint)
+	}
+}
+
+func TestRestartWithinBlock(t *testing.T) {
+	sm, db, serializer := newTestStateMachine(t)
+	for _, account := range testAccounts[:2] {
+		_, err := sm.ApplyTransaction(
+			&types.DepositTransaction{Account: account, Token: testToken, Amount: big.NewInt(100)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := sm.RetainStateRoot(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := sm.GetStateRoot()
+
+	// Stop within block 1, after it updated slot 0 and created slot 2
+	txs := []types.Transaction{
+		&types.TransferTransaction{
+			Sender:    testAccounts[0],
+			Recipient: testAccounts[2],
+			Token:     testToken,
+			Amount:    big.NewInt(10),
+			Nonce:     big.NewInt(0),
+		},
+		&types.DepositTransaction{Account: testAccounts[1], Token: testToken, Amount: big.NewInt(30)},
+	}
+	for _, tx := range txs {
+		_, err = sm.ApplyTransaction(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	postRoot := sm.GetStateRoot()
+
+	restarted, err := NewStateMachine(db, serializer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(restarted.GetStateRoot(), root) {
+		t.Fatal("state root not restored to the checkpoint")
+	}
+	for i, account := range testAccounts[:2] {
+		info, err := restarted.getAccountInfo(account)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Balances[0].Cmp(big.NewInt(100)) != 0 || info.TransferNonces[0].Sign() != 0 {
+			t.Errorf("account %d not restored: balance %s, transfer nonce %s",
+				i, info.Balances[0], info.TransferNonces[0])
+		}
+	}
+	if _, err = restarted.getAccountInfo(testAccounts[2]); err != errAccountNotFound {
+		t.Errorf("account created within the block not removed: %v", err)
+	}
+	nextSlotIndex, err := restarted.GetNextAccountSlotIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nextSlotIndex.Cmp(big.NewInt(2)) != 0 {
+		t.Errorf("next slot index %d, expected 2", nextSlotIndex.Uint64())
+	}
+
+	// Applying the block again gives the same state
+	for _, tx := range txs {
+		_, err = restarted.ApplyTransaction(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(restarted.GetStateRoot(), postRoot) {
+		t.Error("state root differs after applying the block again")
+	}
+	err = restarted.RetainStateRoot(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirtySlots, err := restarted.getDirtySlots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirtySlots) != 0 {
+		t.Errorf("%d dirty slots after a checkpoint", len(dirtySlots))
+	}
+}
+
+func TestPausePruning(t *testing.T) {
+	sm, _, _ := newTestStateMachine(t)
+	deposit := func(account int) {
+		_, err := sm.ApplyTransaction(
+			&types.DepositTransaction{Account: testAccounts[account], Token: testToken, Amount: big.NewInt(10)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	deposit(0)
+	err := sm.RetainStateRoot(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A state root within a block is neither retained nor current
+	deposit(1)
+	intermediateRoot := sm.GetStateRoot()
+	deposit(0)
+	slotIndex := big.NewInt(1).Bytes()
+
+	resumePruning := sm.PausePruning()
+	pruned := make(chan error, 1)
+	go func() {
+		_, pruneErr := sm.PruneStateTree()
+		pruned <- pruneErr
+	}()
+	select {
+	case <-pruned:
+		t.Fatal("pruned while pruning is paused")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if _, err = sm.GetStateSnapshotForRoot(slotIndex, intermediateRoot); err != nil {
+		t.Fatalf("intermediate state root not readable while pruning is paused: %v", err)
+	}
+	resumePruning()
+	select {
+	case err = <-pruned:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pruning not resumed")
+	}
+	if _, err = sm.GetStateSnapshotForRoot(slotIndex, intermediateRoot); err == nil {
+		t.Error("intermediate state root readable after pruning")
+	}
+}
+
+// partialCommitDB commits only the first operations of its next transaction, like a badger transaction that is
+// split and fails after its first parts
+type partialCommitDB struct {
+	rollupdb.DB
+	committedOps int
+}
+
+func (db *partialCommitDB) NewTx() rollupdb.Transaction {
+	if db.committedOps < 0 {
+		return db.DB.NewTx()
+	}
+	committedOps := db.committedOps
+	db.committedOps = -1
+	return &partialCommitTx{db: db.DB, committedOps: committedOps}
+}
+
+type partialCommitTx struct {
+	db           rollupdb.DB
+	committedOps int
+	ops          []func(tx rollupdb.Transaction) error
+}
+
+func (tx *partialCommitTx) Set(namespace []byte, key []byte, value []byte) error {
+	tx.ops = append(tx.ops, func(tx rollupdb.Transaction) error { return tx.Set(namespace, key, value) })
+	return nil
+}
+
+func (tx *partialCommitTx) Delete(namespace []byte, key []byte) error {
+	tx.ops = append(tx.ops, func(tx rollupdb.Transaction) error { return tx.Delete(namespace, key) })
+	return nil
+}
+
+func (tx *partialCommitTx) Commit() error {
+	committed := tx.db.NewTx()
+	for _, op := range tx.ops[:tx.committedOps] {
+		err := op(committed)
+		if err != nil {
+			return err
+		}
+	}
+	err := committed.Commit()
+	if err != nil {
+		return err
+	}
+	return &rollupdb.PartialCommitError{Committed: 1, Total: 2, Err: errors.New("Commit failed")}
+}
+
+func (tx *partialCommitTx) Discard() {}
+
+func TestRestartAfterPartialCommit(t *testing.T) {
+	tests := []struct {
+		name         string
+		committedOps int
+		// Whether the restart reverts the slots left dirty by the partial commit
+		recovers bool
+	}{
+		{"dirty marks partially cleared", 6, true},
+		{"dirty marks not cleared", 3, false},
+	}
+	for _, test := range tests {
+		sm, db, serializer := newTestStateMachine(t)
+		for _, account := range testAccounts[:2] {
+			_, err := sm.ApplyTransaction(
+				&types.DepositTransaction{Account: account, Token: testToken, Amount: big.NewInt(100)})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err := sm.RetainStateRoot(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root := sm.GetStateRoot()
+		// Update slots 0 and 1 and create slot 2
+		txs := []types.Transaction{
+			&types.TransferTransaction{
+				Sender:    testAccounts[0],
+				Recipient: testAccounts[2],
+				Token:     testToken,
+				Amount:    big.NewInt(10),
+				Nonce:     big.NewInt(0),
+			},
+			&types.DepositTransaction{Account: testAccounts[1], Token: testToken, Amount: big.NewInt(30)},
+		}
+		for _, tx := range txs {
+			_, err = sm.ApplyTransaction(tx)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		restarted, err := NewStateMachine(&partialCommitDB{DB: db, committedOps: test.committedOps}, serializer)
+		if !test.recovers {
+			var partialErr *rollupdb.PartialCommitError
+			if !errors.As(err, &partialErr) {
+				t.Fatalf("%s: restart error %v, expected a partial commit", test.name, err)
+			}
+			// The next restart reverts the slots that are still dirty
+			restarted, err = NewStateMachine(db, serializer)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !bytes.Equal(restarted.GetStateRoot(), root) {
+			t.Fatalf("%s: state root not restored to the checkpoint", test.name)
+		}
+		for i, account := range testAccounts[:2] {
+			info, err := restarted.getAccountInfo(account)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Balances[0].Cmp(big.NewInt(100)) != 0 || info.TransferNonces[0].Sign() != 0 {
+				t.Errorf("%s: account %d not restored: balance %s, transfer nonce %s",
+					test.name, i, info.Balances[0], info.TransferNonces[0])
+			}
+		}
+		if _, err = restarted.getAccountInfo(testAccounts[2]); err != errAccountNotFound {
+			t.Errorf("%s: account created within the block not removed: %v", test.name, err)
+		}
+		nextSlotIndex, err := restarted.GetNextAccountSlotIndex()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nextSlotIndex.Cmp(big.NewInt(2)) != 0 {
+			t.Errorf("%s: next slot index %d, expected 2", test.name, nextSlotIndex.Uint64())
+		}
+		dirtySlots, err := restarted.getDirtySlots()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(dirtySlots) != 0 {
+			t.Errorf("%s: %d dirty slots after the restart", test.name, len(dirtySlots))
+		}
+	}
+}
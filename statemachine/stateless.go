@@ -0,0 +1,190 @@
+package statemachine
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	rollupdb "github.com/celer-network/go-rollup/db"
+	"github.com/celer-network/go-rollup/db/memorydb"
+	"github.com/celer-network/go-rollup/smt"
+	"github.com/celer-network/go-rollup/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// GetBlockWitness builds the witness of a block at the current state root, so it has to be called before the
+// block is applied.
+func (sm *StateMachine) GetBlockWitness(block *types.RollupBlock) (*types.BlockWitness, error) {
+	nextSlotIndex, err := sm.GetNextAccountSlotIndex()
+	if err != nil {
+		return nil, err
+	}
+	return sm.GetBlockWitnessForRoot(block, sm.smt.Root(), nextSlotIndex)
+}
+
+// GetBlockWitnessForRoot builds the witness of a block at a state root before it, where nextSlotIndex was the
+// next account slot index. The nodes of root must not have been pruned, see RetainStateRoot.
+func (sm *StateMachine) GetBlockWitnessForRoot(
+	block *types.RollupBlock, root []byte, nextSlotIndex *big.Int) (*types.BlockWitness, error) {
+	slotIndices := append(block.GetSlotIndices(), nextSlotIndex)
+	if nextSlotIndex.Sign() > 0 {
+		slotIndices = append(slotIndices, new(big.Int).Sub(nextSlotIndex, big.NewInt(1)))
+	}
+	seen := make(map[string]bool)
+	var snapshots []*types.StateSnapshot
+	for _, slotIndex := range slotIndices {
+		key := slotIndex.Bytes()
+		if seen[string(key)] {
+			continue
+		}
+		seen[string(key)] = true
+		snapshot, err := sm.GetStateSnapshotForRoot(key, root)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return &types.BlockWitness{
+		BlockNumber:   block.BlockNumber,
+		StateRoot:     root,
+		NextSlotIndex: nextSlotIndex,
+		Snapshots:     snapshots,
+	}, nil
+}
+
+// GetInputStateSnapshots returns the snapshots of the slots that applying tx accesses, at the current state
+// root, in the order of the entries of its state update. Call it right before ApplyTransaction.
+func (sm *StateMachine) GetInputStateSnapshots(tx types.Transaction) ([]*types.StateSnapshot, error) {
+	var accounts []common.Address
+	switch tx.GetTransactionType() {
+	case types.TransactionTypeDeposit:
+		accounts = []common.Address{tx.(*types.DepositTransaction).Account}
+	case types.TransactionTypeWithdraw:
+		accounts = []common.Address{tx.(*types.WithdrawTransaction).Account}
+	case types.TransactionTypeTransfer:
+		transferTx := tx.(*types.TransferTransaction)
+		accounts = []common.Address{transferTx.Sender, transferTx.Recipient}
+	default:
+		return nil, errors.New("Invalid transaction type")
+	}
+	root := sm.smt.Root()
+	snapshots := make([]*types.StateSnapshot, len(accounts))
+	for i, account := range accounts {
+		key, exists, err := sm.db.Get(rollupdb.NamespaceAccountAddressToKey, account.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			// A missing account is created in the next slot
+			nextSlotIndex, err := sm.GetNextAccountSlotIndex()
+			if err != nil {
+				return nil, err
+			}
+			key = nextSlotIndex.Bytes()
+		}
+		snapshots[i], err = sm.GetStateSnapshotForRoot(key, root)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return snapshots, nil
+}
+
+// NewStatelessStateMachine creates a state machine that holds only the slots of a block witness, in a deep
+// subtree of the state tree on an in-memory db, so that its memory depends on the size of the block and not on
+// the size of the state. Every snapshot is checked against the state root of the witness. Token mappings are
+// read from tokenDB. Accessing a slot that is not in the witness fails.
+func NewStatelessStateMachine(
+	tokenDB rollupdb.DB, serializer *types.Serializer, witness *types.BlockWitness) (*StateMachine, error) {
+	db := memorydb.NewDB()
+	subtree, err := smt.NewDeepSparseMerkleSubTree(
+		db, rollupdb.NamespaceStateTrie, smt.HashKeccak256, stateTreeHeight, false)
+	if err != nil {
+		return nil, err
+	}
+	subtree.SetRoot(witness.StateRoot)
+	bulk := db.NewBulk()
+	// Whether each witnessed slot is empty
+	emptySlots := make(map[string]bool)
+	for _, snapshot := range witness.Snapshots {
+		slotIndex := snapshot.SlotIndex.Uint64()
+		if !bytes.Equal(snapshot.StateRoot, witness.StateRoot) {
+			return nil, fmt.Errorf("Witness of slot %d is not at the state root of the witness", slotIndex)
+		}
+		if len(snapshot.InclusionProof) != stateTreeHeight-1 {
+			return nil, fmt.Errorf("Witness of slot %d has a bad proof size", slotIndex)
+		}
+		key := snapshot.SlotIndex.Bytes()
+		value := smt.DefaultValue
+		empty := snapshot.AccountInfo.IsEmpty()
+		if !empty {
+			value, err = snapshot.AccountInfo.Serialize(serializer)
+			if err != nil {
+				return nil, err
+			}
+			err = bulk.Set(rollupdb.NamespaceAccountAddressToKey, snapshot.AccountInfo.Account.Bytes(), key)
+			if err != nil {
+				return nil, err
+			}
+			err = bulk.Set(rollupdb.NamespaceKeyToAccountInfo, key, value)
+			if err != nil {
+				return nil, err
+			}
+		}
+		// Inclusion proofs start at the leaf, while the branches of a key are added from the root down
+		proof := make([][]byte, len(snapshot.InclusionProof))
+		for i := range snapshot.InclusionProof {
+			proof[len(proof)-1-i] = snapshot.InclusionProof[i][:]
+		}
+		root, err := subtree.AddBranches(proof, [][]byte{key}, [][]byte{value}, false)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(root, witness.StateRoot) {
+			return nil, fmt.Errorf("Witness of slot %d does not match the state root of the witness", slotIndex)
+		}
+		emptySlots[string(key)] = empty
+	}
+	lastKey, err := checkNextSlotIndex(witness.NextSlotIndex, emptySlots)
+	if err != nil {
+		return nil, err
+	}
+	if lastKey != nil {
+		err = bulk.Set(rollupdb.NamespaceLastKey, rollupdb.EmptyKey, lastKey.Bytes())
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = bulk.Flush()
+	if err != nil {
+		return nil, err
+	}
+	return &StateMachine{
+		db:         db,
+		tokenDB:    tokenDB,
+		smt:        subtree.SparseMerkleTree,
+		serializer: serializer,
+	}, nil
+}
+
+// checkNextSlotIndex checks that the witness proves the next slot index, which holds if the slot is empty and
+// the slot before it is not. It returns the last allocated slot index, or nil if no slot is allocated.
+func checkNextSlotIndex(nextSlotIndex *big.Int, emptySlots map[string]bool) (*big.Int, error) {
+	if nextSlotIndex == nil {
+		return nil, errors.New("Witness has no next slot index")
+	}
+	empty, ok := emptySlots[string(nextSlotIndex.Bytes())]
+	if !ok || !empty {
+		return nil, errors.New("Witness does not prove the next slot empty")
+	}
+	if nextSlotIndex.Sign() == 0 {
+		return nil, nil
+	}
+	lastKey := new(big.Int).Sub(nextSlotIndex, big.NewInt(1))
+	empty, ok = emptySlots[string(lastKey.Bytes())]
+	if !ok || empty {
+		return nil, errors.New("Witness does not prove the slot before the next slot allocated")
+	}
+	return lastKey, nil
+}
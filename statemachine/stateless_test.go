@@ -0,0 +1,190 @@
+package statemachine
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	rollupdb "github.com/celer-network/go-rollup/db"
+	"github.com/celer-network/go-rollup/db/memorydb"
+	"github.com/celer-network/go-rollup/smt"
+	"github.com/celer-network/go-rollup/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	testToken    = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	testAccounts = []common.Address{
+		common.HexToAddress("0x2000000000000000000000000000000000000001"),
+		common.HexToAddress("0x2000000000000000000000000000000000000002"),
+		common.HexToAddress("0x2000000000000000000000000000000000000003"),
+		common.HexToAddress("0x2000000000000000000000000000000000000004"),
+	}
+)
+
+func newTestStateMachine(t *testing.T) (*StateMachine, rollupdb.DB, *types.Serializer) {
+	serializer, err := types.NewSerializer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := memorydb.NewDB()
+	err = db.Set(rollupdb.NamespaceTokenAddressToTokenIndex, testToken.Bytes(), big.NewInt(0).Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm, err := NewStateMachine(db, serializer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sm, db, serializer
+}
+
+func TestStatelessStateMachine(t *testing.T) {
+	sm, db, serializer := newTestStateMachine(t)
+	for _, account := range testAccounts[:3] {
+		_, err := sm.ApplyTransaction(
+			&types.DepositTransaction{Account: account, Token: testToken, Amount: big.NewInt(100)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The block transfers from slot 0 to slot 1, deposits to slot 2 and creates slot 3
+	block := &types.RollupBlock{
+		BlockNumber: 1,
+		Transitions: []types.Transition{
+			&types.TransferTransition{SenderSlotIndex: big.NewInt(0), RecipientSlotIndex: big.NewInt(1)},
+			&types.DepositTransition{AccountSlotIndex: big.NewInt(2)},
+			&types.CreateAndDepositTransition{AccountSlotIndex: big.NewInt(3)},
+		},
+	}
+	txs := []types.Transaction{
+		&types.TransferTransaction{
+			Sender:    testAccounts[0],
+			Recipient: testAccounts[1],
+			Token:     testToken,
+			Amount:    big.NewInt(10),
+			Nonce:     big.NewInt(0),
+		},
+		&types.DepositTransaction{Account: testAccounts[2], Token: testToken, Amount: big.NewInt(20)},
+		&types.DepositTransaction{Account: testAccounts[3], Token: testToken, Amount: big.NewInt(30)},
+	}
+	witness, err := sm.GetBlockWitness(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if witness.NextSlotIndex.Cmp(big.NewInt(3)) != 0 {
+		t.Fatalf("unexpected next slot index %d", witness.NextSlotIndex.Uint64())
+	}
+	if len(witness.Snapshots) != 4 {
+		t.Fatalf("unexpected number of witness snapshots %d", len(witness.Snapshots))
+	}
+	stateless, err := NewStatelessStateMachine(db, serializer, witness)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stateless.GetStateRoot(), sm.GetStateRoot()) {
+		t.Fatal("stateless state root differs from state root")
+	}
+	for i, tx := range txs {
+		_, err = sm.ApplyTransaction(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = stateless.ApplyTransaction(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(stateless.GetStateRoot(), sm.GetStateRoot()) {
+			t.Errorf("stateless state root differs from state root after transaction %d", i)
+		}
+	}
+
+	// Slots outside the witness cannot be read
+	if _, err = stateless.GetStateSnapshot(big.NewInt(5).Bytes()); err == nil {
+		t.Error("reading a slot outside the witness did not fail")
+	}
+}
+
+func TestStatelessStateMachineBadWitness(t *testing.T) {
+	sm, db, serializer := newTestStateMachine(t)
+	for _, account := range testAccounts[:2] {
+		_, err := sm.ApplyTransaction(
+			&types.DepositTransaction{Account: account, Token: testToken, Amount: big.NewInt(100)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	block := &types.RollupBlock{
+		BlockNumber: 1,
+		Transitions: []types.Transition{&types.DepositTransition{AccountSlotIndex: big.NewInt(0)}},
+	}
+	witness, err := sm.GetBlockWitness(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewStatelessStateMachine(db, serializer, witness)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	witness.Snapshots[0].AccountInfo.Balances[0] = big.NewInt(1000)
+	if _, err = NewStatelessStateMachine(db, serializer, witness); err == nil {
+		t.Error("witness with a changed account did not fail")
+	}
+	witness.Snapshots[0].AccountInfo.Balances[0] = big.NewInt(100)
+
+	witness.NextSlotIndex = big.NewInt(1)
+	if _, err = NewStatelessStateMachine(db, serializer, witness); err == nil {
+		t.Error("witness with a wrong next slot index did not fail")
+	}
+	witness.NextSlotIndex = big.NewInt(2)
+
+	witness.StateRoot = smt.DefaultValue
+	if _, err = NewStatelessStateMachine(db, serializer, witness); err == nil {
+		t.Error("witness with a wrong state root did not fail")
+	}
+}
+
+func TestInputStateSnapshots(t *testing.T) {
+	sm, _, _ := newTestStateMachine(t)
+	for _, account := range testAccounts[:2] {
+		_, err := sm.ApplyTransaction(
+			&types.DepositTransaction{Account: account, Token: testToken, Amount: big.NewInt(100)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The recipient is created in slot 2
+	tx := &types.TransferTransaction{
+		Sender:    testAccounts[1],
+		Recipient: testAccounts[2],
+		Token:     testToken,
+		Amount:    big.NewInt(10),
+		Nonce:     big.NewInt(0),
+	}
+	preRoot := sm.GetStateRoot()
+	inputs, err := sm.GetInputStateSnapshots(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stateUpdate, err := sm.ApplyTransaction(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) != len(stateUpdate.Entries) {
+		t.Fatalf("got %d inputs for %d entries", len(inputs), len(stateUpdate.Entries))
+	}
+	for i, input := range inputs {
+		if input.SlotIndex.Cmp(stateUpdate.Entries[i].SlotIndex) != 0 {
+			t.Errorf("input %d is slot %d, entry is slot %d",
+				i, input.SlotIndex.Uint64(), stateUpdate.Entries[i].SlotIndex.Uint64())
+		}
+		if !bytes.Equal(input.StateRoot, preRoot) {
+			t.Errorf("input %d is not at the state root before the transaction", i)
+		}
+	}
+	if !inputs[1].AccountInfo.IsEmpty() {
+		t.Error("input of the created recipient is not empty")
+	}
+}
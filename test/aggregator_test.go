@@ -39,6 +39,7 @@ func TestSubmitBlock(t *testing.T) {
 		node1Keystore,
 		node1Keystore,
 		6666,
+		6667,
 		false,
 		false,
 	)
@@ -106,6 +106,7 @@ func TestDummyApp(t *testing.T) {
 		node1Keystore,
 		node1Keystore,
 		6666,
+		6667,
 		false,
 		false,
 	)
@@ -0,0 +1,3 @@
+#!/bin/sh
+go run ../cmd/validator/main.go \
+    -validatordb /tmp/celer-rollup-test/standalone-validator-db
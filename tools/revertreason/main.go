@@ -12,6 +12,8 @@ import (
 	"time"
 
 	"github.com/rs/zerolog/log"
+
+	"github.com/celer-network/go-rollup/utils"
 )
 
 var (
@@ -74,16 +76,11 @@ func main() {
 	printReason(txt)
 }
 
-// txt is like 0x08c379a0
-// 0000000000000000000000000000000000000000000000000000000000000020
-// 0000000000000000000000000000000000000000000000000000000000000017
-// 74686973206973206d792072657175697265206d73672e000000000000000000
-// simplest parsing for now, not robust
+// txt is the hex encoded return data of the reverted call
 func printReason(txt string) {
-	b := hex2Bytes(txt)
-	if len(b) > 68 {
-		b = bytes.Trim(b, "\x00")
-		log.Info().Str("reason", string(b[68:])).Send()
+	reason, ok := utils.UnpackRevertReason(hex2Bytes(txt))
+	if ok {
+		log.Info().Str("reason", reason).Send()
 	} else {
 		log.Info().Msg("No revert reason")
 	}
@@ -16,6 +16,23 @@ type AccountInfo struct {
 	WithdrawNonces []*big.Int
 }
 
+// NewEmptyAccountInfo returns the AccountInfo of an unallocated storage slot, as expected by the contract
+func NewEmptyAccountInfo() *AccountInfo {
+	return &AccountInfo{
+		Balances:       []*big.Int{},
+		TransferNonces: []*big.Int{},
+		WithdrawNonces: []*big.Int{},
+	}
+}
+
+// IsEmpty reports whether the AccountInfo belongs to an unallocated storage slot
+func (info *AccountInfo) IsEmpty() bool {
+	return info.Account == (common.Address{}) &&
+		len(info.Balances) == 0 &&
+		len(info.TransferNonces) == 0 &&
+		len(info.WithdrawNonces) == 0
+}
+
 func createAccountInfoArguments(r *typeRegistry) abi.Arguments {
 	return abi.Arguments([]abi.Argument{
 		{Name: "account", Type: r.addressTy, Indexed: false},
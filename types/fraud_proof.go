@@ -1,6 +1,7 @@
 package types
 
 import (
+	"bytes"
 	"fmt"
 	"math/big"
 
@@ -12,6 +13,7 @@ type LocalFraudProof struct {
 	Position   *TransitionPosition
 	Inputs     []*StateSnapshot
 	Transition Transition
+	Reason     string
 }
 
 type StorageSlot struct {
@@ -100,6 +102,45 @@ type ContractFraudProof struct {
 	TransitionStorageSlots     []mainchain.DataTypesIncludedStorageSlot
 }
 
+// Serialize encodes the fraud proof as the calldata of RollupChain.proveTransitionInvalid
+func (proof *ContractFraudProof) Serialize(s *Serializer) ([]byte, error) {
+	data, err := s.rollupChainABI.Pack(
+		"proveTransitionInvalid",
+		proof.PreStateIncludedTransition,
+		proof.InvalidIncludedTransition,
+		proof.TransitionStorageSlots,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Serialize ContractFraudProof: %w", err)
+	}
+	return data, nil
+}
+
+func (s *Serializer) DeserializeContractFraudProof(data []byte) (*ContractFraudProof, error) {
+	method, exists := s.rollupChainABI.Methods["proveTransitionInvalid"]
+	if !exists {
+		return nil, fmt.Errorf("Deserialize ContractFraudProof: missing method")
+	}
+	methodID := method.ID()
+	if len(data) < len(methodID) || !bytes.Equal(data[:len(methodID)], methodID) {
+		return nil, fmt.Errorf("Deserialize ContractFraudProof, data %v: invalid method ID", data)
+	}
+	var proof struct {
+		PreStateIncludedTransition mainchain.DataTypesIncludedTransition
+		InvalidIncludedTransition  mainchain.DataTypesIncludedTransition
+		TransitionStorageSlots     []mainchain.DataTypesIncludedStorageSlot
+	}
+	err := method.Inputs.Unpack(&proof, data[len(methodID):])
+	if err != nil {
+		return nil, fmt.Errorf("Deserialize ContractFraudProof, data %v: %w", data, err)
+	}
+	return &ContractFraudProof{
+		PreStateIncludedTransition: proof.PreStateIncludedTransition,
+		InvalidIncludedTransition:  proof.InvalidIncludedTransition,
+		TransitionStorageSlots:     proof.TransitionStorageSlots,
+	}, nil
+}
+
 func ConvertToInclusionProof(data [][]byte) InclusionProof {
 	proof := make([][32]byte, len(data))
 	for i, sibling := range data {
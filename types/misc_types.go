@@ -34,6 +34,25 @@ type StateSnapshot struct {
 	InclusionProof InclusionProof
 }
 
+// BlockWitness holds the state a block needs to be validated without the state db: the snapshots of the slots
+// accessed by the block, all proven against the state root before the block, and the slot that the next
+// created account is allocated. The snapshots include that slot and, unless it is the first, the slot before it,
+// which proves it as slots are allocated in order. Transitions is optional, see TransitionWitness.
+type BlockWitness struct {
+	BlockNumber   uint64
+	StateRoot     []byte
+	NextSlotIndex *big.Int
+	Snapshots     []*StateSnapshot
+	Transitions   []*TransitionWitness
+}
+
+// TransitionWitness lets a single transition be checked on its own: the snapshots of the slots it accesses at
+// the state root before it, in the order of GetInputSlotIndices, and its inclusion proof in the block.
+type TransitionWitness struct {
+	Inputs         []*StateSnapshot
+	InclusionProof InclusionProof
+}
+
 type TransitionPosition struct {
 	BlockNumber     uint64
 	TransitionIndex uint64
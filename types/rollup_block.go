@@ -24,6 +24,22 @@ func NewRollupBlock(blockNumber uint64) *RollupBlock {
 	}
 }
 
+// GetSlotIndices returns the distinct storage slots accessed by the transitions of the block, in access order
+func (b *RollupBlock) GetSlotIndices() []*big.Int {
+	var slotIndices []*big.Int
+	seen := make(map[string]bool)
+	for _, transition := range b.Transitions {
+		for _, slotIndex := range GetInputSlotIndices(transition) {
+			key := string(slotIndex.Bytes())
+			if !seen[key] {
+				seen[key] = true
+				slotIndices = append(slotIndices, slotIndex)
+			}
+		}
+	}
+	return slotIndices
+}
+
 func (s *Serializer) DeserializeRollupBlockFromData(data []byte) (*RollupBlock, error) {
 	var storedBlock storedRollupBlock
 	rollupBlockArguments := abi.Arguments([]abi.Argument{
@@ -37,15 +53,12 @@ func (s *Serializer) DeserializeRollupBlockFromData(data []byte) (*RollupBlock,
 	return s.DeserializeRollupBlockFromFields(storedBlock.BlockNumber.Uint64(), storedBlock.Transitions)
 }
 
+// DeserializeRollupBlockFromFields decodes the transitions of a committed block, see DeserializeCommittedTransition.
 func (s *Serializer) DeserializeRollupBlockFromFields(
 	blockNumber uint64, rawTransitions [][]byte) (*RollupBlock, error) {
 	transitions := make([]Transition, len(rawTransitions))
 	for i, transitionData := range rawTransitions {
-		transition, err := s.DeserializeTransition(transitionData)
-		if err != nil {
-			return nil, err
-		}
-		transitions[i] = transition
+		transitions[i] = s.DeserializeCommittedTransition(transitionData)
 	}
 	return &RollupBlock{
 		BlockNumber: blockNumber,
@@ -3,12 +3,8 @@ package types
 import (
 	"math/big"
 
-	rollupdb "github.com/celer-network/go-rollup/db"
-
-	"github.com/celer-network/go-rollup/db/memorydb"
 	"github.com/celer-network/go-rollup/smt"
 	"github.com/celer-network/rollup-contracts/bindings/go/mainchain"
-	"golang.org/x/crypto/sha3"
 )
 
 type RollupBlockInfo struct {
@@ -19,27 +15,22 @@ type RollupBlockInfo struct {
 
 func NewRollupBlockInfo(serializer *Serializer, rollupBlock *RollupBlock) (*RollupBlockInfo, error) {
 	transitions := rollupBlock.Transitions
-	numTransitions := len(transitions)
-	smt, err := smt.NewSparseMerkleTree(memorydb.NewDB(), rollupdb.NamespaceRollupBlockTrie, sha3.NewLegacyKeccak256(), nil, numTransitions, false)
-	if err != nil {
-		return nil, err
-	}
-	encodedTransitions := make([][]byte, numTransitions)
+	encodedTransitions := make([][]byte, len(transitions))
 	for i, transition := range transitions {
 		encodedTransition, err := transition.Serialize(serializer)
 		if err != nil {
 			return nil, err
 		}
 		encodedTransitions[i] = encodedTransition
-		_, err = smt.Update(big.NewInt(int64(i)).Bytes(), encodedTransition)
-		if err != nil {
-			return nil, err
-		}
+	}
+	tree, err := NewTransitionTree(encodedTransitions)
+	if err != nil {
+		return nil, err
 	}
 	return &RollupBlockInfo{
 		blockNumber:        big.NewInt(int64(rollupBlock.BlockNumber)),
 		encodedTransitions: encodedTransitions,
-		smt:                smt,
+		smt:                tree,
 	}, nil
 }
 
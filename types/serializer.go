@@ -1,6 +1,11 @@
 package types
 
-import "github.com/ethereum/go-ethereum/accounts/abi"
+import (
+	"strings"
+
+	"github.com/celer-network/rollup-contracts/bindings/go/mainchain"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
 
 type Serializer struct {
 	typeRegistry                         *typeRegistry
@@ -11,6 +16,7 @@ type Serializer struct {
 	withdrawTransitionArguments          abi.Arguments
 	createAndTransferTransitionArguments abi.Arguments
 	transferTransitionArguments          abi.Arguments
+	rollupChainABI                       abi.ABI
 }
 
 func NewSerializer() (*Serializer, error) {
@@ -22,6 +28,10 @@ func NewSerializer() (*Serializer, error) {
 	if err != nil {
 		return nil, err
 	}
+	rollupChainABI, err := abi.JSON(strings.NewReader(mainchain.RollupChainABI))
+	if err != nil {
+		return nil, err
+	}
 	return &Serializer{
 		typeRegistry:                         typeRegistry,
 		accountInfoArguments:                 createAccountInfoArguments(typeRegistry),
@@ -31,5 +41,6 @@ func NewSerializer() (*Serializer, error) {
 		withdrawTransitionArguments:          createWithdrawTransitionArguments(typeRegistry),
 		createAndTransferTransitionArguments: createCreateAndTransferTransitionArguments(typeRegistry),
 		transferTransitionArguments:          createTransferTransitionArguments(typeRegistry),
+		rollupChainABI:                       rollupChainABI,
 	}, nil
 }
@@ -0,0 +1,47 @@
+package types
+
+import (
+	"math/big"
+	"math/bits"
+
+	rollupdb "github.com/celer-network/go-rollup/db"
+	"github.com/celer-network/go-rollup/db/memorydb"
+	"github.com/celer-network/go-rollup/smt"
+)
+
+// TransitionTreeHeight returns the height of the transition tree of a block with numTransitions transitions.
+// The contract pads every level with an odd number of nodes with a default node, which gives the root of a full
+// tree with ceil(log2(numTransitions)) levels above the leaves. A single transition is its own root.
+func TransitionTreeHeight(numTransitions int) int {
+	if numTransitions <= 1 {
+		return 1
+	}
+	return bits.Len(uint(numTransitions-1)) + 1
+}
+
+// NewTransitionTree builds the transition tree of a block, keyed by transition index. Its root matches the root
+// computed by the RollupChain contract for the same encoded transitions.
+func NewTransitionTree(encodedTransitions [][]byte) (*smt.SparseMerkleTree, error) {
+	tree, err := smt.NewSparseMerkleTree(
+		memorydb.NewDB(),
+		rollupdb.NamespaceRollupBlockTrie,
+		smt.HashKeccak256,
+		nil,
+		TransitionTreeHeight(len(encodedTransitions)),
+		false)
+	if err != nil {
+		return nil, err
+	}
+	if len(encodedTransitions) == 0 {
+		return tree, nil
+	}
+	keys := make([][]byte, len(encodedTransitions))
+	for i := range encodedTransitions {
+		keys[i] = big.NewInt(int64(i)).Bytes()
+	}
+	_, err = tree.UpdateBatch(keys, encodedTransitions)
+	if err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
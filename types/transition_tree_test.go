@@ -0,0 +1,75 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/celer-network/rollup-contracts/bindings/go/mainchain"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func deployMerkleUtils(t *testing.T) *mainchain.MerkleUtils {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := bind.NewKeyedTransactor(key)
+	backend := backends.NewSimulatedBackend(
+		core.GenesisAlloc{auth.From: {Balance: new(big.Int).Lsh(big.NewInt(1), 100)}}, 10000000)
+	auth.GasLimit = 8000000
+	_, _, merkleUtils, err := mainchain.DeployMerkleUtils(auth, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.Commit()
+	return merkleUtils
+}
+
+func TestTransitionTreeHeight(t *testing.T) {
+	heights := map[int]int{0: 1, 1: 1, 2: 2, 3: 3, 4: 3, 5: 4, 8: 4, 9: 5, 64: 7, 65: 8}
+	for numTransitions, height := range heights {
+		if TransitionTreeHeight(numTransitions) != height {
+			t.Errorf("height %d for %d transitions, expected %d",
+				TransitionTreeHeight(numTransitions), numTransitions, height)
+		}
+	}
+}
+
+func TestTransitionTreeMatchesContract(t *testing.T) {
+	merkleUtils := deployMerkleUtils(t)
+	var transitions [][]byte
+	for numTransitions := 1; numTransitions <= 64; numTransitions++ {
+		transitions = append(transitions, []byte(fmt.Sprintf("transition%d", numTransitions-1)))
+		tree, err := NewTransitionTree(transitions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contractRoot, err := merkleUtils.GetMerkleRoot(&bind.CallOpts{}, transitions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(tree.Root(), contractRoot[:]) {
+			t.Errorf("root mismatch for %d transitions", numTransitions)
+			continue
+		}
+		for i := range transitions {
+			proof, err := tree.Prove(big.NewInt(int64(i)).Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			verified, err := merkleUtils.Verify(
+				&bind.CallOpts{}, contractRoot, transitions[i], big.NewInt(int64(i)), ConvertToInclusionProof(proof))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !verified {
+				t.Errorf("contract rejected proof of transition %d of %d", i, numTransitions)
+			}
+		}
+	}
+}
@@ -16,8 +16,13 @@ const (
 	TransitionTypeWithdraw
 	TransitionTypeCreateAndTransfer
 	TransitionTypeTransfer
+	// TransitionTypeInvalid marks a committed transition that cannot be decoded
+	TransitionTypeInvalid TransitionType = -1
 )
 
+// transitionTypeLength is the length of the leading ABI encoded transition type
+const transitionTypeLength = 32
+
 type Transition interface {
 	GetTransitionType() TransitionType
 	GetSignature() []byte
@@ -320,7 +325,10 @@ func (s *Serializer) DeserializeTransferTransition(data []byte) (*TransferTransi
 }
 
 func (s *Serializer) DeserializeTransition(data []byte) (Transition, error) {
-	transitionType := new(big.Int).SetBytes(data[0:32]).Uint64()
+	if len(data) < transitionTypeLength {
+		return nil, fmt.Errorf("Transition too short, length %d", len(data))
+	}
+	transitionType := new(big.Int).SetBytes(data[0:transitionTypeLength]).Uint64()
 	switch TransitionType(transitionType) {
 	case TransitionTypeCreateAndDeposit:
 		return s.DeserializeCreateAndDepositTransition(data)
@@ -335,3 +343,68 @@ func (s *Serializer) DeserializeTransition(data []byte) (Transition, error) {
 	}
 	return nil, fmt.Errorf("Unknown transition type %d", transitionType)
 }
+
+// DeserializeCommittedTransition decodes a transition committed on chain. A transition that cannot be decoded
+// is kept as an InvalidTransition so that it can be proven invalid.
+func (s *Serializer) DeserializeCommittedTransition(data []byte) Transition {
+	transition, err := s.DeserializeTransition(data)
+	if err != nil {
+		return NewInvalidTransition(data, err)
+	}
+	return transition
+}
+
+// InvalidTransition wraps the raw data of a committed transition that cannot be decoded, either because
+// of an unknown transition type or malformed ABI data. The contract prunes a block containing it.
+type InvalidTransition struct {
+	Data   []byte
+	Reason string
+}
+
+func NewInvalidTransition(data []byte, err error) *InvalidTransition {
+	return &InvalidTransition{
+		Data:   data,
+		Reason: err.Error(),
+	}
+}
+
+func (*InvalidTransition) GetTransitionType() TransitionType {
+	return TransitionTypeInvalid
+}
+
+func (*InvalidTransition) GetSignature() []byte {
+	return nil
+}
+
+func (*InvalidTransition) GetStateRoot() [32]byte {
+	return [32]byte{}
+}
+
+func (transition *InvalidTransition) Serialize(s *Serializer) ([]byte, error) {
+	return transition.Data, nil
+}
+
+// GetInputSlotIndices returns the storage slots accessed by a transition, in the order expected by the contract
+func GetInputSlotIndices(transition Transition) []*big.Int {
+	switch transition.GetTransitionType() {
+	case TransitionTypeCreateAndDeposit:
+		return []*big.Int{transition.(*CreateAndDepositTransition).AccountSlotIndex}
+	case TransitionTypeDeposit:
+		return []*big.Int{transition.(*DepositTransition).AccountSlotIndex}
+	case TransitionTypeWithdraw:
+		return []*big.Int{transition.(*WithdrawTransition).AccountSlotIndex}
+	case TransitionTypeCreateAndTransfer:
+		createAndTransferTransition := transition.(*CreateAndTransferTransition)
+		return []*big.Int{
+			createAndTransferTransition.SenderSlotIndex,
+			createAndTransferTransition.RecipientSlotIndex,
+		}
+	case TransitionTypeTransfer:
+		transferTransition := transition.(*TransferTransition)
+		return []*big.Int{
+			transferTransition.SenderSlotIndex,
+			transferTransition.RecipientSlotIndex,
+		}
+	}
+	return nil
+}
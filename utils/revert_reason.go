@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"bytes"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// revertReasonSelector is the selector of Error(string), which solidity uses to encode
+// the message of a failed require / revert
+var revertReasonSelector = []byte{0x08, 0xc3, 0x79, 0xa0}
+
+// UnpackRevertReason extracts the reason string from the return data of a reverted call.
+// The data looks like 0x08c379a0
+// 0000000000000000000000000000000000000000000000000000000000000020
+// 0000000000000000000000000000000000000000000000000000000000000017
+// 74686973206973206d792072657175697265206d73672e000000000000000000
+// It returns false if the data is not an Error(string) encoding.
+func UnpackRevertReason(data []byte) (string, bool) {
+	if len(data) < len(revertReasonSelector) || !bytes.Equal(data[:len(revertReasonSelector)], revertReasonSelector) {
+		return "", false
+	}
+	stringTy, err := abi.NewType("string", "", nil)
+	if err != nil {
+		return "", false
+	}
+	values, err := abi.Arguments{{Type: stringTy}}.UnpackValues(data[len(revertReasonSelector):])
+	if err != nil || len(values) != 1 {
+		return "", false
+	}
+	reason, ok := values[0].(string)
+	return reason, ok
+}
@@ -0,0 +1,56 @@
+package validator
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/celer-network/go-rollup/types"
+	"github.com/celer-network/rollup-contracts/bindings/go/mainchain"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+// GetCommittedStateRoot returns the post state root of a block committed to RollupChain, which is the state root
+// of its last transition. The transitions are read from the RollupBlockCommitted events from mainchain block
+// startBlock on, and must match the transition root of the block on chain, as a block pruned after a fraud proof
+// is committed again with other transitions.
+func GetCommittedStateRoot(
+	rollupChain *mainchain.RollupChain,
+	serializer *types.Serializer,
+	blockNumber uint64,
+	startBlock uint64,
+) ([]byte, error) {
+	committed, err := rollupChain.Blocks(&bind.CallOpts{}, new(big.Int).SetUint64(blockNumber))
+	if err != nil {
+		return nil, fmt.Errorf("Get block %d from chain: %w", blockNumber, err)
+	}
+	it, err := rollupChain.FilterRollupBlockCommitted(&bind.FilterOpts{Start: startBlock})
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	var stateRoot []byte
+	for it.Next() {
+		event := it.Event
+		if event.BlockNumber.Uint64() != blockNumber || len(event.Transitions) == 0 {
+			continue
+		}
+		tree, err := types.NewTransitionTree(event.Transitions)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(tree.Root(), committed.RootHash[:]) {
+			continue
+		}
+		lastTransition := serializer.DeserializeCommittedTransition(event.Transitions[len(event.Transitions)-1])
+		root := lastTransition.GetStateRoot()
+		stateRoot = root[:]
+	}
+	if it.Error() != nil {
+		return nil, it.Error()
+	}
+	if stateRoot == nil {
+		return nil, fmt.Errorf("No committed transitions of block %d match its root on chain", blockNumber)
+	}
+	return stateRoot, nil
+}
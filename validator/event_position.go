@@ -0,0 +1,92 @@
+package validator
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/celer-network/go-rollup/db"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// Keys of the positions of the last processed mainchain events, so that a restarted watcher resumes after them
+var (
+	eventPositionRollupBlockCommitted = []byte("RollupBlockCommitted")
+	eventPositionTokenRegistered      = []byte("TokenRegistered")
+)
+
+// eventPosition is the position of a log on the mainchain
+type eventPosition struct {
+	blockNumber uint64
+	index       uint64
+}
+
+func newEventPosition(log *ethtypes.Log) eventPosition {
+	return eventPosition{blockNumber: log.BlockNumber, index: uint64(log.Index)}
+}
+
+func (p eventPosition) after(other eventPosition) bool {
+	return p.blockNumber > other.blockNumber || (p.blockNumber == other.blockNumber && p.index > other.index)
+}
+
+// loadEventPosition returns the saved position of the last processed event, if any
+func loadEventPosition(database db.DB, key []byte) (eventPosition, bool, error) {
+	data, exists, err := database.Get(db.NamespaceEventPosition, key)
+	if err != nil || !exists {
+		return eventPosition{}, false, err
+	}
+	if len(data) != 16 {
+		return eventPosition{}, false, errors.New("Invalid event position")
+	}
+	return eventPosition{
+		blockNumber: binary.BigEndian.Uint64(data[:8]),
+		index:       binary.BigEndian.Uint64(data[8:]),
+	}, true, nil
+}
+
+func saveEventPosition(database db.DB, key []byte, position eventPosition) error {
+	return database.Set(db.NamespaceEventPosition, key, encodeEventPosition(position))
+}
+
+func encodeEventPosition(position eventPosition) []byte {
+	data := make([]byte, 16)
+	binary.BigEndian.PutUint64(data[:8], position.blockNumber)
+	binary.BigEndian.PutUint64(data[8:], position.index)
+	return data
+}
+
+// eventFilter skips the events that have already been processed, which is needed as the backfill and the
+// subscription overlap, and the backfill starts again at the block of the last processed event
+type eventFilter struct {
+	last   eventPosition
+	exists bool
+}
+
+func newEventFilter(database db.DB, key []byte) (*eventFilter, error) {
+	last, exists, err := loadEventPosition(database, key)
+	if err != nil {
+		return nil, err
+	}
+	return &eventFilter{last: last, exists: exists}, nil
+}
+
+// startBlock returns the mainchain block to backfill from
+func (f *eventFilter) startBlock(deployBlock uint64) uint64 {
+	if f.exists && f.last.blockNumber > deployBlock {
+		return f.last.blockNumber
+	}
+	return deployBlock
+}
+
+// next returns whether an event is new, and then records it as the last one
+func (f *eventFilter) next(log *ethtypes.Log) bool {
+	if log.Removed {
+		return false
+	}
+	position := newEventPosition(log)
+	if f.exists && !position.after(f.last) {
+		return false
+	}
+	f.last = position
+	f.exists = true
+	return true
+}
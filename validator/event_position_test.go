@@ -0,0 +1,62 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/celer-network/go-rollup/db/memorydb"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestEventFilter(t *testing.T) {
+	database := memorydb.NewDB()
+	filter, err := newEventFilter(database, eventPositionRollupBlockCommitted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.startBlock(100) != 100 {
+		t.Errorf("first start at block %d, expected the deploy block", filter.startBlock(100))
+	}
+	logs := []struct {
+		log ethtypes.Log
+		new bool
+	}{
+		{ethtypes.Log{BlockNumber: 101, Index: 3}, true},
+		{ethtypes.Log{BlockNumber: 101, Index: 5}, true},
+		// The subscription delivers the events of the backfill again
+		{ethtypes.Log{BlockNumber: 101, Index: 5}, false},
+		{ethtypes.Log{BlockNumber: 101, Index: 4}, false},
+		{ethtypes.Log{BlockNumber: 102, Index: 0}, true},
+		{ethtypes.Log{BlockNumber: 103, Index: 0, Removed: true}, false},
+	}
+	for i, test := range logs {
+		if filter.next(&test.log) != test.new {
+			t.Errorf("log %d new: %t, expected %t", i, !test.new, test.new)
+		}
+	}
+
+	err = saveEventPosition(database, eventPositionRollupBlockCommitted, eventPosition{blockNumber: 101, index: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted, err := newEventFilter(database, eventPositionRollupBlockCommitted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The backfill starts again at the block of the last processed event, and skips the processed ones
+	if restarted.startBlock(100) != 101 {
+		t.Errorf("restart at block %d, expected 101", restarted.startBlock(100))
+	}
+	if restarted.next(&ethtypes.Log{BlockNumber: 101, Index: 5}) {
+		t.Error("processed event is new after a restart")
+	}
+	if !restarted.next(&ethtypes.Log{BlockNumber: 101, Index: 6}) {
+		t.Error("next event is not new after a restart")
+	}
+	other, err := newEventFilter(database, eventPositionTokenRegistered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.startBlock(100) != 100 {
+		t.Error("positions of different events are not independent")
+	}
+}
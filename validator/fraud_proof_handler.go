@@ -0,0 +1,177 @@
+package validator
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/celer-network/go-rollup/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	fraudProofQueueSize     = 16
+	fraudProofRetryInterval = time.Minute
+)
+
+var (
+	errSubmitterIsCommitter = errors.New("Fraud proof submitter is the committer")
+	errFraudProofWouldFail  = errors.New("Fraud proof would fail")
+	errSubmissionDisabled   = errors.New("Fraud proof submission disabled")
+)
+
+// fraudProofSubmission is a new fraud proof queued for the submission goroutine
+type fraudProofSubmission struct {
+	record *FraudProofRecord
+	proof  *types.ContractFraudProof
+}
+
+// handleFraudProof persists a detected fraud before generating the contract fraud proof and queueing it for
+// submission, so that the proof survives failed submissions and restarts. A fraud that is already recorded is
+// left to the retries, so that its attempts and status are kept.
+func (v *Validator) handleFraudProof(blockInfo *types.RollupBlockInfo, localFraudProof *types.LocalFraudProof) {
+	record, err := newFraudProofRecord(v.serializer, localFraudProof)
+	if err != nil {
+		log.Err(err).Msg("Failed to create fraud proof record")
+		return
+	}
+	existing, exists, err := v.fraudProofStore.Get(record.BlockNumber, record.TransitionIndex)
+	if err != nil {
+		log.Err(err).Msg("Failed to load fraud proof record")
+		return
+	}
+	if exists {
+		if bytes.Equal(existing.Transition, record.Transition) {
+			log.Info().
+				Uint64("blockNumber", record.BlockNumber).
+				Uint64("transitionIndex", record.TransitionIndex).
+				Str("status", string(existing.Status)).
+				Msg("Fraud proof already recorded")
+			return
+		}
+		// The block was reverted and another one committed with the same number
+		log.Warn().
+			Uint64("blockNumber", record.BlockNumber).
+			Uint64("transitionIndex", record.TransitionIndex).
+			Msg("Replacing fraud proof record of a different transition")
+	}
+	contractFraudProof, err := v.generateContractFraudProof(blockInfo, localFraudProof)
+	if err != nil {
+		log.Err(err).Msg("Failed to generate contract fraud proof")
+		record.Status = FraudProofStatusFailed
+		record.Result = err.Error()
+		v.putFraudProofRecord(record)
+		v.reportFraudProof(record)
+		return
+	}
+	record.ContractFraudProof, err = contractFraudProof.Serialize(v.serializer)
+	if err != nil {
+		log.Err(err).Msg("Failed to serialize contract fraud proof")
+		record.Status = FraudProofStatusFailed
+		record.Result = err.Error()
+		v.putFraudProofRecord(record)
+		v.reportFraudProof(record)
+		return
+	}
+	v.putFraudProofRecord(record)
+	v.fraudProofSubmissions <- &fraudProofSubmission{record: record, proof: contractFraudProof}
+}
+
+// submitFraudProofs submits all fraud proofs on one goroutine, so that transactions from the mainchain
+// account are sent one at a time. It submits new proofs as they are queued and retries pending ones
+// periodically, including those left pending by a previous run.
+func (v *Validator) submitFraudProofs() {
+	v.retryPendingFraudProofs(true)
+	ticker := time.NewTicker(fraudProofRetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case submission := <-v.fraudProofSubmissions:
+			v.submitFraudProofRecord(submission.record, submission.proof)
+			// Report after the first attempt, so that the report includes the outcome of the dry run
+			v.reportFraudProof(submission.record)
+		case <-ticker.C:
+			v.retryPendingFraudProofs(false)
+		}
+	}
+}
+
+// submitFraudProofRecord submits the proof and records the attempt and its outcome
+func (v *Validator) submitFraudProofRecord(record *FraudProofRecord, proof *types.ContractFraudProof) {
+	txHash, err := v.submitContractFraudProof(proof)
+	if err != nil && !errors.Is(err, errSubmitterIsCommitter) && !errors.Is(err, errSubmissionDisabled) &&
+		!errors.Is(err, errFraudProofWouldFail) {
+		log.Err(err).Uint64("blockNumber", record.BlockNumber).Uint64("transitionIndex", record.TransitionIndex).
+			Msg("Failed to submit fraud proof")
+	}
+	recordFraudProofAttempt(record, time.Now(), txHash, err)
+	v.putFraudProofRecord(record)
+}
+
+// recordFraudProofAttempt appends a submission attempt to the record and updates its status from the
+// outcome. A failed submission leaves the record pending until it runs out of attempts.
+func recordFraudProofAttempt(record *FraudProofRecord, now time.Time, txHash common.Hash, err error) {
+	attempt := &FraudProofAttempt{Time: now}
+	if txHash != (common.Hash{}) {
+		attempt.TxHash = txHash.Hex()
+	}
+	switch {
+	case err == nil:
+		record.Status = FraudProofStatusSubmitted
+		record.Result = txHash.Hex()
+	case errors.Is(err, errSubmitterIsCommitter), errors.Is(err, errSubmissionDisabled):
+		record.Status = FraudProofStatusSkipped
+		record.Result = err.Error()
+	case errors.Is(err, errFraudProofWouldFail):
+		attempt.Error = err.Error()
+		record.Status = FraudProofStatusRejected
+		record.Result = err.Error()
+	default:
+		attempt.Error = err.Error()
+		if len(record.Attempts)+1 >= maxFraudProofAttempts {
+			record.Status = FraudProofStatusFailed
+			record.Result = "Too many failed attempts"
+		}
+	}
+	record.Attempts = append(record.Attempts, attempt)
+}
+
+func (v *Validator) putFraudProofRecord(record *FraudProofRecord) {
+	err := v.fraudProofStore.Put(record)
+	if err != nil {
+		log.Err(err).
+			Uint64("blockNumber", record.BlockNumber).
+			Uint64("transitionIndex", record.TransitionIndex).
+			Msg("Failed to persist fraud proof record")
+	}
+}
+
+// retryPendingFraudProofs resubmits the pending proofs whose submission failed so far. Proofs that were never
+// attempted are only included at startup, as they are otherwise still queued for their first attempt.
+func (v *Validator) retryPendingFraudProofs(includeUnattempted bool) {
+	records, err := v.fraudProofStore.Pending()
+	if err != nil {
+		log.Err(err).Msg("Failed to load pending fraud proofs")
+		return
+	}
+	for _, record := range records {
+		if len(record.Attempts) == 0 && !includeUnattempted {
+			continue
+		}
+		proof, err := v.serializer.DeserializeContractFraudProof(record.ContractFraudProof)
+		if err != nil {
+			log.Err(err).Msg("Failed to deserialize pending fraud proof")
+			record.Status = FraudProofStatusFailed
+			record.Result = err.Error()
+			v.putFraudProofRecord(record)
+			continue
+		}
+		log.Info().
+			Uint64("blockNumber", record.BlockNumber).
+			Uint64("transitionIndex", record.TransitionIndex).
+			Int("attempts", len(record.Attempts)).
+			Msg("Retrying pending fraud proof")
+		v.submitFraudProofRecord(record, proof)
+	}
+}
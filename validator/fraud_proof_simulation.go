@@ -0,0 +1,159 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/celer-network/go-rollup/types"
+	"github.com/celer-network/go-rollup/utils"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	fraudProofGasLimit = 8000000
+	fraudProofGasPrice = 10e9 // 10 Gwei
+)
+
+// Known revert reasons of RollupChain.proveTransitionInvalid and what they mean for the proof we built
+var fraudProofRevertExplanations = map[string]string{
+	"No fraud detected!":                                                                     "The contract re-executed the transition and got the committed post-state root, the local state has likely diverged from the chain",
+	"The first transition must be included!":                                                 "The pre-state transition is not included in its block, the local copy of the previous block may be stale",
+	"The second transition must be included!":                                                "The invalid transition is not included in its block, the block may have been pruned already",
+	"Transitions must be sequential!":                                                        "The pre-state transition does not directly precede the invalid transition",
+	"Blocks must be one after another or equal.":                                             "The pre-state transition is not in the previous block",
+	"_transition0 must be last in its block.":                                                "The pre-state transition is not the last transition of the previous block",
+	"_transition0 must be first in its block.":                                               "The invalid transition is not the first transition of its block",
+	"Supplied storage slot index is incorrect!":                                              "The storage slots do not match the access list of the invalid transition",
+	"If the preStateRoot is invalid, then prove that invalid instead!":                       "The pre-state transition cannot be decoded, it should be proven invalid first",
+	"Failed same root verification check! This was an inclusion proof for a different tree!": "The storage slot inclusion proofs do not match the pre-state root",
+}
+
+type fraudProofSimulation struct {
+	success bool
+	reason  string
+	gasUsed uint64
+}
+
+// simulateContractFraudProof dry-runs RollupChain.proveTransitionInvalid with eth_call against the current
+// chain head, so that we only spend gas on proofs that would succeed.
+func (v *Validator) simulateContractFraudProof(proof *types.ContractFraudProof) (*fraudProofSimulation, error) {
+	data, err := proof.Serialize(v.serializer)
+	if err != nil {
+		return nil, err
+	}
+	var from common.Address
+	if v.mainchainAuth != nil {
+		from = v.mainchainAuth.From
+	}
+	msg := ethereum.CallMsg{
+		From:     from,
+		To:       &v.rollupChainAddress,
+		Gas:      fraudProofGasLimit,
+		GasPrice: big.NewInt(fraudProofGasPrice),
+		Data:     data,
+	}
+	return simulateFraudProofCall(context.Background(), v.mainchainClient, msg)
+}
+
+// contractCaller is the part of the mainchain client that fraud proofs are simulated with
+type contractCaller interface {
+	CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+	EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error)
+}
+
+// simulateFraudProofCall reports a failed simulation only if the call reverted. Other errors, like a failed
+// connection to the node, are returned, so that the proof is simulated again later.
+func simulateFraudProofCall(
+	ctx context.Context, caller contractCaller, msg ethereum.CallMsg) (*fraudProofSimulation, error) {
+	// Nodes differ in how they report a reverted eth_call: some return the revert data as the result,
+	// others return an error. Gas estimation fails in both cases, so use it to decide on success.
+	result, callErr := caller.CallContract(ctx, msg, nil)
+	if reason, ok := utils.UnpackRevertReason(result); ok {
+		return &fraudProofSimulation{success: false, reason: reason}, nil
+	}
+	if callErr != nil {
+		if reason, ok := decodeRevertError(callErr); ok {
+			return &fraudProofSimulation{success: false, reason: reason}, nil
+		}
+		return nil, callErr
+	}
+	gasUsed, estimateErr := caller.EstimateGas(ctx, msg)
+	if estimateErr != nil {
+		if reason, ok := decodeRevertError(estimateErr); ok {
+			return &fraudProofSimulation{success: false, reason: reason}, nil
+		}
+		return nil, estimateErr
+	}
+	return &fraudProofSimulation{success: true, gasUsed: gasUsed}, nil
+}
+
+// revertDataError is implemented by the JSON-RPC errors of nodes that return the revert data with the error
+type revertDataError interface {
+	ErrorData() interface{}
+}
+
+// Messages of the errors that nodes return for a reverted eth_call or eth_estimateGas
+var revertErrorMessages = []string{
+	"execution reverted",
+	"always failing transaction",
+	"VM Exception while processing transaction",
+}
+
+const executionRevertedPrefix = "execution reverted: "
+
+// decodeRevertError returns the revert reason of an error returned for a call, and whether the error is a
+// revert at all
+func decodeRevertError(err error) (string, bool) {
+	var dataErr revertDataError
+	if errors.As(err, &dataErr) {
+		if data, ok := dataErr.ErrorData().(string); ok {
+			if revertData, decodeErr := hexutil.Decode(data); decodeErr == nil {
+				if reason, ok := utils.UnpackRevertReason(revertData); ok {
+					return reason, true
+				}
+			}
+		}
+	}
+	message := err.Error()
+	for _, revertMessage := range revertErrorMessages {
+		if strings.Contains(message, revertMessage) {
+			return strings.TrimPrefix(message, executionRevertedPrefix), true
+		}
+	}
+	return "", false
+}
+
+func logFraudProofSimulation(proof *types.ContractFraudProof, simulation *fraudProofSimulation) {
+	invalidProof := proof.InvalidIncludedTransition.InclusionProof
+	preStateProof := proof.PreStateIncludedTransition.InclusionProof
+	slotIndices := make([]uint64, len(proof.TransitionStorageSlots))
+	for i, slot := range proof.TransitionStorageSlots {
+		slotIndices[i] = slot.StorageSlot.SlotIndex.Uint64()
+	}
+	event := log.Info()
+	if !simulation.success {
+		event = log.Warn()
+	}
+	event.
+		Bool("success", simulation.success).
+		Uint64("blockNumber", invalidProof.BlockNumber.Uint64()).
+		Uint64("transitionIndex", invalidProof.TransitionIndex.Uint64()).
+		Uint64("preStateBlockNumber", preStateProof.BlockNumber.Uint64()).
+		Uint64("preStateTransitionIndex", preStateProof.TransitionIndex.Uint64()).
+		Str("invalidTransition", common.Bytes2Hex(proof.InvalidIncludedTransition.Transition)).
+		Uints64("storageSlots", slotIndices)
+	if simulation.success {
+		event.Uint64("estimatedGas", simulation.gasUsed).Msg("Fraud proof simulation succeeded")
+		return
+	}
+	explanation, known := fraudProofRevertExplanations[simulation.reason]
+	if !known {
+		explanation = "Unknown failure"
+	}
+	event.Str("reason", simulation.reason).Str("explanation", explanation).Msg("Fraud proof simulation failed")
+}
@@ -0,0 +1,111 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+type fakeContractCaller struct {
+	callResult  []byte
+	callErr     error
+	estimateErr error
+}
+
+func (c *fakeContractCaller) CallContract(
+	ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return c.callResult, c.callErr
+}
+
+func (c *fakeContractCaller) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	if c.estimateErr != nil {
+		return 0, c.estimateErr
+	}
+	return 500000, nil
+}
+
+// testRevertError is a JSON-RPC error of a node that returns the revert data with the error
+type testRevertError struct {
+	message string
+	data    string
+}
+
+func (err *testRevertError) Error() string {
+	return err.message
+}
+
+func (err *testRevertError) ErrorData() interface{} {
+	return err.data
+}
+
+func TestSimulateFraudProofCall(t *testing.T) {
+	revertData := packTestRevertReason(t, "No fraud detected!")
+	transportErr := errors.New("Post http://localhost:8545: dial tcp: connection refused")
+	tests := []struct {
+		name    string
+		caller  *fakeContractCaller
+		success bool
+		reason  string
+		err     error
+	}{
+		{
+			name:    "success",
+			caller:  &fakeContractCaller{},
+			success: true,
+		},
+		{
+			name: "revert data",
+			caller: &fakeContractCaller{
+				callResult:  revertData,
+				estimateErr: errors.New("gas required exceeds allowance or always failing transaction"),
+			},
+			reason: "No fraud detected!",
+		},
+		{
+			name: "revert error with data",
+			caller: &fakeContractCaller{
+				callErr: &testRevertError{message: "execution reverted: No fraud detected!", data: hexutil.Encode(revertData)},
+			},
+			reason: "No fraud detected!",
+		},
+		{
+			name: "revert error without data",
+			caller: &fakeContractCaller{
+				estimateErr: errors.New("gas required exceeds allowance or always failing transaction"),
+			},
+			reason: "gas required exceeds allowance or always failing transaction",
+		},
+		{
+			name:   "transport error on call",
+			caller: &fakeContractCaller{callErr: transportErr},
+			err:    transportErr,
+		},
+		{
+			name:   "transport error on gas estimation",
+			caller: &fakeContractCaller{estimateErr: transportErr},
+			err:    transportErr,
+		},
+	}
+	for _, test := range tests {
+		simulation, err := simulateFraudProofCall(context.Background(), test.caller, ethereum.CallMsg{})
+		if test.err != nil {
+			// The proof stays pending and is simulated again
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: error %v, expected %v", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if simulation.success != test.success || simulation.reason != test.reason {
+			t.Errorf("%s: success %t with reason %q, expected %t with %q",
+				test.name, simulation.success, simulation.reason, test.success, test.reason)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package validator
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/celer-network/go-rollup/db"
+	"github.com/celer-network/go-rollup/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+type FraudProofStatus string
+
+const (
+	// FraudProofStatusPending means the proof has not been submitted successfully yet and will be retried
+	FraudProofStatusPending FraudProofStatus = "pending"
+	// FraudProofStatusSubmitted means the proof was mined successfully
+	FraudProofStatusSubmitted FraudProofStatus = "submitted"
+	// FraudProofStatusRejected means the dry run showed that the proof would fail on chain
+	FraudProofStatusRejected FraudProofStatus = "rejected"
+	// FraudProofStatusSkipped means the proof was not submitted by us, because we are the committer or
+	// submission is disabled
+	FraudProofStatusSkipped FraudProofStatus = "skipped"
+	// FraudProofStatusFailed means the proof could not be generated or ran out of attempts
+	FraudProofStatusFailed FraudProofStatus = "failed"
+)
+
+const maxFraudProofAttempts = 5
+
+type FraudProofInput struct {
+	SlotIndex      *big.Int           `json:"slotIndex"`
+	AccountInfo    *types.AccountInfo `json:"accountInfo"`
+	StateRoot      hexutil.Bytes      `json:"stateRoot"`
+	InclusionProof []common.Hash      `json:"inclusionProof"`
+}
+
+type FraudProofAttempt struct {
+	Time   time.Time `json:"time"`
+	TxHash string    `json:"txHash,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// FraudProofRecord is the persisted audit trail of a detected fraud
+type FraudProofRecord struct {
+	BlockNumber        uint64               `json:"blockNumber"`
+	TransitionIndex    uint64               `json:"transitionIndex"`
+	DetectedAt         time.Time            `json:"detectedAt"`
+	Reason             string               `json:"reason"`
+	Transition         hexutil.Bytes        `json:"transition"`
+	Inputs             []*FraudProofInput   `json:"inputs"`
+	ContractFraudProof hexutil.Bytes        `json:"contractFraudProof,omitempty"`
+	Attempts           []*FraudProofAttempt `json:"attempts"`
+	Status             FraudProofStatus     `json:"status"`
+	Result             string               `json:"result,omitempty"`
+}
+
+func newFraudProofRecord(
+	serializer *types.Serializer, localFraudProof *types.LocalFraudProof) (*FraudProofRecord, error) {
+	transition, err := localFraudProof.Transition.Serialize(serializer)
+	if err != nil {
+		return nil, err
+	}
+	inputs := make([]*FraudProofInput, len(localFraudProof.Inputs))
+	for i, snapshot := range localFraudProof.Inputs {
+		if snapshot == nil {
+			continue
+		}
+		inclusionProof := make([]common.Hash, len(snapshot.InclusionProof))
+		for j, sibling := range snapshot.InclusionProof {
+			inclusionProof[j] = sibling
+		}
+		inputs[i] = &FraudProofInput{
+			SlotIndex:      snapshot.SlotIndex,
+			AccountInfo:    snapshot.AccountInfo,
+			StateRoot:      snapshot.StateRoot,
+			InclusionProof: inclusionProof,
+		}
+	}
+	return &FraudProofRecord{
+		BlockNumber:     localFraudProof.Position.BlockNumber,
+		TransitionIndex: localFraudProof.Position.TransitionIndex,
+		DetectedAt:      time.Now(),
+		Reason:          localFraudProof.Reason,
+		Transition:      transition,
+		Inputs:          inputs,
+		Status:          FraudProofStatusPending,
+	}, nil
+}
+
+// FraudProofStore persists FraudProofRecords keyed by their transition position
+type FraudProofStore struct {
+	db db.DB
+}
+
+func NewFraudProofStore(db db.DB) *FraudProofStore {
+	return &FraudProofStore{db: db}
+}
+
+func fraudProofKey(blockNumber uint64, transitionIndex uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key[:8], blockNumber)
+	binary.BigEndian.PutUint64(key[8:], transitionIndex)
+	return key
+}
+
+func (s *FraudProofStore) Put(record *FraudProofRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.db.Set(db.NamespaceFraudProof, fraudProofKey(record.BlockNumber, record.TransitionIndex), data)
+}
+
+func (s *FraudProofStore) Get(blockNumber uint64, transitionIndex uint64) (*FraudProofRecord, bool, error) {
+	data, exists, err := s.db.Get(db.NamespaceFraudProof, fraudProofKey(blockNumber, transitionIndex))
+	if err != nil || !exists {
+		return nil, false, err
+	}
+	var record FraudProofRecord
+	err = json.Unmarshal(data, &record)
+	if err != nil {
+		return nil, false, err
+	}
+	return &record, true, nil
+}
+
+// List returns all records ordered by transition position
+func (s *FraudProofStore) List() ([]*FraudProofRecord, error) {
+	var records []*FraudProofRecord
+	iter := s.db.IteratePrefix(db.NamespaceFraudProof, nil)
+	defer iter.Close()
+	for iter.Valid() {
+		data, err := iter.Value()
+		if err != nil {
+			return nil, err
+		}
+		var record FraudProofRecord
+		err = json.Unmarshal(data, &record)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, &record)
+		err = iter.Next()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// Pending returns the records that should be submitted again
+func (s *FraudProofStore) Pending() ([]*FraudProofRecord, error) {
+	records, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var pending []*FraudProofRecord
+	for _, record := range records {
+		if record.Status == FraudProofStatusPending {
+			pending = append(pending, record)
+		}
+	}
+	return pending, nil
+}
+
+// Export writes all records as a JSON array for incident review
+func (s *FraudProofStore) Export(w io.Writer) error {
+	records, err := s.List()
+	if err != nil {
+		return err
+	}
+	if records == nil {
+		records = []*FraudProofRecord{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
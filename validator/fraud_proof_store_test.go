@@ -0,0 +1,173 @@
+package validator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/celer-network/go-rollup/db/memorydb"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func newTestFraudProofRecord(blockNumber uint64, transitionIndex uint64) *FraudProofRecord {
+	return &FraudProofRecord{
+		BlockNumber:        blockNumber,
+		TransitionIndex:    transitionIndex,
+		DetectedAt:         time.Unix(1600000000, 0).UTC(),
+		Reason:             "test",
+		Transition:         []byte{byte(blockNumber), byte(transitionIndex)},
+		ContractFraudProof: []byte{1, 2, 3},
+		Status:             FraudProofStatusPending,
+	}
+}
+
+func TestFraudProofStore(t *testing.T) {
+	store := NewFraudProofStore(memorydb.NewDB())
+
+	_, exists, err := store.Get(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("record exists in empty store")
+	}
+
+	// Put out of order to check that List orders by position
+	positions := [][2]uint64{{2, 1}, {1, 3}, {2, 0}, {256, 0}, {1, 0}}
+	for _, position := range positions {
+		err = store.Put(newTestFraudProofRecord(position[0], position[1]))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	record, exists, err := store.Get(1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists || record.BlockNumber != 1 || record.TransitionIndex != 3 ||
+		!bytes.Equal(record.Transition, []byte{1, 3}) || !record.DetectedAt.Equal(time.Unix(1600000000, 0)) {
+		t.Fatalf("unexpected record %+v", record)
+	}
+
+	records, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][2]uint64{{1, 0}, {1, 3}, {2, 0}, {2, 1}, {256, 0}}
+	if len(records) != len(expected) {
+		t.Fatalf("listed %d records, expected %d", len(records), len(expected))
+	}
+	for i, record := range records {
+		if record.BlockNumber != expected[i][0] || record.TransitionIndex != expected[i][1] {
+			t.Errorf("record %d at %d/%d, expected %d/%d",
+				i, record.BlockNumber, record.TransitionIndex, expected[i][0], expected[i][1])
+		}
+	}
+
+	// Overwrite two records with final states
+	record.Status = FraudProofStatusSubmitted
+	record.BlockNumber, record.TransitionIndex = 2, 0
+	err = store.Put(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record = newTestFraudProofRecord(1, 0)
+	record.Status = FraudProofStatusFailed
+	err = store.Put(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := store.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = [][2]uint64{{1, 3}, {2, 1}, {256, 0}}
+	if len(pending) != len(expected) {
+		t.Fatalf("%d pending records, expected %d", len(pending), len(expected))
+	}
+	for i, record := range pending {
+		if record.BlockNumber != expected[i][0] || record.TransitionIndex != expected[i][1] {
+			t.Errorf("pending record %d at %d/%d, expected %d/%d",
+				i, record.BlockNumber, record.TransitionIndex, expected[i][0], expected[i][1])
+		}
+	}
+
+	var buf bytes.Buffer
+	err = store.Export(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported []*FraudProofRecord
+	err = json.Unmarshal(buf.Bytes(), &exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exported) != len(positions) {
+		t.Errorf("exported %d records, expected %d", len(exported), len(positions))
+	}
+}
+
+func TestFraudProofStoreExportEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	err := NewFraudProofStore(memorydb.NewDB()).Export(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bytes.TrimSpace(buf.Bytes())) != "[]" {
+		t.Errorf("exported %q, expected an empty array", buf.String())
+	}
+}
+
+func TestRecordFraudProofAttempt(t *testing.T) {
+	txHash := common.HexToHash("0x01")
+	now := time.Now()
+	tests := []struct {
+		name   string
+		txHash common.Hash
+		err    error
+		status FraudProofStatus
+	}{
+		{"submitted", txHash, nil, FraudProofStatusSubmitted},
+		{"committer", common.Hash{}, errSubmitterIsCommitter, FraudProofStatusSkipped},
+		{"disabled", common.Hash{}, errSubmissionDisabled, FraudProofStatusSkipped},
+		{"would fail", common.Hash{}, errFraudProofWouldFail, FraudProofStatusRejected},
+		{"error", common.Hash{}, errors.New("nonce too low"), FraudProofStatusPending},
+		{"mining error", txHash, errors.New("transaction reverted"), FraudProofStatusPending},
+	}
+	for _, test := range tests {
+		record := newTestFraudProofRecord(1, 0)
+		recordFraudProofAttempt(record, now, test.txHash, test.err)
+		if record.Status != test.status {
+			t.Errorf("%s: status %s, expected %s", test.name, record.Status, test.status)
+		}
+		if len(record.Attempts) != 1 || !record.Attempts[0].Time.Equal(now) {
+			t.Fatalf("%s: unexpected attempts %+v", test.name, record.Attempts)
+		}
+		attempt := record.Attempts[0]
+		if (test.txHash != common.Hash{}) != (attempt.TxHash == test.txHash.Hex()) {
+			t.Errorf("%s: attempt tx hash %s", test.name, attempt.TxHash)
+		}
+		if test.status == FraudProofStatusPending && attempt.Error != test.err.Error() {
+			t.Errorf("%s: attempt error %q, expected %q", test.name, attempt.Error, test.err)
+		}
+	}
+}
+
+func TestRecordFraudProofAttemptMaxAttempts(t *testing.T) {
+	record := newTestFraudProofRecord(1, 0)
+	err := errors.New("connection refused")
+	for i := 1; i <= maxFraudProofAttempts; i++ {
+		if record.Status != FraudProofStatusPending {
+			t.Fatalf("status %s before attempt %d", record.Status, i)
+		}
+		recordFraudProofAttempt(record, time.Now(), common.Hash{}, err)
+	}
+	if record.Status != FraudProofStatusFailed {
+		t.Errorf("status %s after %d attempts, expected %s", record.Status, maxFraudProofAttempts, FraudProofStatusFailed)
+	}
+	if len(record.Attempts) != maxFraudProofAttempts {
+		t.Errorf("%d attempts, expected %d", len(record.Attempts), maxFraudProofAttempts)
+	}
+}
@@ -0,0 +1,159 @@
+package validator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	webhookTimeout = 10 * time.Second
+	// A report is retried with exponential backoff, so the last attempt is made about 15 seconds after the first
+	webhookMaxAttempts  = 5
+	webhookRetryBackoff = time.Second
+	// Number of fraud reports waiting for the reporters before new ones are dropped
+	fraudReportQueueSize = 64
+)
+
+// FraudReport is what a FraudReporter receives for every detected fraud. Anyone can submit the proof by
+// sending ContractFraudProof as calldata to RollupChain.
+type FraudReport struct {
+	RollupChain common.Address `json:"rollupChain"`
+	*FraudProofRecord
+}
+
+// FraudReporter publishes detected frauds, e.g. for auditors that do not submit proofs themselves
+type FraudReporter interface {
+	Report(report *FraudReport) error
+}
+
+// FileFraudReporter appends reports to a file as JSON lines
+type FileFraudReporter struct {
+	lock sync.Mutex
+	path string
+}
+
+func NewFileFraudReporter(path string) *FileFraudReporter {
+	return &FileFraudReporter{path: path}
+}
+
+func (r *FileFraudReporter) Report(report *FraudReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	file, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(data, '\n'))
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WebhookFraudReporter POSTs reports as JSON to a URL. Failed requests are retried with backoff, except for
+// client errors other than 429 Too Many Requests, which a retry does not fix.
+type WebhookFraudReporter struct {
+	url          string
+	client       *http.Client
+	maxAttempts  int
+	retryBackoff time.Duration
+}
+
+func NewWebhookFraudReporter(url string) *WebhookFraudReporter {
+	return &WebhookFraudReporter{
+		url:          url,
+		client:       &http.Client{Timeout: webhookTimeout},
+		maxAttempts:  webhookMaxAttempts,
+		retryBackoff: webhookRetryBackoff,
+	}
+}
+
+func (r *WebhookFraudReporter) Report(report *FraudReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	backoff := r.retryBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := r.post(data)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= r.maxAttempts {
+			return fmt.Errorf("Webhook failed after %d attempts: %w", attempt, err)
+		}
+		log.Warn().Err(err).Int("attempt", attempt).Dur("backoff", backoff).Msg("Webhook failed, retrying")
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post sends one request, and returns whether it is worth retrying if it fails
+func (r *WebhookFraudReporter) post(data []byte) (bool, error) {
+	resp, err := r.client.Post(r.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("Webhook returned status %s", resp.Status)
+	}
+	return false, nil
+}
+
+// AddFraudReporter registers a reporter for detected frauds, it must be called before Start
+func (v *Validator) AddFraudReporter(reporter FraudReporter) {
+	v.fraudReporters = append(v.fraudReporters, reporter)
+}
+
+// reportFraudProof queues a report of the record as it is now. It does not wait for the reporters, which may
+// retry for a while.
+func (v *Validator) reportFraudProof(record *FraudProofRecord) {
+	if len(v.fraudReporters) == 0 {
+		return
+	}
+	// The record keeps changing with submission attempts
+	snapshot := *record
+	snapshot.Attempts = append([]*FraudProofAttempt(nil), record.Attempts...)
+	report := &FraudReport{
+		RollupChain:      v.rollupChainAddress,
+		FraudProofRecord: &snapshot,
+	}
+	select {
+	case v.fraudReports <- report:
+	default:
+		log.Error().
+			Uint64("blockNumber", record.BlockNumber).
+			Uint64("transitionIndex", record.TransitionIndex).
+			Msg("Fraud report queue is full, dropping report")
+	}
+}
+
+// sendFraudReports sends the queued reports to all reporters, one report at a time
+func (v *Validator) sendFraudReports() {
+	for report := range v.fraudReports {
+		for _, reporter := range v.fraudReporters {
+			err := reporter.Report(report)
+			if err != nil {
+				log.Err(err).
+					Uint64("blockNumber", report.BlockNumber).
+					Uint64("transitionIndex", report.TransitionIndex).
+					Msg("Failed to report fraud proof")
+			}
+		}
+	}
+}
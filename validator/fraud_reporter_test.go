@@ -0,0 +1,328 @@
+package validator
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/celer-network/go-rollup/types"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+var testRollupChainAddress = common.HexToAddress("0x3000000000000000000000000000000000000001")
+
+func newTestFraudReport(blockNumber uint64) *FraudReport {
+	return &FraudReport{
+		RollupChain:      testRollupChainAddress,
+		FraudProofRecord: newTestFraudProofRecord(blockNumber, 0),
+	}
+}
+
+func checkTestFraudReport(t *testing.T, report *FraudReport, blockNumber uint64) {
+	if report.RollupChain != testRollupChainAddress {
+		t.Errorf("report of rollup chain %s", report.RollupChain.Hex())
+	}
+	if report.FraudProofRecord == nil || report.BlockNumber != blockNumber {
+		t.Errorf("report %+v, expected block %d", report.FraudProofRecord, blockNumber)
+	}
+}
+
+func TestFileFraudReporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fraud-reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	reporter := NewFileFraudReporter(filepath.Join(dir, "reports.jsonl"))
+	for blockNumber := uint64(1); blockNumber <= 2; blockNumber++ {
+		err = reporter.Report(newTestFraudReport(blockNumber))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := os.Open(filepath.Join(dir, "reports.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	blockNumber := uint64(1)
+	for ; scanner.Scan(); blockNumber++ {
+		var report FraudReport
+		err = json.Unmarshal(scanner.Bytes(), &report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkTestFraudReport(t, &report, blockNumber)
+	}
+	if blockNumber != 3 {
+		t.Errorf("read %d reports, expected 2", blockNumber-1)
+	}
+}
+
+func TestFileFraudReporterFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fraud-reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	reporter := NewFileFraudReporter(filepath.Join(dir, "missing", "reports.jsonl"))
+	if err = reporter.Report(newTestFraudReport(1)); err == nil {
+		t.Error("report to a missing directory did not fail")
+	}
+}
+
+// newTestWebhook returns a webhook server that responds with the given statuses in turn, and then with 200,
+// and counts the requests
+func newTestWebhook(t *testing.T, statuses []int, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(requests, 1)
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("webhook got %s request with content type %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		var report FraudReport
+		err := json.NewDecoder(r.Body).Decode(&report)
+		if err != nil {
+			t.Error(err)
+		}
+		checkTestFraudReport(t, &report, 1)
+		if int(n) <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+		}
+	}))
+}
+
+func newTestWebhookFraudReporter(url string) *WebhookFraudReporter {
+	reporter := NewWebhookFraudReporter(url)
+	reporter.retryBackoff = time.Millisecond
+	return reporter
+}
+
+func TestWebhookFraudReporter(t *testing.T) {
+	var requests int32
+	server := newTestWebhook(t, nil, &requests)
+	defer server.Close()
+	err := newTestWebhookFraudReporter(server.URL).Report(newTestFraudReport(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 {
+		t.Errorf("%d requests, expected 1", requests)
+	}
+}
+
+func TestWebhookFraudReporterRetry(t *testing.T) {
+	var requests int32
+	server := newTestWebhook(
+		t, []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusBadGateway}, &requests)
+	defer server.Close()
+	err := newTestWebhookFraudReporter(server.URL).Report(newTestFraudReport(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 4 {
+		t.Errorf("%d requests, expected 4", requests)
+	}
+}
+
+func TestWebhookFraudReporterFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		requests int32
+	}{
+		{"server error", http.StatusInternalServerError, webhookMaxAttempts},
+		{"client error", http.StatusBadRequest, 1},
+	}
+	for _, test := range tests {
+		statuses := make([]int, webhookMaxAttempts)
+		for i := range statuses {
+			statuses[i] = test.status
+		}
+		var requests int32
+		server := newTestWebhook(t, statuses, &requests)
+		err := newTestWebhookFraudReporter(server.URL).Report(newTestFraudReport(1))
+		server.Close()
+		if err == nil {
+			t.Errorf("%s: report did not fail", test.name)
+		}
+		if requests != test.requests {
+			t.Errorf("%s: %d requests, expected %d", test.name, requests, test.requests)
+		}
+	}
+
+	// Connection errors are retried as well
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	if err := newTestWebhookFraudReporter(server.URL).Report(newTestFraudReport(1)); err == nil {
+		t.Error("report to a closed server did not fail")
+	}
+}
+
+type chanFraudReporter chan *FraudReport
+
+func (r chanFraudReporter) Report(report *FraudReport) error {
+	r <- report
+	return nil
+}
+
+// newTestMainchainNode returns a JSON-RPC server that answers the calls of a fraud proof dry run. If
+// revertReason is not empty, eth_call reverts with it.
+func newTestMainchainNode(t *testing.T, revertReason string) *httptest.Server {
+	callResult := []byte{}
+	if revertReason != "" {
+		callResult = packTestRevertReason(t, revertReason)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		response := map[string]interface{}{"jsonrpc": "2.0", "id": request.ID}
+		switch request.Method {
+		case "eth_call":
+			response["result"] = hexutil.Bytes(callResult)
+		case "eth_estimateGas":
+			response["result"] = hexutil.Uint64(500000)
+		default:
+			t.Errorf("unexpected call %s", request.Method)
+			response["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(response)
+		if err != nil {
+			t.Error(err)
+		}
+	}))
+}
+
+func packTestRevertReason(t *testing.T, reason string) []byte {
+	stringTy, err := abi.NewType("string", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := abi.Arguments{{Type: stringTy}}.Pack(reason)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append([]byte{0x08, 0xc3, 0x79, 0xa0}, data...)
+}
+
+func TestAuditor(t *testing.T) {
+	tests := []struct {
+		name         string
+		revertReason string
+		status       FraudProofStatus
+	}{
+		{"valid proof", "", FraudProofStatusSkipped},
+		{"failing proof", "No fraud detected!", FraudProofStatusRejected},
+	}
+	for _, test := range tests {
+		node := newTestMainchainNode(t, test.revertReason)
+		client, err := ethclient.Dial(node.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// An auditor has no mainchain account
+		v, committer := newTestValidator(t)
+		v.mainchainClient = client
+		v.rollupChainAddress = testRollupChainAddress
+		reports := make(chanFraudReporter, 1)
+		v.AddFraudReporter(reports)
+		go v.submitFraudProofs()
+		go v.sendFraudReports()
+
+		validateTestGenesis(t, v, committer)
+		transitions := newDepositTransitions(t, committer, testAccounts, 10)
+		transitions[1].(*types.DepositTransition).StateRoot = [32]byte{1}
+		validateTestBlock(t, v, 1, transitions)
+
+		var report *FraudReport
+		select {
+		case report = <-reports:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: no fraud report", test.name)
+		}
+		node.Close()
+		if report.RollupChain != testRollupChainAddress || report.BlockNumber != 1 || report.TransitionIndex != 1 {
+			t.Errorf("%s: report of transition %d of block %d on %s",
+				test.name, report.TransitionIndex, report.BlockNumber, report.RollupChain.Hex())
+		}
+		if report.Status != test.status {
+			t.Errorf("%s: reported status %s, expected %s: %s", test.name, report.Status, test.status, report.Result)
+		}
+		if len(report.ContractFraudProof) == 0 {
+			t.Errorf("%s: report without contract fraud proof", test.name)
+		}
+		record, _, err := v.fraudProofStore.Get(1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.Status != test.status || len(record.Attempts) != 1 {
+			t.Errorf("%s: recorded status %s after %d attempts", test.name, record.Status, len(record.Attempts))
+		}
+	}
+}
+
+// blockingFraudReporter passes reports on only once it is released, like a webhook that keeps retrying
+type blockingFraudReporter struct {
+	release chan struct{}
+	reports chan *FraudReport
+}
+
+func (r *blockingFraudReporter) Report(report *FraudReport) error {
+	<-r.release
+	r.reports <- report
+	return nil
+}
+
+func TestReportFraudProofDoesNotWait(t *testing.T) {
+	v, _ := newTestValidator(t)
+	v.rollupChainAddress = testRollupChainAddress
+	reporter := &blockingFraudReporter{release: make(chan struct{}), reports: make(chan *FraudReport, 2)}
+	v.AddFraudReporter(reporter)
+	go v.sendFraudReports()
+
+	reported := make(chan struct{})
+	record := newTestFraudProofRecord(1, 0)
+	go func() {
+		v.reportFraudProof(record)
+		v.reportFraudProof(record)
+		close(reported)
+	}()
+	select {
+	case <-reported:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reporting waits for the reporters")
+	}
+	// The reports are of the record when it was reported
+	record.Status = FraudProofStatusSubmitted
+	close(reporter.release)
+	for i := 0; i < 2; i++ {
+		select {
+		case report := <-reporter.reports:
+			checkTestFraudReport(t, report, 1)
+			if report.Status == FraudProofStatusSubmitted {
+				t.Error("report changed after it was queued")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("report %d not sent", i)
+		}
+	}
+}
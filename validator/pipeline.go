@@ -0,0 +1,183 @@
+package validator
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/celer-network/go-rollup/types"
+	"github.com/rs/zerolog/log"
+)
+
+// Committed blocks flow through three stages: the watcher queues them without blocking, the decoder
+// deserializes them and builds their transition trees ahead of time, and a single goroutine validates them
+// in commit order against the local state, which keeps the result deterministic.
+
+const (
+	decodedBlockBufferSize = 16
+	backlogLogInterval     = 30 * time.Second
+)
+
+type committedBlock struct {
+	blockNumber uint64
+	transitions [][]byte
+	// Position of the RollupBlockCommitted event
+	position eventPosition
+}
+
+type decodedBlock struct {
+	block    *types.RollupBlock
+	info     *types.RollupBlockInfo
+	position eventPosition
+}
+
+// blockQueue is an unbounded FIFO queue, so that the watcher never blocks on a slow validator
+type blockQueue struct {
+	lock   sync.Mutex
+	blocks []*committedBlock
+	notify chan struct{}
+}
+
+func newBlockQueue() *blockQueue {
+	return &blockQueue{
+		notify: make(chan struct{}, 1),
+	}
+}
+
+func (q *blockQueue) push(block *committedBlock) {
+	q.lock.Lock()
+	q.blocks = append(q.blocks, block)
+	q.lock.Unlock()
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pop blocks until a block is available
+func (q *blockQueue) pop() *committedBlock {
+	for {
+		q.lock.Lock()
+		if len(q.blocks) > 0 {
+			block := q.blocks[0]
+			q.blocks[0] = nil
+			q.blocks = q.blocks[1:]
+			q.lock.Unlock()
+			return block
+		}
+		q.lock.Unlock()
+		<-q.notify
+	}
+}
+
+// queueBlock queues a committed block for validation
+func (v *Validator) queueBlock(block *committedBlock) {
+	atomic.AddInt32(&v.backlog, 1)
+	v.blockQueue.push(block)
+}
+
+// Backlog returns the number of committed blocks that have been caught but not fully validated yet. A single
+// counter is used for all stages, so that a block moving between stages is counted exactly once.
+func (v *Validator) Backlog() int {
+	return int(atomic.LoadInt32(&v.backlog))
+}
+
+func (v *Validator) logBacklog() {
+	ticker := time.NewTicker(backlogLogInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		backlog := v.Backlog()
+		if backlog > 0 {
+			log.Info().Int("backlog", backlog).Msg("Validator backlog")
+		}
+	}
+}
+
+func (v *Validator) decodeBlocks() {
+	for {
+		committed := v.blockQueue.pop()
+		decoded, err := v.decodeBlock(committed)
+		if err != nil {
+			log.Err(err).Uint64("blockNumber", committed.blockNumber).Msg("Failed to decode block")
+			v.blockProcessed(committed.position)
+			continue
+		}
+		v.decodedBlocks <- decoded
+	}
+}
+
+func (v *Validator) decodeBlock(committed *committedBlock) (*decodedBlock, error) {
+	transitions := make([]types.Transition, len(committed.transitions))
+	forEachParallel(len(transitions), func(i int) {
+		transitions[i] = v.serializer.DeserializeCommittedTransition(committed.transitions[i])
+	})
+	block := &types.RollupBlock{
+		BlockNumber: committed.blockNumber,
+		Transitions: transitions,
+	}
+	info, err := types.NewRollupBlockInfo(v.serializer, block)
+	if err != nil {
+		return nil, err
+	}
+	return &decodedBlock{block: block, info: info, position: committed.position}, nil
+}
+
+func (v *Validator) validateBlocks() {
+	for decoded := range v.decodedBlocks {
+		v.validateBlock(decoded)
+		v.blockProcessed(decoded.position)
+	}
+}
+
+// blockProcessed saves the event of a block that left the pipeline, so that a restart resumes after it
+func (v *Validator) blockProcessed(position eventPosition) {
+	atomic.AddInt32(&v.backlog, -1)
+	err := saveEventPosition(v.db, eventPositionRollupBlockCommitted, position)
+	if err != nil {
+		log.Err(err).Msg("Failed to save the position of the last validated block")
+	}
+}
+
+// prefetchStateSnapshots reads the snapshots of all slots accessed by the block in parallel at the current
+// state root. Their proofs are at the state root before the block, so fraud proofs prove their inputs again.
+func (v *Validator) prefetchStateSnapshots(block *types.RollupBlock) map[string]*types.StateSnapshot {
+	slotIndices := block.GetSlotIndices()
+	root := v.blockStateMachine.GetStateRoot()
+	snapshots := make([]*types.StateSnapshot, len(slotIndices))
+	forEachParallel(len(slotIndices), func(i int) {
+		snapshot, err := v.blockStateMachine.GetStateSnapshotForRoot(slotIndices[i].Bytes(), root)
+		if err != nil {
+			// Leave it to the sequential read to report the error
+			return
+		}
+		snapshots[i] = snapshot
+	})
+	prefetched := make(map[string]*types.StateSnapshot)
+	for i, snapshot := range snapshots {
+		if snapshot != nil {
+			prefetched[string(slotIndices[i].Bytes())] = snapshot
+		}
+	}
+	return prefetched
+}
+
+// forEachParallel calls fn for 0 <= i < n on up to runtime.NumCPU() goroutines and waits for all calls
+func forEachParallel(n int, fn func(i int)) {
+	numWorkers := runtime.NumCPU()
+	if numWorkers > n {
+		numWorkers = n
+	}
+	var next int32 = -1
+	var wg sync.WaitGroup
+	wg.Add(numWorkers)
+	for w := 0; w < numWorkers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := int(atomic.AddInt32(&next, 1)); i < n; i = int(atomic.AddInt32(&next, 1)) {
+				fn(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
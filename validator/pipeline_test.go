@@ -0,0 +1,96 @@
+package validator
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	rollupdb "github.com/celer-network/go-rollup/db"
+)
+
+func TestBlockQueue(t *testing.T) {
+	q := newBlockQueue()
+	for i := 0; i < 100; i++ {
+		q.push(&committedBlock{blockNumber: uint64(i)})
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 101; i++ {
+			if block := q.pop(); block.blockNumber != uint64(i) {
+				t.Errorf("popped block %d, expected %d", block.blockNumber, i)
+				return
+			}
+		}
+	}()
+	// pop waits for the next push
+	time.Sleep(10 * time.Millisecond)
+	q.push(&committedBlock{blockNumber: 100})
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pop did not return the pushed block")
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	v, committer := newTestValidator(t)
+	const numBlocks = 8
+	var postStateRoots [][]byte
+	for i := 0; i < numBlocks; i++ {
+		// Every block depends on the state of the previous one
+		transitions := newDepositTransitions(t, committer, testAccounts, int64(i+1))
+		v.queueBlock(newCommittedBlock(t, v, uint64(i), transitions))
+		postStateRoots = append(postStateRoots, committer.GetStateRoot())
+	}
+	if backlog := v.Backlog(); backlog != numBlocks {
+		t.Fatalf("backlog %d before validation, expected %d", backlog, numBlocks)
+	}
+
+	go v.decodeBlocks()
+	go v.validateBlocks()
+	deadline := time.Now().Add(10 * time.Second)
+	for backlog := v.Backlog(); backlog > 0; backlog = v.Backlog() {
+		if backlog > numBlocks {
+			t.Fatalf("backlog %d is larger than the number of blocks", backlog)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("backlog %d after timeout", backlog)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	records, err := v.fraudProofStore.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("%d frauds in valid blocks, first at block %d", len(records), records[0].BlockNumber)
+	}
+	if !bytes.Equal(v.stateMachine.GetStateRoot(), committer.GetStateRoot()) {
+		t.Error("state root differs from the committer")
+	}
+	for i := 0; i < numBlocks; i++ {
+		_, exists, err := v.db.Get(rollupdb.NamespaceRollupBlockNumber, big.NewInt(int64(i)).Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !exists {
+			t.Errorf("block %d not stored", i)
+		}
+	}
+	// The blocks are validated in commit order, so every block boundary is retained in order
+	roots, err := v.stateMachine.GetRetainedStateRoots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != numBlocks {
+		t.Fatalf("%d retained state roots, expected %d", len(roots), numBlocks)
+	}
+	for i, root := range roots {
+		if !bytes.Equal(root, postStateRoots[i]) {
+			t.Errorf("retained state root %d is not the post state root of block %d", i, i)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package validator
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/celer-network/go-rollup/db"
+	"github.com/celer-network/go-rollup/statemachine"
+	"github.com/celer-network/go-rollup/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// WitnessSource provides the witnesses of committed blocks for stateless validation
+type WitnessSource interface {
+	GetBlockWitness(blockNumber uint64) (*types.BlockWitness, error)
+}
+
+// SetWitnessSource switches the validator to stateless validation: every block is re-executed on a state
+// machine built from its witness instead of the local state, which is then neither read nor updated.
+func (v *Validator) SetWitnessSource(source WitnessSource) {
+	v.witnessSource = source
+}
+
+// newBlockStateMachine returns the state machine to validate a block on
+func (v *Validator) newBlockStateMachine(block *types.RollupBlock) (*statemachine.StateMachine, error) {
+	if v.witnessSource == nil {
+		return v.stateMachine, nil
+	}
+	witness, err := v.witnessSource.GetBlockWitness(block.BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if witness.BlockNumber != block.BlockNumber {
+		return nil, fmt.Errorf("Got the witness of block %d", witness.BlockNumber)
+	}
+	preStateRoot, err := v.getPreStateRoot(block.BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(preStateRoot, witness.StateRoot) {
+		return nil, fmt.Errorf(
+			"Witness state root %s differs from the post state root %s of the previous block",
+			common.Bytes2Hex(witness.StateRoot), common.Bytes2Hex(preStateRoot))
+	}
+	return statemachine.NewStatelessStateMachine(v.db, v.serializer, witness)
+}
+
+// getPreStateRoot returns the post state root of the previous block, which is the state root of its last
+// transition. If the previous block has not been validated, it is read from the block committed on chain, so
+// that the witness of a block is never trusted.
+func (v *Validator) getPreStateRoot(blockNumber uint64) ([]byte, error) {
+	if blockNumber == 0 {
+		return statemachine.EmptyStateRoot()
+	}
+	data, exists, err := v.db.Get(db.NamespaceRollupBlockNumber, big.NewInt(int64(blockNumber-1)).Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		log.Info().Uint64("blockNumber", blockNumber-1).Msg("Previous block unknown, reading it from the chain")
+		return GetCommittedStateRoot(v.rollupChain, v.serializer, blockNumber-1, v.startBlock)
+	}
+	previousBlock, err := v.serializer.DeserializeRollupBlockFromData(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(previousBlock.Transitions) == 0 {
+		return nil, fmt.Errorf("Previous block %d has no transitions", blockNumber-1)
+	}
+	stateRoot := previousBlock.Transitions[len(previousBlock.Transitions)-1].GetStateRoot()
+	return stateRoot[:], nil
+}
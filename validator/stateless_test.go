@@ -0,0 +1,92 @@
+package validator
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/celer-network/go-rollup/statemachine"
+	"github.com/celer-network/go-rollup/types"
+)
+
+type mapWitnessSource map[uint64]*types.BlockWitness
+
+func (s mapWitnessSource) GetBlockWitness(blockNumber uint64) (*types.BlockWitness, error) {
+	witness, exists := s[blockNumber]
+	if !exists {
+		return nil, errors.New("No witness")
+	}
+	return witness, nil
+}
+
+// newTestWitnessBlock applies a block of deposits on the committer, and returns it with its witness
+func newTestWitnessBlock(
+	t *testing.T, committer *statemachine.StateMachine, blockNumber uint64) (*types.RollupBlock, *types.BlockWitness) {
+	root := committer.GetStateRoot()
+	nextSlotIndex, err := committer.GetNextAccountSlotIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := &types.RollupBlock{
+		BlockNumber: blockNumber,
+		Transitions: newDepositTransitions(t, committer, testAccounts, 10),
+	}
+	witness, err := committer.GetBlockWitnessForRoot(block, root, nextSlotIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return block, witness
+}
+
+func TestStatelessValidation(t *testing.T) {
+	v, committer := newTestValidator(t)
+	witnesses := make(mapWitnessSource)
+	v.SetWitnessSource(witnesses)
+	emptyRoot, err := statemachine.EmptyStateRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for blockNumber := uint64(0); blockNumber < 2; blockNumber++ {
+		block, witness := newTestWitnessBlock(t, committer, blockNumber)
+		witnesses[blockNumber] = witness
+		_, err = v.newBlockStateMachine(block)
+		if err != nil {
+			t.Fatalf("block %d: %v", blockNumber, err)
+		}
+		validateTestBlock(t, v, blockNumber, block.Transitions)
+	}
+	records, err := v.fraudProofStore.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Errorf("%d frauds in valid blocks", len(records))
+	}
+	if !bytes.Equal(v.stateMachine.GetStateRoot(), emptyRoot) {
+		t.Error("stateless validation changed the local state")
+	}
+}
+
+func TestStatelessValidationWitnessRoot(t *testing.T) {
+	v, committer := newTestValidator(t)
+	witnesses := make(mapWitnessSource)
+	v.SetWitnessSource(witnesses)
+
+	// The witness of the first block must be at the empty state root
+	genesis, witness := newTestWitnessBlock(t, committer, 0)
+	witnesses[0] = &types.BlockWitness{BlockNumber: 0, StateRoot: committer.GetStateRoot()}
+	if _, err := v.newBlockStateMachine(genesis); err == nil {
+		t.Error("witness of the first block at another state root is accepted")
+	}
+	witnesses[0] = witness
+	validateTestBlock(t, v, 0, genesis.Transitions)
+
+	// The witness of a later block must be at the post state root of the previous block
+	block, _ := newTestWitnessBlock(t, committer, 1)
+	witnesses[1] = witness
+	witnesses[1].BlockNumber = 1
+	if _, err := v.newBlockStateMachine(block); err == nil {
+		t.Error("witness at another state root than the previous block is accepted")
+	}
+}
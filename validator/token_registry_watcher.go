@@ -0,0 +1,109 @@
+package validator
+
+import (
+	"github.com/celer-network/go-rollup/db"
+	"github.com/celer-network/rollup-contracts/bindings/go/mainchain"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/rs/zerolog/log"
+)
+
+// TokenRegistryWatcher keeps the token mappings of a validator DB in sync with the TokenRegistry, for
+// validators that run without an aggregator feeding them
+type TokenRegistryWatcher struct {
+	db            db.DB
+	tokenRegistry *mainchain.TokenRegistry
+	// Mainchain block to backfill registered tokens from on the first start
+	startBlock uint64
+}
+
+// NewTokenRegistryWatcher creates a TokenRegistryWatcher. startBlock is the block TokenRegistry was deployed at.
+func NewTokenRegistryWatcher(db db.DB, tokenRegistry *mainchain.TokenRegistry, startBlock uint64) *TokenRegistryWatcher {
+	return &TokenRegistryWatcher{
+		db:            db,
+		tokenRegistry: tokenRegistry,
+		startBlock:    startBlock,
+	}
+}
+
+// Start registers the tokens registered since the last start, and then watches for new ones. The backfill
+// finishes before Start returns, so that the blocks validated afterwards find their tokens.
+func (w *TokenRegistryWatcher) Start() error {
+	filter, err := newEventFilter(w.db, eventPositionTokenRegistered)
+	if err != nil {
+		return err
+	}
+	// Subscribe before the backfill, so that no token is registered in between
+	channel := make(chan *mainchain.TokenRegistryTokenRegistered)
+	sub, err := w.tokenRegistry.WatchTokenRegistered(&bind.WatchOpts{}, channel, nil, nil)
+	if err != nil {
+		return err
+	}
+	it, err := w.tokenRegistry.FilterTokenRegistered(
+		&bind.FilterOpts{Start: filter.startBlock(w.startBlock)}, nil, nil)
+	if err != nil {
+		sub.Unsubscribe()
+		return err
+	}
+	for it.Next() {
+		err = w.registerToken(filter, it.Event)
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = it.Error()
+	}
+	it.Close()
+	if err != nil {
+		sub.Unsubscribe()
+		return err
+	}
+	go w.watchTokenRegistry(filter, channel, sub)
+	return nil
+}
+
+func (w *TokenRegistryWatcher) watchTokenRegistry(
+	filter *eventFilter, channel chan *mainchain.TokenRegistryTokenRegistered, sub event.Subscription) {
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case event := <-channel:
+			err := w.registerToken(filter, event)
+			if err != nil {
+				log.Err(err).Send()
+			}
+		case err := <-sub.Err():
+			log.Err(err).Send()
+			return
+		}
+	}
+}
+
+func (w *TokenRegistryWatcher) registerToken(filter *eventFilter, event *mainchain.TokenRegistryTokenRegistered) error {
+	if !filter.next(&event.Raw) {
+		return nil
+	}
+	log.Info().
+		Str("token", event.TokenAddress.Hex()).
+		Str("tokenIndex", event.TokenIndex.String()).
+		Msg("Registered token")
+	tx := w.db.NewTx()
+	err := tx.Set(db.NamespaceTokenAddressToTokenIndex, event.TokenAddress.Bytes(), event.TokenIndex.Bytes())
+	if err != nil {
+		tx.Discard()
+		return err
+	}
+	err = tx.Set(db.NamespaceTokenIndexToTokenAddress, event.TokenIndex.Bytes(), event.TokenAddress.Bytes())
+	if err != nil {
+		tx.Discard()
+		return err
+	}
+	position := newEventPosition(&event.Raw)
+	err = tx.Set(db.NamespaceEventPosition, eventPositionTokenRegistered, encodeEventPosition(position))
+	if err != nil {
+		tx.Discard()
+		return err
+	}
+	return tx.Commit()
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/rs/zerolog/log"
@@ -19,59 +20,136 @@ import (
 )
 
 type Validator struct {
-	db              db.DB
-	serializer      *types.Serializer
-	stateMachine    *statemachine.StateMachine
-	mainchainClient *ethclient.Client
-	mainchainAuth   *bind.TransactOpts
-	rollupChain     *mainchain.RollupChain
+	db                 db.DB
+	serializer         *types.Serializer
+	stateMachine       *statemachine.StateMachine
+	mainchainClient    *ethclient.Client
+	mainchainAuth      *bind.TransactOpts
+	rollupChainAddress common.Address
+	rollupChain        *mainchain.RollupChain
+	fraudProofStore    *FraudProofStore
+	fraudReporters     []FraudReporter
+	// New fraud proofs for the submission goroutine
+	fraudProofSubmissions chan *fraudProofSubmission
+	// Fraud reports for the reporting goroutine, so that slow reporters do not delay submissions
+	fraudReports chan *FraudReport
+
+	blockQueue    *blockQueue
+	decodedBlocks chan *decodedBlock
+	// Number of queued blocks that are not fully validated yet
+	backlog int32
+	// Mainchain block to backfill committed blocks from on the first start
+	startBlock uint64
+	// Set for stateless validation
+	witnessSource WitnessSource
+	// State machine of the block being validated, which is built from its witness for stateless validation
+	blockStateMachine *statemachine.StateMachine
+	// Snapshots of the block being validated, read ahead of its transitions
+	prefetchedSnapshots map[string]*types.StateSnapshot
 }
 
+// NewValidator creates a Validator. mainchainAuth can be nil to only detect and record frauds without
+// submitting fraud proofs, which needs no funded account.
 func NewValidator(
 	db db.DB,
 	serializer *types.Serializer,
 	stateMachine *statemachine.StateMachine,
 	mainchainClient *ethclient.Client,
 	mainchainAuth *bind.TransactOpts,
+	rollupChainAddress common.Address,
 	rollupChain *mainchain.RollupChain,
 ) *Validator {
 	return &Validator{
-		db:              db,
-		serializer:      serializer,
-		stateMachine:    stateMachine,
-		mainchainClient: mainchainClient,
-		mainchainAuth:   mainchainAuth,
-		rollupChain:     rollupChain,
+		db:                    db,
+		serializer:            serializer,
+		stateMachine:          stateMachine,
+		mainchainClient:       mainchainClient,
+		mainchainAuth:         mainchainAuth,
+		rollupChainAddress:    rollupChainAddress,
+		rollupChain:           rollupChain,
+		fraudProofStore:       NewFraudProofStore(db),
+		fraudProofSubmissions: make(chan *fraudProofSubmission, fraudProofQueueSize),
+		fraudReports:          make(chan *FraudReport, fraudReportQueueSize),
+		blockQueue:            newBlockQueue(),
+		decodedBlocks:         make(chan *decodedBlock, decodedBlockBufferSize),
 	}
 }
 
+// SetStartBlock sets the mainchain block to backfill committed blocks from on the first start, which is the
+// block RollupChain was deployed at. Later starts resume after the last validated block.
+func (v *Validator) SetStartBlock(startBlock uint64) {
+	v.startBlock = startBlock
+}
+
 func (v *Validator) Start() {
+	go v.submitFraudProofs()
+	go v.sendFraudReports()
+	if v.witnessSource == nil {
+		v.stateMachine.StartPruning()
+	}
+	go v.decodeBlocks()
+	go v.validateBlocks()
+	go v.logBacklog()
 	go v.watchRollupBlockCommitted()
 }
 
+// watchRollupBlockCommitted queues the blocks committed since the last validated one, and then the newly
+// committed blocks
 func (v *Validator) watchRollupBlockCommitted() error {
+	filter, err := newEventFilter(v.db, eventPositionRollupBlockCommitted)
+	if err != nil {
+		log.Err(err).Send()
+		return err
+	}
+	// Subscribe before the backfill, so that no block is committed in between
 	channel := make(chan *mainchain.RollupChainRollupBlockCommitted)
 	sub, err := v.rollupChain.WatchRollupBlockCommitted(&bind.WatchOpts{}, channel)
 	if err != nil {
+		log.Err(err).Send()
+		return err
+	}
+	defer sub.Unsubscribe()
+	startBlock := filter.startBlock(v.startBlock)
+	log.Info().Uint64("startBlock", startBlock).Msg("Backfilling committed blocks")
+	it, err := v.rollupChain.FilterRollupBlockCommitted(&bind.FilterOpts{Start: startBlock})
+	if err != nil {
+		log.Err(err).Send()
+		return err
+	}
+	for it.Next() {
+		v.queueCommittedBlock(filter, it.Event)
+	}
+	err = it.Error()
+	it.Close()
+	if err != nil {
+		log.Err(err).Send()
 		return err
 	}
 	for {
 		select {
 		case event := <-channel:
 			log.Debug().Msg("Caught RollupBlock")
-			rollupBlock, err := v.serializer.DeserializeRollupBlockFromFields(event.BlockNumber.Uint64(), event.Transitions)
-			if err != nil {
-				log.Err(err).Msg("Failed to deserialize block")
-				return err
-			}
-			v.validateBlock(rollupBlock)
+			v.queueCommittedBlock(filter, event)
 		case err := <-sub.Err():
+			log.Err(err).Send()
 			return err
 		}
 	}
 }
 
-func (v *Validator) validateBlock(block *types.RollupBlock) {
+func (v *Validator) queueCommittedBlock(filter *eventFilter, event *mainchain.RollupChainRollupBlockCommitted) {
+	if !filter.next(&event.Raw) {
+		return
+	}
+	v.queueBlock(&committedBlock{
+		blockNumber: event.BlockNumber.Uint64(),
+		transitions: event.Transitions,
+		position:    newEventPosition(&event.Raw),
+	})
+}
+
+func (v *Validator) validateBlock(decoded *decodedBlock) {
+	block := decoded.block
 	// TODO: Validate block number
 	_, serializedBlock, err := block.Serialize(v.serializer)
 	if err != nil {
@@ -82,6 +160,33 @@ func (v *Validator) validateBlock(block *types.RollupBlock) {
 		log.Err(err).Send()
 	}
 
+	if v.witnessSource == nil {
+		checkpoint, exists, err := v.stateMachine.GetStateCheckpoint()
+		if err != nil {
+			log.Err(err).Msg("Failed to get state checkpoint")
+			return
+		}
+		if exists && block.BlockNumber < checkpoint.NextBlockNumber {
+			// The state resumed from a checkpoint after the block
+			log.Debug().Uint64("blockNumber", block.BlockNumber).Msg("Block already applied")
+			return
+		}
+	}
+
+	// The fraud proofs are built at the state roots within the block, which pruning would delete
+	resumePruning := v.stateMachine.PausePruning()
+	defer resumePruning()
+	v.blockStateMachine, err = v.newBlockStateMachine(block)
+	if err != nil {
+		log.Err(err).Uint64("blockNumber", block.BlockNumber).Msg("Failed to get state machine for block")
+		return
+	}
+	v.prefetchedSnapshots = v.prefetchStateSnapshots(block)
+	defer func() {
+		v.blockStateMachine = nil
+		v.prefetchedSnapshots = nil
+	}()
+	fraudulent := false
 	for i, transition := range block.Transitions {
 		transitionPosition := &types.TransitionPosition{
 			BlockNumber:     block.BlockNumber,
@@ -91,110 +196,182 @@ func (v *Validator) validateBlock(block *types.RollupBlock) {
 		if err != nil {
 			log.Err(err).Msg("Failed to validate transaction")
 		}
+		// The transition may have changed the accounts in its slots
+		for _, slotIndex := range types.GetInputSlotIndices(transition) {
+			delete(v.prefetchedSnapshots, string(slotIndex.Bytes()))
+		}
 		log.Debug().Msg("Validated transaction")
 		if fraudProof != nil {
 			log.Debug().Msg("Generating and submitting fraud proof")
-			contractFraudProof, err := v.generateContractFraudProof(block, fraudProof)
-			if err != nil {
-				log.Err(err).Send()
-				return
-			}
-			err = v.submitContractFraudProof(contractFraudProof)
-			if err != nil {
-				log.Err(err).Send()
-			}
+			v.handleFraudProof(decoded.info, fraudProof)
+			fraudulent = true
+			// The block gets pruned as a whole, so only its first fraud needs to be proven
+			break
 		}
 	}
+	if v.witnessSource != nil {
+		return
+	}
+	if fraudulent {
+		// The block gets pruned, so drop the transitions applied before the fraud, back to the checkpoint before
+		// the block
+		_, err = v.stateMachine.RevertToCheckpoint()
+		if err != nil {
+			log.Err(err).Uint64("blockNumber", block.BlockNumber).Msg("Failed to revert fraudulent block")
+		}
+		return
+	}
+	err = v.stateMachine.RetainStateRoot(block.BlockNumber + 1)
+	if err != nil {
+		log.Err(err).Msg("Failed to retain state root")
+	}
 }
 
 func (v *Validator) validateTransition(
 	transitionPosition *types.TransitionPosition, transition types.Transition) (*types.LocalFraudProof, error) {
+	if transition.GetTransitionType() == types.TransitionTypeInvalid {
+		log.Error().
+			Uint64("blockNumber", transitionPosition.BlockNumber).
+			Uint64("transitionIndex", transitionPosition.TransitionIndex).
+			Str("reason", transition.(*types.InvalidTransition).Reason).
+			Msg("Invalid transition encoding")
+		// No inputs are needed: proveTransitionInvalid decodes the transition before it verifies any storage
+		// slots, and prunes the block as soon as the decoding fails
+		return &types.LocalFraudProof{
+			Position:   transitionPosition,
+			Inputs:     nil,
+			Transition: transition,
+			Reason:     "Invalid transition encoding",
+		}, nil
+	}
+	preStateRoot := v.blockStateMachine.GetStateRoot()
 	snapshots, err := v.getInputStateSnapshots(transition)
 	if err != nil {
 		return nil, err
 	}
+	createSlotIndex, reason, err := v.checkCreateSlot(transitionPosition, transition, snapshots)
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		log.Error().
+			Uint64("blockNumber", transitionPosition.BlockNumber).
+			Uint64("transitionIndex", transitionPosition.TransitionIndex).
+			Msg(reason)
+		return v.newLocalFraudProof(transitionPosition, transition, snapshots, preStateRoot, reason)
+	}
 	tx, err := v.getTransactionFromTransitionAndSnapshots(transition, snapshots)
 	if err != nil {
 		return nil, err
 	}
-	_, err = v.stateMachine.ApplyTransaction(tx)
+	_, err = v.blockStateMachine.ApplyTransactionWithSlot(tx, createSlotIndex)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to apply transaction")
-		return &types.LocalFraudProof{
-			Position:   transitionPosition,
-			Inputs:     snapshots,
-			Transition: transition,
-		}, nil
 		// TODO: Differentiate between state transition error and other errors
+		return v.newLocalFraudProof(transitionPosition, transition, snapshots, preStateRoot, err.Error())
 	}
-	localPostRoot := v.stateMachine.GetStateRoot()
+	localPostRoot := v.blockStateMachine.GetStateRoot()
 	transitionPostRoot := transition.GetStateRoot()
 	if !bytes.Equal(localPostRoot, transitionPostRoot[:]) {
 		log.Error().
 			Str("localPostRoot", common.Bytes2Hex(localPostRoot)).
 			Str("transitionPostRoot", common.Bytes2Hex(transitionPostRoot[:])).
 			Msg("State root mismatch")
-		return &types.LocalFraudProof{
-			Position:   transitionPosition,
-			Inputs:     snapshots,
-			Transition: transition,
-		}, nil
-
+		return v.newLocalFraudProof(transitionPosition, transition, snapshots, preStateRoot, "State root mismatch")
 	}
 	return nil, nil
 }
 
-func (v *Validator) getInputStateSnapshots(transition types.Transition) ([]*types.StateSnapshot, error) {
-	switch transition.GetTransitionType() {
-	case types.TransitionTypeCreateAndDeposit:
-		// No StateSnapshot for newly created account
-		return nil, nil
-	case types.TransitionTypeDeposit:
-		depositTransition := transition.(*types.DepositTransition)
-		snapshot, err := v.stateMachine.GetStateSnapshot(depositTransition.AccountSlotIndex.Bytes())
-		if err != nil {
-			return nil, err
-		}
-		return []*types.StateSnapshot{
-			snapshot,
-		}, nil
-	case types.TransitionTypeWithdraw:
-		withdrawTransition := transition.(*types.WithdrawTransition)
-		snapshot, err := v.stateMachine.GetStateSnapshot(withdrawTransition.AccountSlotIndex.Bytes())
+// newLocalFraudProof creates the fraud proof of a transition. The contract verifies the inputs against the
+// post state root of the previous transition, so inputs read at another root, like the prefetched ones at the
+// state root before the block, are proven again at preStateRoot.
+func (v *Validator) newLocalFraudProof(
+	transitionPosition *types.TransitionPosition,
+	transition types.Transition,
+	snapshots []*types.StateSnapshot,
+	preStateRoot []byte,
+	reason string,
+) (*types.LocalFraudProof, error) {
+	inputs := make([]*types.StateSnapshot, len(snapshots))
+	for i, snapshot := range snapshots {
+		if bytes.Equal(snapshot.StateRoot, preStateRoot) {
+			inputs[i] = snapshot
+			continue
+		}
+		input, err := v.blockStateMachine.GetStateSnapshotForRoot(snapshot.SlotIndex.Bytes(), preStateRoot)
 		if err != nil {
 			return nil, err
 		}
-		return []*types.StateSnapshot{
-			snapshot,
-		}, nil
+		inputs[i] = input
+	}
+	return &types.LocalFraudProof{
+		Position:   transitionPosition,
+		Inputs:     inputs,
+		Transition: transition,
+		Reason:     reason,
+	}, nil
+}
+
+// checkCreateSlot returns the slot a create transition allocates, or nil for other transitions. The contract
+// only requires that slot to be empty, so an occupied slot is a fraud, whose reason is returned, while a slot
+// other than the next one is applied as is.
+func (v *Validator) checkCreateSlot(
+	transitionPosition *types.TransitionPosition,
+	transition types.Transition,
+	snapshots []*types.StateSnapshot,
+) (*big.Int, string, error) {
+	var createSnapshot *types.StateSnapshot
+	switch transition.GetTransitionType() {
+	case types.TransitionTypeCreateAndDeposit:
+		createSnapshot = snapshots[0]
 	case types.TransitionTypeCreateAndTransfer:
-		createAndTransferTransition := transition.(*types.CreateAndTransferTransition)
-		log.Debug().Uint64("nonce", createAndTransferTransition.Nonce.Uint64()).Msg("getInputStateSnapshots createAndTransfer")
-		senderSnapshot, err := v.stateMachine.GetStateSnapshot(createAndTransferTransition.SenderSlotIndex.Bytes())
-		if err != nil {
-			return nil, err
-		}
-		return []*types.StateSnapshot{
-			senderSnapshot,
-			nil, // No StateSnapshot for newly created account
-		}, err
-	case types.TransitionTypeTransfer:
-		transferTransition := transition.(*types.TransferTransition)
-		log.Debug().Uint64("nonce", transferTransition.Nonce.Uint64()).Msg("getInputStateSnapshots transfer")
-		senderSnapshot, err := v.stateMachine.GetStateSnapshot(transferTransition.SenderSlotIndex.Bytes())
-		if err != nil {
-			return nil, err
-		}
-		recipientSnapshot, err := v.stateMachine.GetStateSnapshot(transferTransition.RecipientSlotIndex.Bytes())
+		createSnapshot = snapshots[1]
+	default:
+		return nil, "", nil
+	}
+	// The contract rejects a create on an occupied slot, so the snapshot of that slot proves the fraud
+	if !createSnapshot.AccountInfo.IsEmpty() {
+		return nil, fmt.Sprintf(
+			"Create slot %d is occupied by %s",
+			createSnapshot.SlotIndex.Uint64(),
+			createSnapshot.AccountInfo.Account.Hex()), nil
+	}
+	nextSlotIndex, err := v.blockStateMachine.GetNextAccountSlotIndex()
+	if err != nil {
+		return nil, "", err
+	}
+	if createSnapshot.SlotIndex.Cmp(nextSlotIndex) != 0 {
+		log.Warn().
+			Uint64("blockNumber", transitionPosition.BlockNumber).
+			Uint64("transitionIndex", transitionPosition.TransitionIndex).
+			Uint64("slotIndex", createSnapshot.SlotIndex.Uint64()).
+			Uint64("nextSlotIndex", nextSlotIndex.Uint64()).
+			Msg("Create slot does not follow the allocation rule")
+	}
+	return createSnapshot.SlotIndex, "", nil
+}
+
+func (v *Validator) getInputStateSnapshots(transition types.Transition) ([]*types.StateSnapshot, error) {
+	slotIndices := types.GetInputSlotIndices(transition)
+	if slotIndices == nil {
+		return nil, errors.New("Invalid transition type")
+	}
+	snapshots := make([]*types.StateSnapshot, len(slotIndices))
+	for i, slotIndex := range slotIndices {
+		snapshot, err := v.getStateSnapshot(slotIndex)
 		if err != nil {
 			return nil, err
 		}
-		return []*types.StateSnapshot{
-			senderSnapshot,
-			recipientSnapshot,
-		}, nil
+		snapshots[i] = snapshot
 	}
-	return nil, errors.New("Invalid transition type")
+	return snapshots, nil
+}
+
+func (v *Validator) getStateSnapshot(slotIndex *big.Int) (*types.StateSnapshot, error) {
+	if snapshot, ok := v.prefetchedSnapshots[string(slotIndex.Bytes())]; ok {
+		return snapshot, nil
+	}
+	return v.blockStateMachine.GetStateSnapshot(slotIndex.Bytes())
 }
 
 func (v *Validator) getTransactionFromTransitionAndSnapshots(
@@ -304,7 +481,8 @@ func (v *Validator) getTransactionFromTransitionAndSnapshots(
 	return tx, nil
 }
 
-func (v *Validator) generateContractFraudProof(block *types.RollupBlock, localFraudProof *types.LocalFraudProof) (*types.ContractFraudProof, error) {
+func (v *Validator) generateContractFraudProof(
+	blockInfo *types.RollupBlockInfo, localFraudProof *types.LocalFraudProof) (*types.ContractFraudProof, error) {
 	fraudInputs := localFraudProof.Inputs
 	transitionStorageSlots := make([]mainchain.DataTypesIncludedStorageSlot, len(fraudInputs))
 	for i, input := range fraudInputs {
@@ -324,10 +502,6 @@ func (v *Validator) generateContractFraudProof(block *types.RollupBlock, localFr
 		}
 	}
 
-	blockInfo, err := types.NewRollupBlockInfo(v.serializer, block)
-	if err != nil {
-		return nil, err
-	}
 	position := localFraudProof.Position
 	blockNumber := position.BlockNumber
 	transitionIndex := position.TransitionIndex
@@ -372,64 +546,51 @@ func (v *Validator) generateContractFraudProof(block *types.RollupBlock, localFr
 	}, nil
 }
 
-func (v *Validator) submitContractFraudProof(proof *types.ContractFraudProof) error {
-	v.mainchainAuth.GasLimit = 8000000
-	v.mainchainAuth.GasPrice = big.NewInt(10e9) // 10 Gwei
-	committerAddress, err := v.rollupChain.CommitterAddress(&bind.CallOpts{})
+func (v *Validator) submitContractFraudProof(proof *types.ContractFraudProof) (common.Hash, error) {
+	if v.mainchainAuth != nil {
+		committerAddress, err := v.rollupChain.CommitterAddress(&bind.CallOpts{})
+		if err != nil {
+			return common.Hash{}, err
+		}
+		// Hack for now
+		if bytes.Equal(v.mainchainAuth.From.Bytes(), committerAddress.Bytes()) {
+			return common.Hash{}, errSubmitterIsCommitter
+		}
+	}
+	simulation, err := v.simulateContractFraudProof(proof)
 	if err != nil {
-		return err
+		return common.Hash{}, err
+	}
+	logFraudProofSimulation(proof, simulation)
+	if !simulation.success {
+		return common.Hash{}, fmt.Errorf("%w: %s", errFraudProofWouldFail, simulation.reason)
+	}
+	if v.mainchainAuth == nil {
+		return common.Hash{}, errSubmissionDisabled
 	}
-	// Hack for now
-	if bytes.Equal(v.mainchainAuth.From.Bytes(), committerAddress.Bytes()) {
-		return nil
-	}
-	// transitionEvaluatorAddress := common.HexToAddress(viper.GetString("transitionEvaluator"))
-	// transitionEvaluator, err := mainchain.NewTransitionEvaluator(transitionEvaluatorAddress, v.mainchainClient)
-	// log.Debug().Str("invalidIncludedTransition", common.Bytes2Hex(proof.InvalidIncludedTransition.Transition)).Send()
-	// stateRoot, slots, err := transitionEvaluator.GetTransitionStateRootAndAccessList(&bind.CallOpts{}, proof.InvalidIncludedTransition.Transition)
-	// if err != nil {
-	// 	log.Error().Err(err).Msg("Failed to GetTransitionStateRootAndAccessList")
-	// }
-	// log.Debug().Str("stateRoot", common.Bytes2Hex(stateRoot[:])).Send()
-	// log.Debug().Interface("slots", slots).Uint64("storageSlot0", slots[0].Uint64()).Send()
-	// tx, err := v.rollupChain.GetStateRootsAndStorageSlots(
-	// 	v.mainchainAuth,
-	// 	proof.PreStateIncludedTransition.Transition,
-	// 	proof.InvalidIncludedTransition.Transition,
-	// )
-	// storageSlots := make([]mainchain.DataTypesStorageSlot, len(proof.TransitionStorageSlots))
-	// for i, includedSlot := range proof.TransitionStorageSlots {
-	// 	storageSlots[i] = includedSlot.StorageSlot
-	// 	log.Debug().Int("index", i).Uint64("balance0", storageSlots[i].Value.Balances[0].Uint64()).Send()
-	// }
-	// tx, err := transitionEvaluator.EvaluateTransition(
-	// 	v.mainchainAuth,
-	// 	proof.InvalidIncludedTransition.Transition,
-	// 	storageSlots,
-	// )
-	// if err != nil {
-	// 	log.Error().Err(err).Msg("Failed to evaluate transition")
-	// 	return err
-	// }
 
+	// Copy the options, which are shared with other users of the account
+	auth := *v.mainchainAuth
+	auth.GasLimit = fraudProofGasLimit
+	auth.GasPrice = big.NewInt(fraudProofGasPrice)
 	tx, err := v.rollupChain.ProveTransitionInvalid(
-		v.mainchainAuth,
+		&auth,
 		proof.PreStateIncludedTransition,
 		proof.InvalidIncludedTransition,
 		proof.TransitionStorageSlots,
 	)
 	if err != nil {
-		return err
+		return common.Hash{}, err
 	}
 	receipt, err := utils.WaitMined(context.Background(), v.mainchainClient, tx, 0)
 	if err != nil {
-		return err
+		return tx.Hash(), err
 	}
 	if receipt.Status != 1 {
 		log.Error().Str("tx", tx.Hash().Hex()).Msg("Failed to submit fraud proof")
-		return errors.New("Failed to submit fraud proof")
+		return tx.Hash(), errors.New("Failed to submit fraud proof")
 	}
 	log.Debug().Str("tx", tx.Hash().Hex()).Msg("Successfully submitted fraud proof")
 	log.Debug().Uint64("gasUsed", receipt.GasUsed).Send()
-	return nil
+	return tx.Hash(), nil
 }
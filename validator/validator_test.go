@@ -0,0 +1,396 @@
+package validator
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	rollupdb "github.com/celer-network/go-rollup/db"
+	"github.com/celer-network/go-rollup/db/memorydb"
+	"github.com/celer-network/go-rollup/statemachine"
+	"github.com/celer-network/go-rollup/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	testToken    = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	testAccounts = []common.Address{
+		common.HexToAddress("0x2000000000000000000000000000000000000001"),
+		common.HexToAddress("0x2000000000000000000000000000000000000002"),
+		common.HexToAddress("0x2000000000000000000000000000000000000003"),
+	}
+)
+
+func newTestDB(t *testing.T) rollupdb.DB {
+	db := memorydb.NewDB()
+	tokenIndex := big.NewInt(0).Bytes()
+	err := db.Set(rollupdb.NamespaceTokenAddressToTokenIndex, testToken.Bytes(), tokenIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Set(rollupdb.NamespaceTokenIndexToTokenAddress, tokenIndex, testToken.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// newTestValidator returns a validator without mainchain, and the state machine of a committer to build the
+// blocks with
+func newTestValidator(t *testing.T) (*Validator, *statemachine.StateMachine) {
+	serializer, err := types.NewSerializer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := newTestDB(t)
+	stateMachine, err := statemachine.NewStateMachine(db, serializer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	committer, err := statemachine.NewStateMachine(newTestDB(t), serializer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewValidator(db, serializer, stateMachine, nil, nil, common.Address{}, nil), committer
+}
+
+// newDepositTransitions applies a deposit of amount for each account on the committer and returns the
+// transitions, like the aggregator does
+func newDepositTransitions(
+	t *testing.T, committer *statemachine.StateMachine, accounts []common.Address, amount int64) []types.Transition {
+	transitions := make([]types.Transition, len(accounts))
+	for i, account := range accounts {
+		stateUpdate, err := committer.ApplyTransaction(
+			&types.DepositTransaction{Account: account, Token: testToken, Amount: big.NewInt(amount)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry := stateUpdate.Entries[0]
+		if entry.NewAccount {
+			transitions[i] = &types.CreateAndDepositTransition{
+				TransitionType:   big.NewInt(int64(types.TransitionTypeCreateAndDeposit)),
+				StateRoot:        stateUpdate.StateRoot,
+				AccountSlotIndex: entry.SlotIndex,
+				Account:          account,
+				TokenIndex:       big.NewInt(0),
+				Amount:           big.NewInt(amount),
+			}
+		} else {
+			transitions[i] = &types.DepositTransition{
+				TransitionType:   big.NewInt(int64(types.TransitionTypeDeposit)),
+				StateRoot:        stateUpdate.StateRoot,
+				AccountSlotIndex: entry.SlotIndex,
+				TokenIndex:       big.NewInt(0),
+				Amount:           big.NewInt(amount),
+			}
+		}
+	}
+	return transitions
+}
+
+func newCommittedBlock(t *testing.T, v *Validator, blockNumber uint64, transitions []types.Transition) *committedBlock {
+	serialized := make([][]byte, len(transitions))
+	for i, transition := range transitions {
+		data, err := transition.Serialize(v.serializer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		serialized[i] = data
+	}
+	return &committedBlock{blockNumber: blockNumber, transitions: serialized}
+}
+
+func validateTestBlock(t *testing.T, v *Validator, blockNumber uint64, transitions []types.Transition) {
+	decoded, err := v.decodeBlock(newCommittedBlock(t, v, blockNumber, transitions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.validateBlock(decoded)
+}
+
+// validateTestGenesis validates a first block that creates the test accounts, and returns its post state root
+func validateTestGenesis(t *testing.T, v *Validator, committer *statemachine.StateMachine) []byte {
+	validateTestBlock(t, v, 0, newDepositTransitions(t, committer, testAccounts, 100))
+	if !bytes.Equal(v.stateMachine.GetStateRoot(), committer.GetStateRoot()) {
+		t.Fatal("state root differs from the committer after the first block")
+	}
+	records, err := v.fraudProofStore.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("%d frauds in a valid block", len(records))
+	}
+	return committer.GetStateRoot()
+}
+
+func getTestFraudProof(
+	t *testing.T, v *Validator, blockNumber uint64, transitionIndex uint64) (*FraudProofRecord, *types.ContractFraudProof) {
+	record, exists, err := v.fraudProofStore.Get(blockNumber, transitionIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Fatalf("no fraud proof for transition %d of block %d", transitionIndex, blockNumber)
+	}
+	if record.Status != FraudProofStatusPending {
+		t.Fatalf("fraud proof status %s: %s", record.Status, record.Result)
+	}
+	proof, err := v.serializer.DeserializeContractFraudProof(record.ContractFraudProof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.fraudProofSubmissions) != 1 {
+		t.Errorf("%d fraud proofs queued for submission, expected 1", len(v.fraudProofSubmissions))
+	}
+	return record, proof
+}
+
+// checkFraudProofInputs checks that the inputs of a fraud proof are proven at the pre state root of the
+// fraudulent transition
+func checkFraudProofInputs(
+	t *testing.T,
+	record *FraudProofRecord,
+	proof *types.ContractFraudProof,
+	committer *statemachine.StateMachine,
+	preStateRoot []byte,
+) {
+	if len(record.Inputs) != 1 || len(proof.TransitionStorageSlots) != 1 {
+		t.Fatalf("%d inputs and %d storage slots, expected 1", len(record.Inputs), len(proof.TransitionStorageSlots))
+	}
+	input := record.Inputs[0]
+	if !bytes.Equal(input.StateRoot, preStateRoot) {
+		t.Errorf("input at state root %x, expected %x", []byte(input.StateRoot), preStateRoot)
+	}
+	expected, err := committer.GetStateSnapshotForRoot(input.SlotIndex.Bytes(), preStateRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slot := proof.TransitionStorageSlots[0]
+	if slot.StorageSlot.SlotIndex.Cmp(expected.SlotIndex) != 0 {
+		t.Errorf("storage slot %d, expected %d", slot.StorageSlot.SlotIndex.Uint64(), expected.SlotIndex.Uint64())
+	}
+	if slot.StorageSlot.Value.Balances[0].Cmp(expected.AccountInfo.Balances[0]) != 0 {
+		t.Errorf("storage slot balance %s, expected %s",
+			slot.StorageSlot.Value.Balances[0], expected.AccountInfo.Balances[0])
+	}
+	if len(slot.Siblings) != len(expected.InclusionProof) {
+		t.Fatalf("%d siblings, expected %d", len(slot.Siblings), len(expected.InclusionProof))
+	}
+	for i, sibling := range slot.Siblings {
+		if sibling != expected.InclusionProof[i] {
+			t.Fatalf("sibling %d is not at the pre state root", i)
+		}
+	}
+}
+
+func TestValidateBlock(t *testing.T) {
+	v, committer := newTestValidator(t)
+	validateTestGenesis(t, v, committer)
+	validateTestBlock(t, v, 1, newDepositTransitions(t, committer, testAccounts, 10))
+	if !bytes.Equal(v.stateMachine.GetStateRoot(), committer.GetStateRoot()) {
+		t.Error("state root differs from the committer")
+	}
+	roots, err := v.stateMachine.GetRetainedStateRoots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 2 || !bytes.Equal(roots[1], committer.GetStateRoot()) {
+		t.Errorf("%d retained state roots, expected the roots of both blocks", len(roots))
+	}
+}
+
+func TestFraudAfterFirstTransition(t *testing.T) {
+	v, committer := newTestValidator(t)
+	genesisRoot := validateTestGenesis(t, v, committer)
+
+	transitions := newDepositTransitions(t, committer, testAccounts, 10)
+	transitions[1].(*types.DepositTransition).StateRoot = [32]byte{1}
+	validateTestBlock(t, v, 1, transitions)
+
+	record, proof := getTestFraudProof(t, v, 1, 1)
+	if record.Reason != "State root mismatch" {
+		t.Errorf("fraud reason %q", record.Reason)
+	}
+	preStateRoot := transitions[0].GetStateRoot()
+	// The input is prefetched at the state root before the block, which the first transition changed
+	checkFraudProofInputs(t, record, proof, committer, preStateRoot[:])
+	preStateTransition, err := transitions[0].Serialize(v.serializer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(proof.PreStateIncludedTransition.Transition, preStateTransition) {
+		t.Error("pre state transition is not the previous transition of the block")
+	}
+	if proof.InvalidIncludedTransition.InclusionProof.TransitionIndex.Uint64() != 1 ||
+		proof.InvalidIncludedTransition.InclusionProof.BlockNumber.Uint64() != 1 {
+		t.Error("invalid transition is not at the fraud position")
+	}
+
+	// The fraudulent block is not applied
+	if !bytes.Equal(v.stateMachine.GetStateRoot(), genesisRoot) {
+		t.Error("state root is not reverted to the state root before the fraudulent block")
+	}
+}
+
+func TestFraudAtFirstTransition(t *testing.T) {
+	v, committer := newTestValidator(t)
+	genesisRoot := validateTestGenesis(t, v, committer)
+	genesisData, _, err := v.db.Get(rollupdb.NamespaceRollupBlockNumber, big.NewInt(0).Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis, err := v.serializer.DeserializeRollupBlockFromData(genesisData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transitions := newDepositTransitions(t, committer, testAccounts[1:], 10)
+	transitions[0].(*types.DepositTransition).Amount = big.NewInt(1000)
+	validateTestBlock(t, v, 1, transitions)
+
+	record, proof := getTestFraudProof(t, v, 1, 0)
+	checkFraudProofInputs(t, record, proof, committer, genesisRoot)
+	preStateTransition, err := genesis.Transitions[len(genesis.Transitions)-1].Serialize(v.serializer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(proof.PreStateIncludedTransition.Transition, preStateTransition) ||
+		proof.PreStateIncludedTransition.InclusionProof.BlockNumber.Uint64() != 0 {
+		t.Error("pre state transition is not the last transition of the previous block")
+	}
+	if !bytes.Equal(v.stateMachine.GetStateRoot(), genesisRoot) {
+		t.Error("state root is not reverted to the state root before the fraudulent block")
+	}
+}
+
+func TestFraudInvalidEncoding(t *testing.T) {
+	v, committer := newTestValidator(t)
+	genesisRoot := validateTestGenesis(t, v, committer)
+
+	committed := newCommittedBlock(t, v, 1, newDepositTransitions(t, committer, testAccounts, 10))
+	committed.transitions[2] = []byte{1, 2, 3}
+	decoded, err := v.decodeBlock(committed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.validateBlock(decoded)
+
+	record, proof := getTestFraudProof(t, v, 1, 2)
+	if record.Reason != "Invalid transition encoding" {
+		t.Errorf("fraud reason %q", record.Reason)
+	}
+	if len(proof.TransitionStorageSlots) != 0 {
+		t.Errorf("%d storage slots for an invalid encoding", len(proof.TransitionStorageSlots))
+	}
+	if !bytes.Equal(proof.InvalidIncludedTransition.Transition, committed.transitions[2]) {
+		t.Error("invalid transition is not the committed data")
+	}
+	if !bytes.Equal(v.stateMachine.GetStateRoot(), genesisRoot) {
+		t.Error("state root is not reverted to the state root before the fraudulent block")
+	}
+}
+
+// newCreateTransition applies a deposit of amount that creates account at slotIndex on the committer, and returns
+// the transition
+func newCreateTransition(
+	t *testing.T,
+	committer *statemachine.StateMachine,
+	account common.Address,
+	slotIndex int64,
+	amount int64,
+) types.Transition {
+	stateUpdate, err := committer.ApplyTransactionWithSlot(
+		&types.DepositTransaction{Account: account, Token: testToken, Amount: big.NewInt(amount)},
+		big.NewInt(slotIndex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &types.CreateAndDepositTransition{
+		TransitionType:   big.NewInt(int64(types.TransitionTypeCreateAndDeposit)),
+		StateRoot:        stateUpdate.StateRoot,
+		AccountSlotIndex: big.NewInt(slotIndex),
+		Account:          account,
+		TokenIndex:       big.NewInt(0),
+		Amount:           big.NewInt(amount),
+	}
+}
+
+func TestFraudOccupiedCreateSlot(t *testing.T) {
+	v, committer := newTestValidator(t)
+	genesisRoot := validateTestGenesis(t, v, committer)
+
+	account := common.HexToAddress("0x2000000000000000000000000000000000000004")
+	transitions := []types.Transition{&types.CreateAndDepositTransition{
+		TransitionType:   big.NewInt(int64(types.TransitionTypeCreateAndDeposit)),
+		StateRoot:        [32]byte{1},
+		AccountSlotIndex: big.NewInt(1),
+		Account:          account,
+		TokenIndex:       big.NewInt(0),
+		Amount:           big.NewInt(10),
+	}}
+	validateTestBlock(t, v, 1, transitions)
+
+	record, proof := getTestFraudProof(t, v, 1, 0)
+	if record.Reason != "Create slot 1 is occupied by "+testAccounts[1].Hex() {
+		t.Errorf("fraud reason %q", record.Reason)
+	}
+	checkFraudProofInputs(t, record, proof, committer, genesisRoot)
+	if !bytes.Equal(v.stateMachine.GetStateRoot(), genesisRoot) {
+		t.Error("state root is not reverted to the state root before the fraudulent block")
+	}
+}
+
+func TestOutOfOrderCreateSlot(t *testing.T) {
+	v, committer := newTestValidator(t)
+	validateTestGenesis(t, v, committer)
+
+	// The contract only requires a create slot to be empty, so a create past the next slot is valid
+	account := common.HexToAddress("0x2000000000000000000000000000000000000004")
+	validateTestBlock(t, v, 1, []types.Transition{newCreateTransition(t, committer, account, 5, 10)})
+	records, err := v.fraudProofStore.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("%d frauds for an out of order create slot", len(records))
+	}
+	if !bytes.Equal(v.stateMachine.GetStateRoot(), committer.GetStateRoot()) {
+		t.Error("state root differs from the committer")
+	}
+	blockRoot := committer.GetStateRoot()
+	nextSlotIndex, err := v.stateMachine.GetNextAccountSlotIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nextSlotIndex.Int64() != 6 {
+		t.Errorf("next slot %d, expected 6", nextSlotIndex.Int64())
+	}
+
+	// A fraudulent block after another out of order create reverts the next slot to the one before the block
+	other := common.HexToAddress("0x2000000000000000000000000000000000000005")
+	transitions := []types.Transition{
+		newCreateTransition(t, committer, other, 8, 10),
+		newDepositTransitions(t, committer, testAccounts[:1], 10)[0],
+	}
+	transitions[1].(*types.DepositTransition).StateRoot = [32]byte{1}
+	validateTestBlock(t, v, 2, transitions)
+	getTestFraudProof(t, v, 2, 1)
+	if !bytes.Equal(v.stateMachine.GetStateRoot(), blockRoot) {
+		t.Error("state root is not reverted to the state root before the fraudulent block")
+	}
+	nextSlotIndex, err = v.stateMachine.GetNextAccountSlotIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nextSlotIndex.Int64() != 6 {
+		t.Errorf("next slot %d after the revert, expected 6", nextSlotIndex.Int64())
+	}
+	snapshot, err := v.stateMachine.GetStateSnapshot(big.NewInt(8).Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !snapshot.AccountInfo.IsEmpty() {
+		t.Error("account created by the fraudulent block is not reverted")
+	}
+}
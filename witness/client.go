@@ -0,0 +1,48 @@
+package witness
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/celer-network/go-rollup/types"
+)
+
+const requestTimeout = 30 * time.Second
+
+// Client fetches block witnesses from a witness server. It is a validator.WitnessSource.
+type Client struct {
+	conn       *grpc.ClientConn
+	rpcClient  WitnessRpcClient
+	serializer *types.Serializer
+}
+
+func NewClient(endpoint string, serializer *types.Serializer) (*Client, error) {
+	conn, err := grpc.Dial(endpoint, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		conn:       conn,
+		rpcClient:  NewWitnessRpcClient(conn),
+		serializer: serializer,
+	}, nil
+}
+
+func (c *Client) GetBlockWitness(blockNumber uint64) (*types.BlockWitness, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	response, err := c.rpcClient.GetBlockWitness(ctx, &GetBlockWitnessRequest{BlockNumber: blockNumber})
+	if err != nil {
+		return nil, err
+	}
+	if response.Witness == nil {
+		return nil, errWitnessNotFound
+	}
+	return DecodeBlockWitness(c.serializer, response.Witness)
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,128 @@
+package witness
+
+import (
+	"math/big"
+
+	"github.com/celer-network/go-rollup/types"
+)
+
+// EncodeBlockWitness converts a block witness to its wire format
+func EncodeBlockWitness(serializer *types.Serializer, witness *types.BlockWitness) (*BlockWitness, error) {
+	snapshots, err := encodeStateSnapshots(serializer, witness.Snapshots)
+	if err != nil {
+		return nil, err
+	}
+	transitions := make([]*TransitionWitness, len(witness.Transitions))
+	for i, transition := range witness.Transitions {
+		inputs, err := encodeStateSnapshots(serializer, transition.Inputs)
+		if err != nil {
+			return nil, err
+		}
+		transitions[i] = &TransitionWitness{
+			Inputs:         inputs,
+			InclusionProof: encodeInclusionProof(transition.InclusionProof),
+		}
+	}
+	return &BlockWitness{
+		BlockNumber:   witness.BlockNumber,
+		StateRoot:     witness.StateRoot,
+		NextSlotIndex: witness.NextSlotIndex.Bytes(),
+		Snapshots:     snapshots,
+		Transitions:   transitions,
+	}, nil
+}
+
+// DecodeBlockWitness converts a block witness from its wire format
+func DecodeBlockWitness(serializer *types.Serializer, witness *BlockWitness) (*types.BlockWitness, error) {
+	snapshots, err := decodeStateSnapshots(serializer, witness.Snapshots)
+	if err != nil {
+		return nil, err
+	}
+	var transitions []*types.TransitionWitness
+	for _, transition := range witness.Transitions {
+		inputs, err := decodeStateSnapshots(serializer, transition.Inputs)
+		if err != nil {
+			return nil, err
+		}
+		inclusionProof, err := decodeInclusionProof(transition.InclusionProof)
+		if err != nil {
+			return nil, err
+		}
+		transitions = append(transitions, &types.TransitionWitness{
+			Inputs:         inputs,
+			InclusionProof: inclusionProof,
+		})
+	}
+	return &types.BlockWitness{
+		BlockNumber:   witness.BlockNumber,
+		StateRoot:     witness.StateRoot,
+		NextSlotIndex: new(big.Int).SetBytes(witness.NextSlotIndex),
+		Snapshots:     snapshots,
+		Transitions:   transitions,
+	}, nil
+}
+
+func encodeStateSnapshots(serializer *types.Serializer, snapshots []*types.StateSnapshot) ([]*StateSnapshot, error) {
+	encoded := make([]*StateSnapshot, len(snapshots))
+	for i, snapshot := range snapshots {
+		var accountInfo []byte
+		if !snapshot.AccountInfo.IsEmpty() {
+			var err error
+			accountInfo, err = snapshot.AccountInfo.Serialize(serializer)
+			if err != nil {
+				return nil, err
+			}
+		}
+		encoded[i] = &StateSnapshot{
+			SlotIndex:      snapshot.SlotIndex.Bytes(),
+			AccountInfo:    accountInfo,
+			StateRoot:      snapshot.StateRoot,
+			InclusionProof: encodeInclusionProof(snapshot.InclusionProof),
+		}
+	}
+	return encoded, nil
+}
+
+func decodeStateSnapshots(serializer *types.Serializer, snapshots []*StateSnapshot) ([]*types.StateSnapshot, error) {
+	decoded := make([]*types.StateSnapshot, len(snapshots))
+	for i, snapshot := range snapshots {
+		accountInfo := types.NewEmptyAccountInfo()
+		if len(snapshot.AccountInfo) > 0 {
+			var err error
+			accountInfo, err = serializer.DeserializeAccountInfo(snapshot.AccountInfo)
+			if err != nil {
+				return nil, err
+			}
+		}
+		inclusionProof, err := decodeInclusionProof(snapshot.InclusionProof)
+		if err != nil {
+			return nil, err
+		}
+		decoded[i] = &types.StateSnapshot{
+			SlotIndex:      new(big.Int).SetBytes(snapshot.SlotIndex),
+			AccountInfo:    accountInfo,
+			StateRoot:      snapshot.StateRoot,
+			InclusionProof: inclusionProof,
+		}
+	}
+	return decoded, nil
+}
+
+func encodeInclusionProof(proof types.InclusionProof) [][]byte {
+	encoded := make([][]byte, len(proof))
+	for i := range proof {
+		encoded[i] = append([]byte{}, proof[i][:]...)
+	}
+	return encoded
+}
+
+func decodeInclusionProof(proof [][]byte) (types.InclusionProof, error) {
+	decoded := make(types.InclusionProof, len(proof))
+	for i, sibling := range proof {
+		if len(sibling) != len(decoded[i]) {
+			return nil, errBadSibling
+		}
+		copy(decoded[i][:], sibling)
+	}
+	return decoded, nil
+}
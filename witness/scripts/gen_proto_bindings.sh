@@ -0,0 +1,2 @@
+#!/bin/sh
+protoc -Iproto --go_out=plugins=grpc:. proto/witness.proto --go_opt=paths=source_relative
@@ -0,0 +1,50 @@
+package witness
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server serves the witnesses of a Store over gRPC
+type Server struct {
+	grpcPort int
+	store    *Store
+}
+
+func NewServer(grpcPort int, store *Store) *Server {
+	return &Server{grpcPort: grpcPort, store: store}
+}
+
+func (s *Server) Start() {
+	grpcServer := grpc.NewServer()
+	RegisterWitnessRpcServer(grpcServer, s)
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.grpcPort))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to listen")
+	}
+	log.Info().Int("grpcPort", s.grpcPort).Msg("Serving witness gRPC")
+	go func() {
+		err := grpcServer.Serve(lis)
+		if err != nil {
+			log.Err(err).Msg("Witness gRPC server stopped")
+		}
+	}()
+}
+
+func (s *Server) GetBlockWitness(
+	ctx context.Context, request *GetBlockWitnessRequest) (*GetBlockWitnessResponse, error) {
+	witness, exists, err := s.store.getEncoded(request.BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, status.Error(codes.NotFound, errWitnessNotFound.Error())
+	}
+	return &GetBlockWitnessResponse{Witness: witness}, nil
+}
@@ -0,0 +1,67 @@
+package witness
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/golang/protobuf/proto"
+
+	rollupdb "github.com/celer-network/go-rollup/db"
+	"github.com/celer-network/go-rollup/types"
+)
+
+var (
+	errBadSibling      = errors.New("Bad inclusion proof sibling size")
+	errWitnessNotFound = errors.New("Block witness not found")
+)
+
+// Store persists block witnesses in their wire format, keyed by block number like the blocks
+type Store struct {
+	db         rollupdb.DB
+	serializer *types.Serializer
+}
+
+func NewStore(db rollupdb.DB, serializer *types.Serializer) *Store {
+	return &Store{db: db, serializer: serializer}
+}
+
+func witnessKey(blockNumber uint64) []byte {
+	return new(big.Int).SetUint64(blockNumber).Bytes()
+}
+
+func (s *Store) Put(witness *types.BlockWitness) error {
+	encoded, err := EncodeBlockWitness(s.serializer, witness)
+	if err != nil {
+		return err
+	}
+	data, err := proto.Marshal(encoded)
+	if err != nil {
+		return err
+	}
+	return s.db.Set(rollupdb.NamespaceBlockWitness, witnessKey(witness.BlockNumber), data)
+}
+
+func (s *Store) Get(blockNumber uint64) (*types.BlockWitness, bool, error) {
+	encoded, exists, err := s.getEncoded(blockNumber)
+	if err != nil || !exists {
+		return nil, false, err
+	}
+	witness, err := DecodeBlockWitness(s.serializer, encoded)
+	if err != nil {
+		return nil, false, err
+	}
+	return witness, true, nil
+}
+
+func (s *Store) getEncoded(blockNumber uint64) (*BlockWitness, bool, error) {
+	data, exists, err := s.db.Get(rollupdb.NamespaceBlockWitness, witnessKey(blockNumber))
+	if err != nil || !exists {
+		return nil, false, err
+	}
+	var encoded BlockWitness
+	err = proto.Unmarshal(data, &encoded)
+	if err != nil {
+		return nil, false, err
+	}
+	return &encoded, true, nil
+}
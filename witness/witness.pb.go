@@ -0,0 +1,402 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: witness.proto
+
+package witness
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type StateSnapshot struct {
+	SlotIndex            []byte   `protobuf:"bytes,1,opt,name=slotIndex,proto3" json:"slotIndex,omitempty"`
+	AccountInfo          []byte   `protobuf:"bytes,2,opt,name=accountInfo,proto3" json:"accountInfo,omitempty"`
+	StateRoot            []byte   `protobuf:"bytes,3,opt,name=stateRoot,proto3" json:"stateRoot,omitempty"`
+	InclusionProof       [][]byte `protobuf:"bytes,4,rep,name=inclusionProof,proto3" json:"inclusionProof,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StateSnapshot) Reset()         { *m = StateSnapshot{} }
+func (m *StateSnapshot) String() string { return proto.CompactTextString(m) }
+func (*StateSnapshot) ProtoMessage()    {}
+func (*StateSnapshot) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6a1e9059dbd106eb, []int{0}
+}
+
+func (m *StateSnapshot) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StateSnapshot.Unmarshal(m, b)
+}
+func (m *StateSnapshot) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StateSnapshot.Marshal(b, m, deterministic)
+}
+func (m *StateSnapshot) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StateSnapshot.Merge(m, src)
+}
+func (m *StateSnapshot) XXX_Size() int {
+	return xxx_messageInfo_StateSnapshot.Size(m)
+}
+func (m *StateSnapshot) XXX_DiscardUnknown() {
+	xxx_messageInfo_StateSnapshot.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StateSnapshot proto.InternalMessageInfo
+
+func (m *StateSnapshot) GetSlotIndex() []byte {
+	if m != nil {
+		return m.SlotIndex
+	}
+	return nil
+}
+
+func (m *StateSnapshot) GetAccountInfo() []byte {
+	if m != nil {
+		return m.AccountInfo
+	}
+	return nil
+}
+
+func (m *StateSnapshot) GetStateRoot() []byte {
+	if m != nil {
+		return m.StateRoot
+	}
+	return nil
+}
+
+func (m *StateSnapshot) GetInclusionProof() [][]byte {
+	if m != nil {
+		return m.InclusionProof
+	}
+	return nil
+}
+
+type TransitionWitness struct {
+	Inputs               []*StateSnapshot `protobuf:"bytes,1,rep,name=inputs,proto3" json:"inputs,omitempty"`
+	InclusionProof       [][]byte         `protobuf:"bytes,2,rep,name=inclusionProof,proto3" json:"inclusionProof,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
+}
+
+func (m *TransitionWitness) Reset()         { *m = TransitionWitness{} }
+func (m *TransitionWitness) String() string { return proto.CompactTextString(m) }
+func (*TransitionWitness) ProtoMessage()    {}
+func (*TransitionWitness) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6a1e9059dbd106eb, []int{1}
+}
+
+func (m *TransitionWitness) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TransitionWitness.Unmarshal(m, b)
+}
+func (m *TransitionWitness) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TransitionWitness.Marshal(b, m, deterministic)
+}
+func (m *TransitionWitness) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TransitionWitness.Merge(m, src)
+}
+func (m *TransitionWitness) XXX_Size() int {
+	return xxx_messageInfo_TransitionWitness.Size(m)
+}
+func (m *TransitionWitness) XXX_DiscardUnknown() {
+	xxx_messageInfo_TransitionWitness.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TransitionWitness proto.InternalMessageInfo
+
+func (m *TransitionWitness) GetInputs() []*StateSnapshot {
+	if m != nil {
+		return m.Inputs
+	}
+	return nil
+}
+
+func (m *TransitionWitness) GetInclusionProof() [][]byte {
+	if m != nil {
+		return m.InclusionProof
+	}
+	return nil
+}
+
+type BlockWitness struct {
+	BlockNumber          uint64               `protobuf:"varint,1,opt,name=blockNumber,proto3" json:"blockNumber,omitempty"`
+	StateRoot            []byte               `protobuf:"bytes,2,opt,name=stateRoot,proto3" json:"stateRoot,omitempty"`
+	NextSlotIndex        []byte               `protobuf:"bytes,3,opt,name=nextSlotIndex,proto3" json:"nextSlotIndex,omitempty"`
+	Snapshots            []*StateSnapshot     `protobuf:"bytes,4,rep,name=snapshots,proto3" json:"snapshots,omitempty"`
+	Transitions          []*TransitionWitness `protobuf:"bytes,5,rep,name=transitions,proto3" json:"transitions,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *BlockWitness) Reset()         { *m = BlockWitness{} }
+func (m *BlockWitness) String() string { return proto.CompactTextString(m) }
+func (*BlockWitness) ProtoMessage()    {}
+func (*BlockWitness) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6a1e9059dbd106eb, []int{2}
+}
+
+func (m *BlockWitness) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BlockWitness.Unmarshal(m, b)
+}
+func (m *BlockWitness) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BlockWitness.Marshal(b, m, deterministic)
+}
+func (m *BlockWitness) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BlockWitness.Merge(m, src)
+}
+func (m *BlockWitness) XXX_Size() int {
+	return xxx_messageInfo_BlockWitness.Size(m)
+}
+func (m *BlockWitness) XXX_DiscardUnknown() {
+	xxx_messageInfo_BlockWitness.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BlockWitness proto.InternalMessageInfo
+
+func (m *BlockWitness) GetBlockNumber() uint64 {
+	if m != nil {
+		return m.BlockNumber
+	}
+	return 0
+}
+
+func (m *BlockWitness) GetStateRoot() []byte {
+	if m != nil {
+		return m.StateRoot
+	}
+	return nil
+}
+
+func (m *BlockWitness) GetNextSlotIndex() []byte {
+	if m != nil {
+		return m.NextSlotIndex
+	}
+	return nil
+}
+
+func (m *BlockWitness) GetSnapshots() []*StateSnapshot {
+	if m != nil {
+		return m.Snapshots
+	}
+	return nil
+}
+
+func (m *BlockWitness) GetTransitions() []*TransitionWitness {
+	if m != nil {
+		return m.Transitions
+	}
+	return nil
+}
+
+type GetBlockWitnessRequest struct {
+	BlockNumber          uint64   `protobuf:"varint,1,opt,name=blockNumber,proto3" json:"blockNumber,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetBlockWitnessRequest) Reset()         { *m = GetBlockWitnessRequest{} }
+func (m *GetBlockWitnessRequest) String() string { return proto.CompactTextString(m) }
+func (*GetBlockWitnessRequest) ProtoMessage()    {}
+func (*GetBlockWitnessRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6a1e9059dbd106eb, []int{3}
+}
+
+func (m *GetBlockWitnessRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetBlockWitnessRequest.Unmarshal(m, b)
+}
+func (m *GetBlockWitnessRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetBlockWitnessRequest.Marshal(b, m, deterministic)
+}
+func (m *GetBlockWitnessRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetBlockWitnessRequest.Merge(m, src)
+}
+func (m *GetBlockWitnessRequest) XXX_Size() int {
+	return xxx_messageInfo_GetBlockWitnessRequest.Size(m)
+}
+func (m *GetBlockWitnessRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetBlockWitnessRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetBlockWitnessRequest proto.InternalMessageInfo
+
+func (m *GetBlockWitnessRequest) GetBlockNumber() uint64 {
+	if m != nil {
+		return m.BlockNumber
+	}
+	return 0
+}
+
+type GetBlockWitnessResponse struct {
+	Witness              *BlockWitness `protobuf:"bytes,1,opt,name=witness,proto3" json:"witness,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *GetBlockWitnessResponse) Reset()         { *m = GetBlockWitnessResponse{} }
+func (m *GetBlockWitnessResponse) String() string { return proto.CompactTextString(m) }
+func (*GetBlockWitnessResponse) ProtoMessage()    {}
+func (*GetBlockWitnessResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6a1e9059dbd106eb, []int{4}
+}
+
+func (m *GetBlockWitnessResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetBlockWitnessResponse.Unmarshal(m, b)
+}
+func (m *GetBlockWitnessResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetBlockWitnessResponse.Marshal(b, m, deterministic)
+}
+func (m *GetBlockWitnessResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetBlockWitnessResponse.Merge(m, src)
+}
+func (m *GetBlockWitnessResponse) XXX_Size() int {
+	return xxx_messageInfo_GetBlockWitnessResponse.Size(m)
+}
+func (m *GetBlockWitnessResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetBlockWitnessResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetBlockWitnessResponse proto.InternalMessageInfo
+
+func (m *GetBlockWitnessResponse) GetWitness() *BlockWitness {
+	if m != nil {
+		return m.Witness
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*StateSnapshot)(nil), "witness.StateSnapshot")
+	proto.RegisterType((*TransitionWitness)(nil), "witness.TransitionWitness")
+	proto.RegisterType((*BlockWitness)(nil), "witness.BlockWitness")
+	proto.RegisterType((*GetBlockWitnessRequest)(nil), "witness.GetBlockWitnessRequest")
+	proto.RegisterType((*GetBlockWitnessResponse)(nil), "witness.GetBlockWitnessResponse")
+}
+
+func init() { proto.RegisterFile("witness.proto", fileDescriptor_6a1e9059dbd106eb) }
+
+var fileDescriptor_6a1e9059dbd106eb = []byte{
+	// 378 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x53, 0xc1, 0x4e, 0xe2, 0x50,
+	0x14, 0x9d, 0x02, 0xc3, 0x84, 0x5b, 0x98, 0xc9, 0xbc, 0x44, 0x6c, 0x88, 0x89, 0x4d, 0x63, 0x0c,
+	0x31, 0xd2, 0x26, 0xe8, 0xca, 0xb8, 0x62, 0x63, 0x70, 0x61, 0x4c, 0x31, 0x9a, 0xb8, 0x6b, 0xcb,
+	0x03, 0x1a, 0xca, 0xbb, 0xb5, 0xef, 0x36, 0xf0, 0x23, 0xfe, 0xa3, 0x9f, 0x61, 0x28, 0x6d, 0x69,
+	0x01, 0x75, 0x79, 0x4f, 0xcf, 0xb9, 0xf7, 0x9c, 0x93, 0x3e, 0x68, 0x2d, 0x7d, 0x12, 0x5c, 0x4a,
+	0x33, 0x8c, 0x90, 0x90, 0xfd, 0x49, 0x47, 0xe3, 0x5d, 0x81, 0xd6, 0x88, 0x1c, 0xe2, 0x23, 0xe1,
+	0x84, 0x72, 0x86, 0xc4, 0x4e, 0xa0, 0x21, 0x03, 0xa4, 0xa1, 0x18, 0xf3, 0x95, 0xa6, 0xe8, 0x4a,
+	0xb7, 0x69, 0x6f, 0x01, 0xa6, 0x83, 0xea, 0x78, 0x1e, 0xc6, 0x82, 0x86, 0x62, 0x82, 0x5a, 0x25,
+	0xf9, 0x5e, 0x84, 0x12, 0xfd, 0x7a, 0xa1, 0x8d, 0x48, 0x5a, 0x35, 0xd5, 0x67, 0x00, 0x3b, 0x87,
+	0xbf, 0xbe, 0xf0, 0x82, 0x58, 0xfa, 0x28, 0x1e, 0x23, 0xc4, 0x89, 0x56, 0xd3, 0xab, 0xdd, 0xa6,
+	0xbd, 0x83, 0x1a, 0x73, 0xf8, 0xff, 0x14, 0x39, 0x42, 0xfa, 0xe4, 0xa3, 0x78, 0xd9, 0x98, 0x65,
+	0x26, 0xd4, 0x7d, 0x11, 0xc6, 0x24, 0x35, 0x45, 0xaf, 0x76, 0xd5, 0x7e, 0xdb, 0xcc, 0x52, 0x95,
+	0x22, 0xd8, 0x29, 0xeb, 0xc0, 0xb1, 0xca, 0xc1, 0x63, 0x1f, 0x0a, 0x34, 0x07, 0x01, 0x7a, 0xf3,
+	0xec, 0x90, 0x0e, 0xaa, 0xbb, 0x9e, 0x1f, 0xe2, 0x85, 0xcb, 0xa3, 0xa4, 0x85, 0x9a, 0x5d, 0x84,
+	0xca, 0x29, 0x2b, 0xbb, 0x29, 0xcf, 0xa0, 0x25, 0xf8, 0x8a, 0x46, 0x79, 0x8f, 0x9b, 0x1e, 0xca,
+	0x20, 0xbb, 0x86, 0x86, 0x4c, 0x2d, 0x4b, 0xad, 0xf6, 0x6d, 0xa2, 0x2d, 0x91, 0xdd, 0x82, 0x4a,
+	0x79, 0x33, 0x52, 0xfb, 0x9d, 0xe8, 0x3a, 0xb9, 0x6e, 0xaf, 0x35, 0xbb, 0x48, 0x37, 0x6e, 0xa0,
+	0x7d, 0xc7, 0xa9, 0x18, 0xd6, 0xe6, 0x6f, 0x31, 0x97, 0xf4, 0x73, 0x66, 0xe3, 0x1e, 0x8e, 0xf7,
+	0xb4, 0x32, 0x44, 0x21, 0x39, 0xb3, 0x20, 0xfb, 0xa3, 0x12, 0xa1, 0xda, 0x3f, 0xca, 0x0d, 0x95,
+	0xf8, 0x19, 0xab, 0x3f, 0x06, 0xc8, 0xb0, 0xd0, 0x63, 0xcf, 0xf0, 0x6f, 0x67, 0x33, 0x3b, 0xcd,
+	0x17, 0x1c, 0xf6, 0xdb, 0xd1, 0xbf, 0x26, 0x6c, 0x4c, 0x19, 0xbf, 0x06, 0x97, 0xaf, 0x17, 0x53,
+	0x9f, 0x66, 0xb1, 0x6b, 0x7a, 0xb8, 0xb0, 0x3c, 0x1e, 0xf0, 0xa8, 0x27, 0x38, 0x2d, 0x31, 0x9a,
+	0x5b, 0x53, 0xec, 0x45, 0x18, 0x04, 0x71, 0x68, 0xa5, 0x7b, 0xdc, 0x7a, 0xf2, 0x36, 0xae, 0x3e,
+	0x07, 0x00, 0x72, 0x10, 0x15, 0x7e, 0x2c, 0x03, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// WitnessRpcClient is the client API for WitnessRpc service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type WitnessRpcClient interface {
+	GetBlockWitness(ctx context.Context, in *GetBlockWitnessRequest, opts ...grpc.CallOption) (*GetBlockWitnessResponse, error)
+}
+
+type witnessRpcClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWitnessRpcClient(cc grpc.ClientConnInterface) WitnessRpcClient {
+	return &witnessRpcClient{cc}
+}
+
+func (c *witnessRpcClient) GetBlockWitness(ctx context.Context, in *GetBlockWitnessRequest, opts ...grpc.CallOption) (*GetBlockWitnessResponse, error) {
+	out := new(GetBlockWitnessResponse)
+	err := c.cc.Invoke(ctx, "/witness.WitnessRpc/GetBlockWitness", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// WitnessRpcServer is the server API for WitnessRpc service.
+type WitnessRpcServer interface {
+	GetBlockWitness(context.Context, *GetBlockWitnessRequest) (*GetBlockWitnessResponse, error)
+}
+
+// UnimplementedWitnessRpcServer can be embedded to have forward compatible implementations.
+type UnimplementedWitnessRpcServer struct {
+}
+
+func (*UnimplementedWitnessRpcServer) GetBlockWitness(ctx context.Context, req *GetBlockWitnessRequest) (*GetBlockWitnessResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBlockWitness not implemented")
+}
+
+func RegisterWitnessRpcServer(s *grpc.Server, srv WitnessRpcServer) {
+	s.RegisterService(&_WitnessRpc_serviceDesc, srv)
+}
+
+func _WitnessRpc_GetBlockWitness_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBlockWitnessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WitnessRpcServer).GetBlockWitness(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/witness.WitnessRpc/GetBlockWitness",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WitnessRpcServer).GetBlockWitness(ctx, req.(*GetBlockWitnessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _WitnessRpc_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "witness.WitnessRpc",
+	HandlerType: (*WitnessRpcServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetBlockWitness",
+			Handler:    _WitnessRpc_GetBlockWitness_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "witness.proto",
+}
@@ -0,0 +1,133 @@
+package witness
+
+import (
+	"bytes"
+	"math/big"
+	"net"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+
+	rollupdb "github.com/celer-network/go-rollup/db"
+	"github.com/celer-network/go-rollup/db/memorydb"
+	"github.com/celer-network/go-rollup/statemachine"
+	"github.com/celer-network/go-rollup/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var testToken = common.HexToAddress("0x1000000000000000000000000000000000000001")
+
+func newTestWitness(t *testing.T, db rollupdb.DB, serializer *types.Serializer) *types.BlockWitness {
+	err := db.Set(rollupdb.NamespaceTokenAddressToTokenIndex, testToken.Bytes(), big.NewInt(0).Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm, err := statemachine.NewStateMachine(db, serializer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := common.HexToAddress("0x2000000000000000000000000000000000000001")
+	_, err = sm.ApplyTransaction(&types.DepositTransaction{Account: account, Token: testToken, Amount: big.NewInt(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := &types.DepositTransaction{Account: account, Token: testToken, Amount: big.NewInt(2)}
+	inputs, err := sm.GetInputStateSnapshots(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := &types.RollupBlock{
+		BlockNumber: 1,
+		Transitions: []types.Transition{&types.DepositTransition{AccountSlotIndex: big.NewInt(0)}},
+	}
+	witness, err := sm.GetBlockWitness(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	witness.Transitions = []*types.TransitionWitness{{Inputs: inputs, InclusionProof: types.InclusionProof{{1}}}}
+	return witness
+}
+
+func checkWitnessEqual(t *testing.T, serializer *types.Serializer, a *types.BlockWitness, b *types.BlockWitness) {
+	encodedA, err := EncodeBlockWitness(serializer, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encodedB, err := EncodeBlockWitness(serializer, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(encodedA, encodedB) {
+		t.Error("witnesses differ")
+	}
+}
+
+func TestStore(t *testing.T) {
+	serializer, err := types.NewSerializer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := memorydb.NewDB()
+	witness := newTestWitness(t, db, serializer)
+	store := NewStore(db, serializer)
+	err = store.Put(witness)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, exists, err := store.Get(witness.BlockNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Fatal("stored witness not found")
+	}
+	checkWitnessEqual(t, serializer, witness, stored)
+	if _, exists, _ = store.Get(witness.BlockNumber + 1); exists {
+		t.Error("found the witness of an unknown block")
+	}
+}
+
+func TestServer(t *testing.T) {
+	serializer, err := types.NewSerializer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := memorydb.NewDB()
+	witness := newTestWitness(t, db, serializer)
+	store := NewStore(db, serializer)
+	err = store.Put(witness)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer()
+	RegisterWitnessRpcServer(grpcServer, NewServer(0, store))
+	go grpcServer.Serve(lis)
+	defer grpcServer.Stop()
+
+	client, err := NewClient(lis.Addr().String(), serializer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	fetched, err := client.GetBlockWitness(witness.BlockNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkWitnessEqual(t, serializer, witness, fetched)
+	stateless, err := statemachine.NewStatelessStateMachine(db, serializer, fetched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stateless.GetStateRoot(), witness.StateRoot) {
+		t.Error("stateless state root differs from witness state root")
+	}
+	if _, err = client.GetBlockWitness(witness.BlockNumber + 1); err == nil {
+		t.Error("fetching the witness of an unknown block did not fail")
+	}
+}